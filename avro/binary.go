@@ -0,0 +1,349 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Marshal will encode v in the Avro binary encoding of the schema. The value is first marshalled to JSON, so
+// record fields are matched to the JSON names of struct fields and fields left out by omitempty get their default.
+func Marshal(s *Schema, v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var datum interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err = d.Decode(&datum); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = encode(&buf, s, datum); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal will decode data in the Avro binary encoding of the schema into v, by way of its JSON representation
+func Unmarshal(s *Schema, data []byte, v interface{}) error {
+	r := bytes.NewReader(data)
+
+	datum, err := decode(r, s)
+	if err != nil {
+		return err
+	}
+
+	if r.Len() > 0 {
+		return fmt.Errorf("%d bytes left over after decoding", r.Len())
+	}
+
+	b, err := json.Marshal(datum)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+func encode(buf *bytes.Buffer, s *Schema, datum interface{}) error {
+	switch s.Type {
+	case "null":
+		if datum != nil {
+			return fmt.Errorf("expected null, got %v", datum)
+		}
+		return nil
+	case "boolean":
+		b, ok := datum.(bool)
+		if !ok {
+			return fmt.Errorf("expected boolean, got %v", datum)
+		}
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		return nil
+	case "int", "long":
+		n, err := toLong(s, datum)
+		if err != nil {
+			return err
+		}
+		writeLong(buf, n)
+		return nil
+	case "float", "double":
+		n, ok := datum.(json.Number)
+		if !ok {
+			return fmt.Errorf("expected number, got %v", datum)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return err
+		}
+		if s.Type == "float" {
+			return binary.Write(buf, binary.LittleEndian, math.Float32bits(float32(f)))
+		}
+		return binary.Write(buf, binary.LittleEndian, math.Float64bits(f))
+	case "string", "bytes":
+		str, ok := datum.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %v", datum)
+		}
+		writeLong(buf, int64(len(str)))
+		buf.WriteString(str)
+		return nil
+	case "record":
+		fields, ok := datum.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected record %s, got %v", s.Name, datum)
+		}
+		for _, f := range s.Fields {
+			value, found := fields[f.Name]
+			if !found {
+				if !f.HasDefault {
+					return fmt.Errorf("record %s is missing field %s, which has no default", s.Name, f.Name)
+				}
+				d := json.NewDecoder(bytes.NewReader(f.Default))
+				d.UseNumber()
+				if err := d.Decode(&value); err != nil {
+					return err
+				}
+			}
+			if err := encode(buf, f.Type, value); err != nil {
+				return fmt.Errorf("%s.%s: %w", s.Name, f.Name, err)
+			}
+		}
+		return nil
+	case "enum":
+		for i, symbol := range s.Symbols {
+			if symbol == datum {
+				writeLong(buf, int64(i))
+				return nil
+			}
+		}
+		return fmt.Errorf("%v is not a symbol of enum %s", datum, s.Name)
+	case "array":
+		items, ok := datum.([]interface{})
+		if !ok && datum != nil {
+			return fmt.Errorf("expected array, got %v", datum)
+		}
+		if len(items) > 0 {
+			writeLong(buf, int64(len(items)))
+			for _, item := range items {
+				if err := encode(buf, s.Items, item); err != nil {
+					return err
+				}
+			}
+		}
+		writeLong(buf, 0)
+		return nil
+	case "map":
+		values, ok := datum.(map[string]interface{})
+		if !ok && datum != nil {
+			return fmt.Errorf("expected map, got %v", datum)
+		}
+		if len(values) > 0 {
+			writeLong(buf, int64(len(values)))
+			for k, v := range values {
+				writeLong(buf, int64(len(k)))
+				buf.WriteString(k)
+				if err := encode(buf, s.Values, v); err != nil {
+					return err
+				}
+			}
+		}
+		writeLong(buf, 0)
+		return nil
+	case "union":
+		for i, branch := range s.Branches {
+			if matches(branch, datum) {
+				writeLong(buf, int64(i))
+				return encode(buf, branch, datum)
+			}
+		}
+		return fmt.Errorf("%v doesn't match any branch of the union", datum)
+	}
+
+	return fmt.Errorf("unsupported type %q", s.Type)
+}
+
+func decode(r *bytes.Reader, s *Schema) (interface{}, error) {
+	switch s.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.ReadByte()
+		return b == 1, err
+	case "int", "long":
+		n, err := readLong(r)
+		if err != nil {
+			return nil, err
+		}
+		if s.LogicalType == "timestamp-micros" {
+			return time.UnixMicro(n).UTC().Format(time.RFC3339Nano), nil
+		}
+		if s.LogicalType == "timestamp-millis" {
+			return time.UnixMilli(n).UTC().Format(time.RFC3339Nano), nil
+		}
+		return n, nil
+	case "float":
+		var bits uint32
+		err := binary.Read(r, binary.LittleEndian, &bits)
+		return math.Float32frombits(bits), err
+	case "double":
+		var bits uint64
+		err := binary.Read(r, binary.LittleEndian, &bits)
+		return math.Float64frombits(bits), err
+	case "string", "bytes":
+		n, err := readLong(r)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || n > int64(r.Len()) {
+			return nil, errors.New("invalid string length")
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return string(b), err
+	case "record":
+		fields := make(map[string]interface{}, len(s.Fields))
+		for _, f := range s.Fields {
+			value, err := decode(r, f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", s.Name, f.Name, err)
+			}
+			fields[f.Name] = value
+		}
+		return fields, nil
+	case "enum":
+		i, err := readLong(r)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(s.Symbols)) {
+			return nil, fmt.Errorf("invalid symbol index %d for enum %s", i, s.Name)
+		}
+		return s.Symbols[i], nil
+	case "array":
+		items := []interface{}{}
+		for {
+			n, err := readBlockCount(r)
+			if err != nil || n == 0 {
+				return items, err
+			}
+			for ; n > 0; n-- {
+				item, err := decode(r, s.Items)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+		}
+	case "map":
+		values := map[string]interface{}{}
+		for {
+			n, err := readBlockCount(r)
+			if err != nil || n == 0 {
+				return values, err
+			}
+			for ; n > 0; n-- {
+				k, err := decode(r, &Schema{Type: "string"})
+				if err != nil {
+					return nil, err
+				}
+				v, err := decode(r, s.Values)
+				if err != nil {
+					return nil, err
+				}
+				values[k.(string)] = v
+			}
+		}
+	case "union":
+		i, err := readLong(r)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(s.Branches)) {
+			return nil, fmt.Errorf("invalid union branch %d", i)
+		}
+		return decode(r, s.Branches[i])
+	}
+
+	return nil, fmt.Errorf("unsupported type %q", s.Type)
+}
+
+// matches returns true if the datum can be encoded as the branch of a union
+func matches(s *Schema, datum interface{}) bool {
+	switch datum.(type) {
+	case nil:
+		return s.Type == "null"
+	case bool:
+		return s.Type == "boolean"
+	case json.Number:
+		return s.Type == "int" || s.Type == "long" || s.Type == "float" || s.Type == "double"
+	case string:
+		return s.Type == "string" || s.Type == "bytes" || s.Type == "enum" || (s.Type == "long" && len(s.LogicalType) > 0)
+	case []interface{}:
+		return s.Type == "array"
+	case map[string]interface{}:
+		return s.Type == "record" || s.Type == "map"
+	}
+
+	return false
+}
+
+// toLong converts a datum to a long, timestamps are accepted in RFC 3339 format as that's how they're marshalled to JSON
+func toLong(s *Schema, datum interface{}) (int64, error) {
+	if str, ok := datum.(string); ok && (s.LogicalType == "timestamp-micros" || s.LogicalType == "timestamp-millis") {
+		t, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return 0, err
+		}
+		if s.LogicalType == "timestamp-millis" {
+			return t.UnixMilli(), nil
+		}
+		return t.UnixMicro(), nil
+	}
+
+	n, ok := datum.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("expected %s, got %v", s.Type, datum)
+	}
+
+	return n.Int64()
+}
+
+func writeLong(buf *bytes.Buffer, n int64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutVarint(b[:], n)])
+}
+
+func readLong(r *bytes.Reader) (int64, error) {
+	return binary.ReadVarint(r)
+}
+
+// readBlockCount reads the item count of an array or map block, a negative count is followed by the block size
+func readBlockCount(r *bytes.Reader) (int64, error) {
+	n, err := readLong(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if n < 0 {
+		if _, err = readLong(r); err != nil {
+			return 0, err
+		}
+		n = -n
+	}
+
+	return n, nil
+}
@@ -0,0 +1,120 @@
+package avro
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CanRead returns an error if data written with the writer schema can't be read with the reader schema,
+// following the Avro schema resolution rules. A new version of a schema is backward compatible with the
+// previous version if CanRead(new, previous) returns nil.
+func CanRead(reader, writer *Schema) error {
+	return canRead(reader, writer, "")
+}
+
+func canRead(reader, writer *Schema, path string) error {
+	if writer.Type == "union" {
+		for _, branch := range writer.Branches {
+			if err := canRead(reader, branch, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if reader.Type == "union" {
+		for _, branch := range reader.Branches {
+			if canRead(branch, writer, path) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: no branch of the union can read %s", pathOrRoot(path), writer.Type)
+	}
+
+	if reader.Type != writer.Type && !promotable(writer.Type, reader.Type) {
+		return fmt.Errorf("%s: %s can't be read as %s", pathOrRoot(path), writer.Type, reader.Type)
+	}
+
+	switch reader.Type {
+	case "record":
+		if shortName(reader.Name) != shortName(writer.Name) {
+			return fmt.Errorf("%s: record %s can't be read as %s", pathOrRoot(path), writer.Name, reader.Name)
+		}
+
+		for _, rf := range reader.Fields {
+			wf, found := field(writer, rf.Name)
+			if !found {
+				if !rf.HasDefault {
+					return fmt.Errorf("%s.%s: field was added without a default", pathOrRoot(path), rf.Name)
+				}
+				continue
+			}
+
+			if err := canRead(rf.Type, wf.Type, path+"."+rf.Name); err != nil {
+				return err
+			}
+		}
+	case "enum":
+		for _, symbol := range writer.Symbols {
+			if !contains(reader.Symbols, symbol) {
+				return fmt.Errorf("%s: enum symbol %s was removed", pathOrRoot(path), symbol)
+			}
+		}
+	case "array":
+		return canRead(reader.Items, writer.Items, path+"[]")
+	case "map":
+		return canRead(reader.Values, writer.Values, path+"{}")
+	}
+
+	return nil
+}
+
+// promotable returns true if a value written as the writer type can be read as the reader type
+func promotable(writer, reader string) bool {
+	switch writer {
+	case "int":
+		return reader == "long" || reader == "float" || reader == "double"
+	case "long":
+		return reader == "float" || reader == "double"
+	case "float":
+		return reader == "double"
+	case "string":
+		return reader == "bytes"
+	case "bytes":
+		return reader == "string"
+	}
+
+	return false
+}
+
+func field(s *Schema, name string) (Field, bool) {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return Field{}, false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func shortName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func pathOrRoot(path string) string {
+	if len(path) == 0 {
+		return "<root>"
+	}
+
+	return strings.TrimPrefix(path, ".")
+}
@@ -0,0 +1,165 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Schema is a parsed Avro schema. Only the parts of the specification the events in this repository need are
+// supported: the primitive types, records, enums, arrays, maps, unions and the uuid and timestamp logical types.
+type Schema struct {
+	Type        string
+	Name        string // full name of records and enums
+	LogicalType string
+	Fields      []Field   // records
+	Symbols     []string  // enums
+	Items       *Schema   // arrays
+	Values      *Schema   // maps
+	Branches    []*Schema // unions
+}
+
+// Field is a field of a record
+type Field struct {
+	Name       string
+	Type       *Schema
+	Default    json.RawMessage
+	HasDefault bool
+}
+
+var primitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true, "float": true, "double": true, "bytes": true, "string": true,
+}
+
+// Parse will parse the JSON representation of a schema
+func Parse(schema string) (*Schema, error) {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(schema), &raw); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+
+	p := parser{named: make(map[string]*Schema)}
+	return p.parse(raw, "")
+}
+
+type parser struct {
+	named map[string]*Schema
+}
+
+func (p parser) parse(raw interface{}, namespace string) (*Schema, error) {
+	switch v := raw.(type) {
+	case string:
+		if primitives[v] {
+			return &Schema{Type: v}, nil
+		}
+
+		if s, found := p.named[fullName(v, namespace)]; found {
+			return s, nil
+		}
+		if s, found := p.named[v]; found {
+			return s, nil
+		}
+
+		return nil, fmt.Errorf("unknown type %q", v)
+	case []interface{}:
+		union := &Schema{Type: "union"}
+		for _, branch := range v {
+			s, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+
+			union.Branches = append(union.Branches, s)
+		}
+
+		return union, nil
+	case map[string]interface{}:
+		return p.parseComplex(v, namespace)
+	}
+
+	return nil, fmt.Errorf("unsupported schema %v", raw)
+}
+
+func (p parser) parseComplex(v map[string]interface{}, namespace string) (*Schema, error) {
+	t, _ := v["type"].(string)
+	logicalType, _ := v["logicalType"].(string)
+
+	if primitives[t] {
+		return &Schema{Type: t, LogicalType: logicalType}, nil
+	}
+
+	switch t {
+	case "record", "enum":
+		name, _ := v["name"].(string)
+		if len(name) == 0 {
+			return nil, fmt.Errorf("%s has no name", t)
+		}
+
+		if ns, ok := v["namespace"].(string); ok {
+			namespace = ns
+		}
+
+		s := &Schema{Type: t, Name: fullName(name, namespace)}
+		if i := strings.LastIndex(s.Name, "."); i >= 0 {
+			namespace = s.Name[:i]
+		}
+
+		// register the type before parsing its fields, so it can refer to itself
+		p.named[s.Name] = s
+
+		if t == "enum" {
+			for _, symbol := range v["symbols"].([]interface{}) {
+				s.Symbols = append(s.Symbols, symbol.(string))
+			}
+
+			return s, nil
+		}
+
+		fields, _ := v["fields"].([]interface{})
+		for _, rawField := range fields {
+			f, ok := rawField.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("record %s has an invalid field", s.Name)
+			}
+
+			fieldType, err := p.parse(f["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field %v of record %s: %w", f["name"], s.Name, err)
+			}
+
+			field := Field{Name: f["name"].(string), Type: fieldType}
+			if d, found := f["default"]; found {
+				field.HasDefault = true
+				field.Default, _ = json.Marshal(d)
+			}
+
+			s.Fields = append(s.Fields, field)
+		}
+
+		return s, nil
+	case "array":
+		items, err := p.parse(v["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Schema{Type: t, Items: items}, nil
+	case "map":
+		values, err := p.parse(v["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Schema{Type: t, Values: values}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %q", t)
+}
+
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || len(namespace) == 0 {
+		return name
+	}
+
+	return namespace + "." + name
+}
@@ -0,0 +1,138 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/avro"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/registry"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/schemas"
+)
+
+// AvroContentType is the media type of Avro events
+const AvroContentType = "application/vnd.apache.avro+binary"
+
+// Avro events are the Avro binary encoding of the event in the Confluent wire format: a zero magic byte,
+// the ID of the schema in the registry as a 4 byte big endian integer, then the encoded event
+const wireHeaderSize = 5
+
+// Registry is the schema registry Avro schemas are registered with and looked up in, the registry configured
+// in the environment is used if it isn't set
+var Registry registry.Client
+
+var (
+	registryOnce sync.Once
+	avroMu       sync.Mutex
+	registered   = make(map[string]int)       // schema ID of the latest schema of each event
+	avroSchemas  = make(map[int]*avro.Schema) // parsed schemas by ID
+)
+
+func encodeAvro(event events.Event) ([]byte, []kafka.Header, error) {
+	id, s, err := latestAvroSchema(event.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, err := avro.Marshal(s, event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	value := make([]byte, wireHeaderSize, wireHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(value[1:], uint32(id))
+
+	return append(value, payload...), []kafka.Header{{Key: headers.ContentType, Value: []byte(AvroContentType)}}, nil
+}
+
+// decodeAvro returns the legacy JSON representation of an Avro event
+func decodeAvro(value []byte) ([]byte, error) {
+	if len(value) < wireHeaderSize || value[0] != 0 {
+		return nil, errors.New("value is not in the Confluent wire format")
+	}
+
+	s, err := avroSchemaByID(int(binary.BigEndian.Uint32(value[1:wireHeaderSize])))
+	if err != nil {
+		return nil, err
+	}
+
+	var event json.RawMessage
+	err = avro.Unmarshal(s, value[wireHeaderSize:], &event)
+
+	return event, err
+}
+
+// isAvro returns true if the message looks like it is in the Confluent wire format, JSON never starts with a zero byte
+func isAvro(msg *kafka.Message) bool {
+	return headers.Get(msg.Headers, headers.ContentType) == AvroContentType ||
+		(len(msg.Value) >= wireHeaderSize && msg.Value[0] == 0)
+}
+
+// latestAvroSchema registers the latest schema of the event, under the full name of its record
+func latestAvroSchema(name string) (int, *avro.Schema, error) {
+	avroMu.Lock()
+	defer avroMu.Unlock()
+
+	if id, found := registered[name]; found {
+		return id, avroSchemas[id], nil
+	}
+
+	raw, _, err := schemas.Latest(name)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	s, err := avro.Parse(raw)
+	if err != nil {
+		return 0, nil, fmt.Errorf("schema of %s is invalid: %w", name, err)
+	}
+
+	id, err := schemaRegistry().Register(s.Name, raw)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	registered[name] = id
+	avroSchemas[id] = s
+
+	return id, s, nil
+}
+
+func avroSchemaByID(id int) (*avro.Schema, error) {
+	avroMu.Lock()
+	defer avroMu.Unlock()
+
+	if s, found := avroSchemas[id]; found {
+		return s, nil
+	}
+
+	raw, err := schemaRegistry().SchemaByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := avro.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d is invalid: %w", id, err)
+	}
+
+	avroSchemas[id] = s
+
+	return s, nil
+}
+
+func schemaRegistry() registry.Client {
+	registryOnce.Do(func() {
+		if Registry == nil {
+			Registry = registry.NewClient()
+		}
+	})
+
+	return Registry
+}
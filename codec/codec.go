@@ -0,0 +1,229 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
+)
+
+// Format is how an event is encoded in a message
+type Format string
+
+const (
+	// Legacy events are the event struct marshalled as JSON, e.g. {"EventBase":{...},"EventBody":{...}}
+	Legacy Format = "legacy"
+
+	// Structured events are a CloudEvents 1.0 JSON envelope in the message value
+	Structured Format = "structured"
+
+	// Binary events are CloudEvents 1.0 in Kafka binary mode, attributes are ce_ headers and the value is the data
+	Binary Format = "binary"
+
+	// Avro events are the Avro binary encoding of the event, in the Confluent wire format
+	Avro Format = "avro"
+)
+
+const (
+	// JSONContentType is the media type of legacy events and of the data of CloudEvents
+	JSONContentType = "application/json"
+
+	// CloudEventsContentType is the media type of structured CloudEvents
+	CloudEventsContentType = "application/cloudevents+json"
+
+	specVersion = "1.0"
+	typePrefix  = "com.ppe4all."
+)
+
+// cloudEvent is the structured JSON representation of a CloudEvent
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	PartitionKey    string          `json:"partitionkey,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// FormatFor returns the format events published to the topic are encoded in
+func FormatFor(topic string) Format {
+	if config.AvroTopic(topic) {
+		return Avro
+	}
+
+	switch Format(config.CloudEventsMode(topic)) {
+	case Structured:
+		return Structured
+	case Binary:
+		return Binary
+	}
+
+	return Legacy
+}
+
+// Encode returns the value and headers of a message holding the event in the format. The source identifies
+// the service publishing the event in CloudEvents.
+func Encode(event events.Event, format Format, source string) ([]byte, []kafka.Header, error) {
+	switch format {
+	case Legacy:
+		value, err := json.Marshal(event)
+		return value, []kafka.Header{{Key: headers.ContentType, Value: []byte(JSONContentType)}}, err
+	case Avro:
+		return encodeAvro(event)
+	}
+
+	data, err := eventData(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ce := cloudEvent{
+		SpecVersion:     specVersion,
+		ID:              event.ID().String(),
+		Source:          source,
+		Type:            typePrefix + event.Name(),
+		Time:            event.Timestamp(),
+		DataContentType: JSONContentType,
+		Data:            data,
+	}
+	if keyed, ok := event.(events.Keyed); ok {
+		ce.PartitionKey = keyed.Key()
+	}
+
+	if format == Binary {
+		hs := []kafka.Header{
+			{Key: headers.ContentType, Value: []byte(JSONContentType)},
+			{Key: "ce_specversion", Value: []byte(ce.SpecVersion)},
+			{Key: "ce_id", Value: []byte(ce.ID)},
+			{Key: "ce_source", Value: []byte(ce.Source)},
+			{Key: "ce_type", Value: []byte(ce.Type)},
+			{Key: "ce_time", Value: []byte(ce.Time.Format(time.RFC3339Nano))},
+		}
+		if len(ce.PartitionKey) > 0 {
+			hs = append(hs, kafka.Header{Key: "ce_partitionkey", Value: []byte(ce.PartitionKey)})
+		}
+
+		return data, hs, nil
+	}
+
+	value, err := json.Marshal(ce)
+	return value, []kafka.Header{{Key: headers.ContentType, Value: []byte(CloudEventsContentType)}}, err
+}
+
+// Decode will decode the value of a message into the event (a pointer to one of the types in the events
+// package), whether it was encoded as a legacy event, as a CloudEvent or in Avro. Events published with an
+// older version of their schema are upcast to the current version first.
+func Decode(msg *kafka.Message, event interface{}) error {
+	value, err := legacyValue(msg)
+	if err != nil {
+		return err
+	}
+
+	name := headers.Get(msg.Headers, headers.EventName)
+	if e, ok := event.(events.Event); ok {
+		name = e.Name()
+	}
+
+	// events published before versions were introduced have no schema version header, they're version 1
+	version := 1
+	if v, err := strconv.Atoi(headers.Get(msg.Headers, headers.SchemaVersion)); err == nil {
+		version = v
+	}
+
+	if value, err = events.Upcast(name, version, value); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(value, event)
+}
+
+// legacyValue returns the legacy JSON representation of the event in a message, whatever format it is in
+func legacyValue(msg *kafka.Message) ([]byte, error) {
+	if isAvro(msg) {
+		return decodeAvro(msg.Value)
+	}
+
+	// binary mode CloudEvents always have a spec version header
+	if specVersion := headers.Get(msg.Headers, "ce_specversion"); len(specVersion) > 0 {
+		timestamp, err := time.Parse(time.RFC3339Nano, headers.Get(msg.Headers, "ce_time"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CloudEvent time: %w", err)
+		}
+
+		return envelope(headers.Get(msg.Headers, "ce_id"), timestamp, msg.Value)
+	}
+
+	// structured mode CloudEvents should have a content type header, but look for the spec version in case
+	// they were published by a producer that doesn't set headers
+	var probe struct {
+		SpecVersion string `json:"specversion"`
+	}
+	if headers.Get(msg.Headers, headers.ContentType) == CloudEventsContentType ||
+		(json.Unmarshal(msg.Value, &probe) == nil && len(probe.SpecVersion) > 0) {
+		var ce cloudEvent
+		if err := json.Unmarshal(msg.Value, &ce); err != nil {
+			return nil, err
+		}
+
+		if len(ce.SpecVersion) == 0 {
+			return nil, errors.New("structured CloudEvent has no spec version")
+		}
+
+		return envelope(ce.ID, ce.Time, ce.Data)
+	}
+
+	return msg.Value, nil
+}
+
+// eventData returns everything in the event apart from the EventBase. For most events that is just the
+// EventBody, events with other fields (like Error) keep their field names.
+func eventData(event events.Event) (json.RawMessage, error) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	delete(fields, "EventBase")
+	if body, ok := fields["EventBody"]; ok && len(fields) == 1 {
+		return body, nil
+	}
+
+	return json.Marshal(fields)
+}
+
+// envelope rebuilds the legacy representation of an event from the attributes and data of a CloudEvent
+func envelope(id string, timestamp time.Time, data json.RawMessage) ([]byte, error) {
+	base, err := json.Marshal(map[string]interface{}{
+		"EventID":        id,
+		"EventTimestamp": timestamp,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	legacy := map[string]json.RawMessage{}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) == nil && fields["EventBody"] != nil {
+		legacy = fields
+	} else {
+		legacy["EventBody"] = data
+	}
+	legacy["EventBase"] = base
+
+	return json.Marshal(legacy)
+}
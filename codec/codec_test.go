@@ -0,0 +1,284 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// decodable lists a new value of every event that has fixtures in events/testdata
+var decodable = []func() events.Event{
+	func() events.Event { return &events.OrderReceived{} },
+	func() events.Event { return &events.FraudCheckPassed{} },
+	func() events.Event { return &events.OrderHeld{} },
+	func() events.Event { return &events.OrderConfirmed{} },
+	func() events.Event { return &events.OrderPickedAndPacked{} },
+	func() events.Event { return &events.PaymentAuthorized{} },
+	func() events.Event { return &events.PaymentDeclined{} },
+	func() events.Event { return &events.OrderShipped{} },
+	func() events.Event { return &events.ReturnReceived{} },
+	func() events.Event { return &events.Notification{} },
+	func() events.Event { return &events.OrderCountMetric{} },
+	func() events.Event { return &events.OrderTimeMetric{} },
+	func() events.Event { return &events.Rejection{} },
+}
+
+func TestDecodeFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "events", "testdata", "*.v*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := make(map[string]func() events.Event)
+	for _, newEvent := range decodable {
+		types[newEvent().Name()] = newEvent
+	}
+
+	fixtures := make(map[string]bool)
+	for _, file := range files {
+		name, rest, _ := strings.Cut(filepath.Base(file), ".v")
+		version, err := strconv.Atoi(strings.TrimSuffix(rest, ".json"))
+		if err != nil {
+			t.Fatalf("%s isn't named <event name>.v<version>.json", file)
+		}
+		fixtures[name] = true
+
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			newEvent, found := types[name]
+			if !found {
+				t.Fatalf("there is no event named %s", name)
+			}
+
+			value, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg := &kafka.Message{
+				Value: value,
+				Headers: []kafka.Header{
+					{Key: headers.EventName, Value: []byte(name)},
+					{Key: headers.SchemaVersion, Value: []byte(strconv.Itoa(version))},
+				},
+			}
+
+			event := newEvent()
+			if err = Decode(msg, event); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if event.ID().String() == "00000000-0000-0000-0000-000000000000" {
+				t.Error("Decode() left the event ID empty")
+			}
+
+			// every field of the fixture, once upcast, should have somewhere to go in the current type
+			upcast, err := events.Upcast(name, version, value)
+			if err != nil {
+				t.Fatalf("Upcast() error = %v", err)
+			}
+
+			d := json.NewDecoder(bytes.NewReader(upcast))
+			d.DisallowUnknownFields()
+			if err = d.Decode(newEvent()); err != nil {
+				t.Errorf("fixture has fields the current type doesn't: %v", err)
+			}
+		})
+	}
+
+	for name := range types {
+		if !fixtures[name] {
+			t.Errorf("there is no fixture of %s", name)
+		}
+	}
+}
+
+func TestDecodeFormats(t *testing.T) {
+	version := strconv.Itoa(events.SchemaVersion(events.OrderShipped{}.Name()))
+	value, err := os.ReadFile(filepath.Join("..", "events", "testdata", "OrderShipped.v"+version+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want events.OrderShipped
+	if err = json.Unmarshal(value, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []Format{Legacy, Structured, Binary} {
+		t.Run(string(format), func(t *testing.T) {
+			value, hs, err := Encode(want, format, "test")
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			// the publisher adds the schema version of the event alongside the encoded value
+			hs = append(hs, kafka.Header{Key: headers.SchemaVersion, Value: []byte(version)})
+
+			var got events.OrderShipped
+			if err = Decode(&kafka.Message{Value: value, Headers: hs}, &got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Decode() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// upcastTestEvent is only registered by this test, its versions are:
+//
+//	1: {"EventBody":{"name":"..."}}
+//	2: {"EventBody":{"fullName":"..."}}
+//	3: {"EventBody":{"fullName":"...","channel":"..."}}
+const upcastTestEvent = "CodecUpcastTest"
+
+func init() {
+	events.RegisterUpcaster(upcastTestEvent, 1, func(event map[string]interface{}) error {
+		body := event["EventBody"].(map[string]interface{})
+		body["fullName"] = body["name"]
+		delete(body, "name")
+
+		return nil
+	})
+	events.RegisterUpcaster(upcastTestEvent, 2, func(event map[string]interface{}) error {
+		body := event["EventBody"].(map[string]interface{})
+		body["channel"] = "web"
+
+		return nil
+	})
+}
+
+func TestDecodeUpcastsOlderVersions(t *testing.T) {
+	type body struct {
+		FullName string `json:"fullName"`
+		Channel  string `json:"channel"`
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		value   string
+		want    body
+	}{
+		{
+			name:  "no version is version 1",
+			value: `{"EventBody":{"name":"Tom Hardy"}}`,
+			want:  body{FullName: "Tom Hardy", Channel: "web"},
+		},
+		{
+			name:    "version 1",
+			version: "1",
+			value:   `{"EventBody":{"name":"Tom Hardy"}}`,
+			want:    body{FullName: "Tom Hardy", Channel: "web"},
+		},
+		{
+			name:    "version 2",
+			version: "2",
+			value:   `{"EventBody":{"fullName":"Tom Hardy"}}`,
+			want:    body{FullName: "Tom Hardy", Channel: "web"},
+		},
+		{
+			name:    "current version",
+			version: "3",
+			value:   `{"EventBody":{"fullName":"Tom Hardy","channel":"phone"}}`,
+			want:    body{FullName: "Tom Hardy", Channel: "phone"},
+		},
+		{
+			name:    "newer version",
+			version: "4",
+			value:   `{"EventBody":{"fullName":"Tom Hardy","channel":"phone"}}`,
+			want:    body{FullName: "Tom Hardy", Channel: "phone"},
+		},
+	}
+
+	if got := events.SchemaVersion(upcastTestEvent); got != 3 {
+		t.Fatalf("SchemaVersion() = %d, want 3", got)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := []kafka.Header{{Key: headers.EventName, Value: []byte(upcastTestEvent)}}
+			if len(tt.version) > 0 {
+				hs = append(hs, kafka.Header{Key: headers.SchemaVersion, Value: []byte(tt.version)})
+			}
+
+			var got struct {
+				EventBody body
+			}
+			if err := Decode(&kafka.Message{Value: []byte(tt.value), Headers: hs}, &got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if got.EventBody != tt.want {
+				t.Errorf("Decode() = %+v, want %+v", got.EventBody, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeFillsInOrderDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		value   string
+		want    events.OrderReceived
+	}{
+		{
+			name:    "version 1 gets the defaults of the time",
+			version: "1",
+			value:   `{"EventBody":{"salesChannel":"","customer":{"shippingAddress":{"country":""},"billingAddress":{}}}}`,
+			want: events.OrderReceived{EventBody: models.Order{
+				SalesChannel: models.DefaultSalesChannel,
+				ServiceLevel: models.StandardShipping,
+				Customer: models.Customer{
+					ShippingAddress: models.Address{Country: models.DefaultCountry},
+					BillingAddress:  &models.Address{Country: models.DefaultCountry},
+				},
+			}},
+		},
+		{
+			name:    "version 1 keeps what was given",
+			version: "1",
+			value:   `{"EventBody":{"salesChannel":"web","serviceLevel":"overnight","customer":{"shippingAddress":{"country":"CA"}}}}`,
+			want: events.OrderReceived{EventBody: models.Order{
+				SalesChannel: "web",
+				ServiceLevel: models.OvernightShipping,
+				Customer:     models.Customer{ShippingAddress: models.Address{Country: "CA"}},
+			}},
+		},
+		{
+			name:    "version 2 is left alone",
+			version: "2",
+			value:   `{"EventBody":{"customer":{"shippingAddress":{}}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := []kafka.Header{
+				{Key: headers.EventName, Value: []byte(tt.want.Name())},
+				{Key: headers.SchemaVersion, Value: []byte(tt.version)},
+			}
+
+			var got events.OrderReceived
+			if err := Decode(&kafka.Message{Value: []byte(tt.value), Headers: hs}, &got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", got.EventBody, tt.want.EventBody)
+			}
+		})
+	}
+}
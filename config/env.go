@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -40,6 +42,122 @@ const (
 	// value of the database name
 	DatabaseNameEnvVar = "DB_NAME"
 
+	// RetryAttemptsEnvVar is the name of the environment variable that controls how many
+	// times a consumer retries a retryable failure in place before moving the event on
+	RetryAttemptsEnvVar = "RETRY_ATTEMPTS"
+
+	// RetryBackoffEnvVar is the name of the environment variable that controls the initial
+	// backoff (in milliseconds) between in place retries, doubled on every attempt
+	RetryBackoffEnvVar = "RETRY_BACKOFF_MS"
+
+	// RetryTopicAttemptsEnvVar is the name of the environment variable that controls how many
+	// times an event is published to a retry topic before it is sent to the dead letter queue
+	RetryTopicAttemptsEnvVar = "RETRY_TOPIC_ATTEMPTS"
+
+	// RetryTopicBackoffEnvVar is the name of the environment variable that controls how long (in milliseconds)
+	// an event waits in a retry topic before it is consumed again, doubled on every trip through it
+	RetryTopicBackoffEnvVar = "RETRY_TOPIC_BACKOFF_MS"
+
+	// ShutdownTimeoutEnvVar is the name of the environment variable that controls how long (in milliseconds)
+	// the HTTP server waits for in-flight requests to drain when shutting down
+	ShutdownTimeoutEnvVar = "SHUTDOWN_TIMEOUT_MS"
+
+	// PartitionWorkersEnvVar is the name of the environment variable that controls whether a consumer processes
+	// each assigned partition concurrently on its own worker, or every message one at a time
+	PartitionWorkersEnvVar = "PARTITION_WORKERS"
+
+	// CloudEventsTopicsEnvVar is the name of the environment variable that controls which topics events are
+	// published to as CloudEvents, as a comma separated list of <topic>=<structured|binary>
+	CloudEventsTopicsEnvVar = "CLOUDEVENTS_TOPICS"
+
+	// AvroTopicsEnvVar is the name of the environment variable that controls which topics events are published
+	// to in Avro, as a comma separated list of topic names
+	AvroTopicsEnvVar = "AVRO_TOPICS"
+
+	// SchemaRegistryURLEnvVar is the name of the environment variable that controls the URL of the Confluent
+	// schema registry, the file backed registry is used if it isn't set
+	SchemaRegistryURLEnvVar = "SCHEMA_REGISTRY_URL"
+
+	// SchemaRegistryDirEnvVar is the name of the environment variable that controls the directory of the file
+	// backed schema registry
+	SchemaRegistryDirEnvVar = "SCHEMA_REGISTRY_DIR"
+
+	// IdempotencyKeyTTLEnvVar is the name of the environment variable that controls how long (in hours) the
+	// response to a request with an Idempotency-Key is kept for replaying
+	IdempotencyKeyTTLEnvVar = "IDEMPOTENCY_KEY_TTL_HOURS"
+
+	// TaxRateEnvVar is the name of the environment variable that controls the rate (in basis points, hundredths
+	// of a percent) of tax charged on the products of an order that no tax rule matches
+	TaxRateEnvVar = "TAX_RATE_BPS"
+
+	// TaxProviderEnvVar is the name of the environment variable that controls how the tax of an order is
+	// calculated, either from the tax rules (rules) or by an external tax provider (http)
+	TaxProviderEnvVar = "TAX_PROVIDER"
+
+	// TaxRulesFileEnvVar is the name of the environment variable that controls the JSON file the tax rules are
+	// loaded from, there are no rules if it isn't set
+	TaxRulesFileEnvVar = "TAX_RULES_FILE"
+
+	// TaxProviderURLEnvVar is the name of the environment variable that controls the URL of the external tax
+	// provider
+	TaxProviderURLEnvVar = "TAX_PROVIDER_URL"
+
+	// PaymentGatewayEnvVar is the name of the environment variable that controls which gateway the payment of
+	// orders is authorised and captured with
+	PaymentGatewayEnvVar = "PAYMENT_GATEWAY"
+
+	// FakePaymentDeclineEnvVar is the name of the environment variable that controls which payments the fake
+	// payment gateway declines, either always or those over an amount (in minor units), none if not set
+	FakePaymentDeclineEnvVar = "FAKE_PAYMENT_DECLINE"
+
+	// FraudHoldScoreEnvVar is the name of the environment variable that controls the fraud score at which an order
+	// is held for review
+	FraudHoldScoreEnvVar = "FRAUD_HOLD_SCORE"
+
+	// FraudRejectScoreEnvVar is the name of the environment variable that controls the fraud score at which an
+	// order is rejected
+	FraudRejectScoreEnvVar = "FRAUD_REJECT_SCORE"
+
+	// FraudVelocityWindowEnvVar is the name of the environment variable that controls how far back (in minutes)
+	// the orders from the same email address or shipping address are counted
+	FraudVelocityWindowEnvVar = "FRAUD_VELOCITY_WINDOW_MINUTES"
+
+	// FraudMaxOrdersPerEmailEnvVar is the name of the environment variable that controls how many orders can come
+	// from an email address within the velocity window
+	FraudMaxOrdersPerEmailEnvVar = "FRAUD_MAX_ORDERS_PER_EMAIL"
+
+	// FraudMaxOrdersPerAddressEnvVar is the name of the environment variable that controls how many orders can be
+	// shipped to an address within the velocity window
+	FraudMaxOrdersPerAddressEnvVar = "FRAUD_MAX_ORDERS_PER_ADDRESS"
+
+	// FraudMaxQuantityEnvVar is the name of the environment variable that controls how many of a product an order
+	// can have
+	FraudMaxQuantityEnvVar = "FRAUD_MAX_QUANTITY"
+
+	// FraudBlocklistFileEnvVar is the name of the environment variable that controls the JSON file the blocked
+	// email addresses, email domains and postal codes are loaded from
+	FraudBlocklistFileEnvVar = "FRAUD_BLOCKLIST_FILE"
+
+	// PickIntervalEnvVar is the name of the environment variable that controls how often (in milliseconds) the
+	// warehouse takes the next order off the pick queue
+	PickIntervalEnvVar = "PICK_INTERVAL_MS"
+
+	// PickMaxUnitsEnvVar is the name of the environment variable that controls how many units of products the
+	// warehouse packs in one shipment, bigger orders are picked in several shipments
+	PickMaxUnitsEnvVar = "PICK_MAX_UNITS"
+
+	// CarriersEnvVar is the name of the environment variable that controls which carriers the shipper shops
+	// between, as a comma separated list of fake or <name>=<url> for a carrier with an HTTP adapter
+	CarriersEnvVar = "CARRIERS"
+
+	// LabelDirEnvVar is the name of the environment variable that controls the directory the shipping labels of
+	// shipments are kept in
+	LabelDirEnvVar = "LABEL_DIR"
+
+	// ShipperPortEnvVar is the name of the environment variable that controls the port the shipper serves the
+	// shipping labels on
+	ShipperPortEnvVar = "SHIPPER_PORT"
+
 	defaultLogLevel         = logrus.DebugLevel     // used if LOG_LEVEL not set
 	defaultPort             = 8080                  // used if PORT not set
 	defaultBrokerAddress    = "localhost"           // used if BROKER_ADDRESS not set
@@ -48,6 +166,32 @@ const (
 	defaultDatabaseUsername = "postgres"            // used if DB_USERNAME not set
 	defaultDatabasePassword = "postgres"            // used if DB_PASSWORD not set
 	defaultDatabaseName     = "liveproject"         // used if DB_NAME not set
+
+	defaultRetryAttempts      = 3     // used if RETRY_ATTEMPTS not set
+	defaultRetryBackoff       = 500   // used if RETRY_BACKOFF_MS not set
+	defaultRetryTopicAttempts = 5     // used if RETRY_TOPIC_ATTEMPTS not set
+	defaultRetryTopicBackoff  = 1000  // used if RETRY_TOPIC_BACKOFF_MS not set
+	defaultShutdownTimeout    = 15000 // used if SHUTDOWN_TIMEOUT_MS not set
+	defaultPartitionWorkers   = true  // used if PARTITION_WORKERS not set
+
+	defaultSchemaRegistryDir = ".schema-registry" // used if SCHEMA_REGISTRY_DIR not set
+	defaultIdempotencyKeyTTL = 24                 // used if IDEMPOTENCY_KEY_TTL_HOURS not set
+	defaultTaxRate           = 0                  // used if TAX_RATE_BPS not set
+	defaultTaxProvider       = "rules"            // used if TAX_PROVIDER not set
+	defaultPaymentGateway    = "fake"             // used if PAYMENT_GATEWAY not set
+
+	defaultFraudHoldScore           = 50  // used if FRAUD_HOLD_SCORE not set
+	defaultFraudRejectScore         = 100 // used if FRAUD_REJECT_SCORE not set
+	defaultFraudVelocityWindow      = 60  // used if FRAUD_VELOCITY_WINDOW_MINUTES not set
+	defaultFraudMaxOrdersPerEmail   = 3   // used if FRAUD_MAX_ORDERS_PER_EMAIL not set
+	defaultFraudMaxOrdersPerAddress = 5   // used if FRAUD_MAX_ORDERS_PER_ADDRESS not set
+	defaultFraudMaxQuantity         = 50  // used if FRAUD_MAX_QUANTITY not set
+
+	defaultPickInterval = 1000      // used if PICK_INTERVAL_MS not set
+	defaultPickMaxUnits = 100       // used if PICK_MAX_UNITS not set
+	defaultCarriers     = "fake"    // used if CARRIERS not set
+	defaultLabelDir     = ".labels" // used if LABEL_DIR not set
+	defaultShipperPort  = 8081      // used if SHIPPER_PORT not set
 )
 
 // LogLevel returns the log level set in the environment, or debug if not defined
@@ -115,6 +259,108 @@ func DatabaseName() string {
 	return value(DatabaseNameEnvVar, defaultDatabaseName)
 }
 
+// RetryAttempts returns how many times a retryable failure is retried in place, or default value if not defined
+func RetryAttempts() int {
+	return intValue(RetryAttemptsEnvVar, defaultRetryAttempts)
+}
+
+// RetryBackoff returns the initial backoff between in place retries, or default value if not defined
+func RetryBackoff() time.Duration {
+	return time.Duration(intValue(RetryBackoffEnvVar, defaultRetryBackoff)) * time.Millisecond
+}
+
+// RetryTopicAttempts returns how many times an event can go through a retry topic, or default value if not defined
+func RetryTopicAttempts() int {
+	return intValue(RetryTopicAttemptsEnvVar, defaultRetryTopicAttempts)
+}
+
+// RetryTopicBackoff returns how long an event waits in a retry topic on its first trip through it, or default
+// value if not defined
+func RetryTopicBackoff() time.Duration {
+	return time.Duration(intValue(RetryTopicBackoffEnvVar, defaultRetryTopicBackoff)) * time.Millisecond
+}
+
+// ShutdownTimeout returns how long to wait for in-flight requests when shutting down, or default value if not defined
+func ShutdownTimeout() time.Duration {
+	return time.Duration(intValue(ShutdownTimeoutEnvVar, defaultShutdownTimeout)) * time.Millisecond
+}
+
+// PartitionWorkers returns whether consumers should process partitions concurrently, or default value if not defined
+func PartitionWorkers() bool {
+	return boolValue(PartitionWorkersEnvVar, defaultPartitionWorkers)
+}
+
+// CloudEventsMode returns the CloudEvents mode (structured or binary) events published to the topic should be
+// encoded in, or an empty string if they should be published as they always have been
+func CloudEventsMode(topic string) string {
+	for _, entry := range strings.Split(os.Getenv(CloudEventsTopicsEnvVar), ",") {
+		if name, mode, found := strings.Cut(strings.TrimSpace(entry), "="); found && name == topic {
+			return mode
+		}
+	}
+
+	return ""
+}
+
+// AvroTopic returns whether events published to the topic should be encoded in Avro
+func AvroTopic(topic string) bool {
+	for _, name := range strings.Split(os.Getenv(AvroTopicsEnvVar), ",") {
+		if strings.TrimSpace(name) == topic {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SchemaRegistryURL returns the URL of the Confluent schema registry, or an empty string if not defined
+func SchemaRegistryURL() string {
+	return os.Getenv(SchemaRegistryURLEnvVar)
+}
+
+// SchemaRegistryDir returns the directory of the file backed schema registry, or default value if not defined
+func SchemaRegistryDir() string {
+	return value(SchemaRegistryDirEnvVar, defaultSchemaRegistryDir)
+}
+
+// IdempotencyKeyTTL returns how long the response to a request with an Idempotency-Key is kept, or default value
+// if not defined
+func IdempotencyKeyTTL() time.Duration {
+	return time.Duration(intValue(IdempotencyKeyTTLEnvVar, defaultIdempotencyKeyTTL)) * time.Hour
+}
+
+// TaxRate returns the rate of tax charged on the products of an order that no tax rule matches in basis points, or
+// default value if not defined
+func TaxRate() int64 {
+	return int64(intValue(TaxRateEnvVar, defaultTaxRate))
+}
+
+// TaxProvider returns how the tax of an order is calculated, or default value if not defined
+func TaxProvider() string {
+	return value(TaxProviderEnvVar, defaultTaxProvider)
+}
+
+// TaxRulesFile returns the JSON file the tax rules are loaded from, or an empty string if not defined
+func TaxRulesFile() string {
+	return os.Getenv(TaxRulesFileEnvVar)
+}
+
+// TaxProviderURL returns the URL of the external tax provider, or an empty string if not defined
+func TaxProviderURL() string {
+	return os.Getenv(TaxProviderURLEnvVar)
+}
+
+// PaymentGateway returns which gateway the payment of orders is authorised and captured with, or default value if
+// not defined
+func PaymentGateway() string {
+	return value(PaymentGatewayEnvVar, defaultPaymentGateway)
+}
+
+// FakePaymentDecline returns which payments the fake payment gateway declines, or an empty string if not defined
+func FakePaymentDecline() string {
+	return os.Getenv(FakePaymentDeclineEnvVar)
+}
+
 func value(key, defaultValue string) string {
 	var value string
 	var found bool
@@ -129,3 +375,117 @@ func value(key, defaultValue string) string {
 
 	return value
 }
+
+func intValue(key string, defaultValue int) int {
+	var (
+		rawValue string
+		found    bool
+		value    int
+		err      error
+	)
+
+	if rawValue, found = os.LookupEnv(key); !found {
+		return defaultValue
+	}
+
+	if value, err = strconv.Atoi(rawValue); err != nil || value < 0 {
+		return defaultValue
+	}
+
+	return value
+}
+
+// FraudHoldScore returns the fraud score at which an order is held for review, or default value if not defined
+func FraudHoldScore() int {
+	return intValue(FraudHoldScoreEnvVar, defaultFraudHoldScore)
+}
+
+// FraudRejectScore returns the fraud score at which an order is rejected, or default value if not defined
+func FraudRejectScore() int {
+	return intValue(FraudRejectScoreEnvVar, defaultFraudRejectScore)
+}
+
+// FraudVelocityWindow returns how far back the orders from the same email address or shipping address are counted,
+// or default value if not defined
+func FraudVelocityWindow() time.Duration {
+	return time.Duration(intValue(FraudVelocityWindowEnvVar, defaultFraudVelocityWindow)) * time.Minute
+}
+
+// FraudMaxOrdersPerEmail returns how many orders can come from an email address within the velocity window, or
+// default value if not defined
+func FraudMaxOrdersPerEmail() int {
+	return intValue(FraudMaxOrdersPerEmailEnvVar, defaultFraudMaxOrdersPerEmail)
+}
+
+// FraudMaxOrdersPerAddress returns how many orders can be shipped to an address within the velocity window, or
+// default value if not defined
+func FraudMaxOrdersPerAddress() int {
+	return intValue(FraudMaxOrdersPerAddressEnvVar, defaultFraudMaxOrdersPerAddress)
+}
+
+// FraudMaxQuantity returns how many of a product an order can have, or default value if not defined
+func FraudMaxQuantity() int {
+	return intValue(FraudMaxQuantityEnvVar, defaultFraudMaxQuantity)
+}
+
+// FraudBlocklistFile returns the JSON file the fraud blocklist is loaded from, or an empty string if not defined
+func FraudBlocklistFile() string {
+	return os.Getenv(FraudBlocklistFileEnvVar)
+}
+
+// PickInterval returns how often the warehouse takes the next order off the pick queue, or default value if not
+// defined
+func PickInterval() time.Duration {
+	return time.Duration(intValue(PickIntervalEnvVar, defaultPickInterval)) * time.Millisecond
+}
+
+// PickMaxUnits returns how many units of products the warehouse packs in one shipment, or default value if not
+// defined or not positive
+func PickMaxUnits() int {
+	if units := intValue(PickMaxUnitsEnvVar, defaultPickMaxUnits); units > 0 {
+		return units
+	}
+
+	return defaultPickMaxUnits
+}
+
+// Carriers returns the carriers the shipper shops between, or default value if not defined
+func Carriers() []string {
+	var carriers []string
+	for _, entry := range strings.Split(value(CarriersEnvVar, defaultCarriers), ",") {
+		if entry = strings.TrimSpace(entry); len(entry) > 0 {
+			carriers = append(carriers, entry)
+		}
+	}
+
+	return carriers
+}
+
+// LabelDir returns the directory the shipping labels of shipments are kept in, or default value if not defined
+func LabelDir() string {
+	return value(LabelDirEnvVar, defaultLabelDir)
+}
+
+// ShipperPort returns the port the shipper serves the shipping labels on, or default value if not defined
+func ShipperPort() int {
+	return intValue(ShipperPortEnvVar, defaultShipperPort)
+}
+
+func boolValue(key string, defaultValue bool) bool {
+	var (
+		rawValue string
+		found    bool
+		value    bool
+		err      error
+	)
+
+	if rawValue, found = os.LookupEnv(key); !found {
+		return defaultValue
+	}
+
+	if value, err = strconv.ParseBool(rawValue); err != nil {
+		return defaultValue
+	}
+
+	return value
+}
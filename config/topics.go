@@ -1,5 +1,7 @@
 package config
 
+import "strings"
+
 const (
 	// OrderReceivedTopicName is the name of the topic that handles OrderReceived events
 	OrderReceivedTopicName = "OrderReceived"
@@ -7,6 +9,24 @@ const (
 	// OrderConfirmedTopicName is the name of the topic that handles OrderConfirmed events
 	OrderConfirmedTopicName = "OrderConfirmed"
 
+	// FraudCheckPassedTopicName is the name of the topic that handles FraudCheckPassed events
+	FraudCheckPassedTopicName = "FraudCheckPassed"
+
+	// OrderHeldTopicName is the name of the topic that handles OrderHeld events
+	OrderHeldTopicName = "OrderHeld"
+
+	// PaymentAuthorizedTopicName is the name of the topic that handles PaymentAuthorized events
+	PaymentAuthorizedTopicName = "PaymentAuthorized"
+
+	// PaymentDeclinedTopicName is the name of the topic that handles PaymentDeclined events
+	PaymentDeclinedTopicName = "PaymentDeclined"
+
+	// OrderShippedTopicName is the name of the topic that handles OrderShipped events
+	OrderShippedTopicName = "OrderShipped"
+
+	// ReturnReceivedTopicName is the name of the topic that handles ReturnReceived events
+	ReturnReceivedTopicName = "ReturnReceived"
+
 	// NotificationTopicName is the name of the topic that handles Notification events
 	NotificationTopicName = "Notification"
 
@@ -21,4 +41,19 @@ const (
 
 	// OrderTimeTopicName is the name of the topic that handles order time metric events
 	OrderTimeTopicName = "OrderTime"
+
+	// RejectionsTopicName is the name of the topic that handles Rejection events
+	RejectionsTopicName = "Rejections"
+
+	// RetryTopicSuffix is appended to the name of a topic to get the name of its retry topic
+	RetryTopicSuffix = "Retry"
 )
+
+// RetryTopicName returns the name of the retry topic for the specified topic. A retry topic is its own retry topic.
+func RetryTopicName(topic string) string {
+	if strings.HasSuffix(topic, RetryTopicSuffix) {
+		return topic
+	}
+
+	return topic + RetryTopicSuffix
+}
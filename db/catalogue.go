@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+var (
+	// ErrDuplicateProduct is returned when a product with the same code is already in the catalogue
+	ErrDuplicateProduct = errors.New("a product with this code is already in the catalogue")
+
+	// ErrProductNotFound is returned when there is no product with the code in the catalogue
+	ErrProductNotFound = errors.New("product not found")
+)
+
+const selectCatalogueProducts = "select code, name, weight_grams, length_mm, width_mm, height_mm, tax_category, active from catalogue.products"
+
+// InsertCatalogueProduct will insert a product and its prices into the catalogue
+func (db DB) InsertCatalogueProduct(p models.CatalogueProduct, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "insert into catalogue.products (code, name, weight_grams, length_mm, width_mm, height_mm, tax_category, active, updated_timestamp) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		p.Code, p.Name, p.WeightGrams, p.Dimensions.LengthMm, p.Dimensions.WidthMm, p.Dimensions.HeightMm, p.TaxCategory, p.Active, time.Now()); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return ErrDuplicateProduct
+		}
+
+		logError(err, "encountered an issue inserting the product into the catalogue")
+		return err
+	}
+
+	return db.insertPrices(p, tx)
+}
+
+// UpdateCatalogueProduct will replace a product and its prices in the catalogue
+func (db DB) UpdateCatalogueProduct(p models.CatalogueProduct, tx pgx.Tx) error {
+	tag, err := tx.Exec(context.Background(), "update catalogue.products set name=$2, weight_grams=$3, length_mm=$4, width_mm=$5, height_mm=$6, tax_category=$7, active=$8, updated_timestamp=$9 where code=$1",
+		p.Code, p.Name, p.WeightGrams, p.Dimensions.LengthMm, p.Dimensions.WidthMm, p.Dimensions.HeightMm, p.TaxCategory, p.Active, time.Now())
+	if err != nil {
+		logError(err, "encountered an issue updating the product in the catalogue")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrProductNotFound
+	}
+
+	if _, err = tx.Exec(context.Background(), "delete from catalogue.prices where product_code=$1", p.Code); err != nil {
+		logError(err, "encountered an issue deleting the prices of the product")
+		return err
+	}
+
+	return db.insertPrices(p, tx)
+}
+
+// DeleteCatalogueProduct will delete a product and its prices from the catalogue
+func (db DB) DeleteCatalogueProduct(code string, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "delete from catalogue.prices where product_code=$1", code); err != nil {
+		logError(err, "encountered an issue deleting the prices of the product")
+		return err
+	}
+
+	tag, err := tx.Exec(context.Background(), "delete from catalogue.products where code=$1", code)
+	if err != nil {
+		logError(err, "encountered an issue deleting the product from the catalogue")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrProductNotFound
+	}
+
+	return nil
+}
+
+// GetCatalogueProduct will return the product with the code, along with its prices
+func (db DB) GetCatalogueProduct(code string, tx pgx.Tx) (models.CatalogueProduct, error) {
+	products, err := db.queryCatalogueProducts(tx, selectCatalogueProducts+" where code=$1", code)
+	if err != nil {
+		return models.CatalogueProduct{}, err
+	}
+	if len(products) == 0 {
+		return models.CatalogueProduct{}, ErrProductNotFound
+	}
+
+	return products[0], nil
+}
+
+// ListCatalogueProducts will return every product in the catalogue, or only the active ones, ordered by code
+func (db DB) ListCatalogueProducts(activeOnly bool, tx pgx.Tx) ([]models.CatalogueProduct, error) {
+	return db.queryCatalogueProducts(tx, selectCatalogueProducts+" where active or not $1 order by code", activeOnly)
+}
+
+// CatalogueProducts will return the products with the codes, by code. Codes that aren't in the catalogue aren't
+// in the result.
+func (db DB) CatalogueProducts(codes []string, tx pgx.Tx) (map[string]models.CatalogueProduct, error) {
+	products, err := db.queryCatalogueProducts(tx, selectCatalogueProducts+" where code = any($1)", codes)
+	if err != nil {
+		return nil, err
+	}
+
+	byCode := make(map[string]models.CatalogueProduct, len(products))
+	for _, p := range products {
+		byCode[p.Code] = p
+	}
+
+	return byCode, nil
+}
+
+func (db DB) insertPrices(p models.CatalogueProduct, tx pgx.Tx) error {
+	for currency, price := range p.Prices {
+		if _, err := tx.Exec(context.Background(), "insert into catalogue.prices (product_code, currency, unit_price) values ($1, $2, $3)", p.Code, currency, int64(price)); err != nil {
+			logError(err, "encountered an issue inserting the price of the product")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queryCatalogueProducts returns the products matching the query along with their prices
+func (db DB) queryCatalogueProducts(tx pgx.Tx, sql string, args ...interface{}) ([]models.CatalogueProduct, error) {
+	rows, err := tx.Query(context.Background(), sql, args...)
+	if err != nil {
+		logError(err, "encountered an issue querying the catalogue")
+		return nil, err
+	}
+
+	var products []models.CatalogueProduct
+	var codes []string
+	for rows.Next() {
+		var p models.CatalogueProduct
+		if err = rows.Scan(&p.Code, &p.Name, &p.WeightGrams, &p.Dimensions.LengthMm, &p.Dimensions.WidthMm, &p.Dimensions.HeightMm, &p.TaxCategory, &p.Active); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		p.Prices = make(map[string]models.Money)
+		products = append(products, p)
+		codes = append(codes, p.Code)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(products) == 0 {
+		return products, nil
+	}
+
+	rows, err = tx.Query(context.Background(), "select product_code, currency, unit_price from catalogue.prices where product_code = any($1)", codes)
+	if err != nil {
+		logError(err, "encountered an issue querying for prices")
+		return nil, err
+	}
+	defer rows.Close()
+
+	index := make(map[string]int, len(products))
+	for i, p := range products {
+		index[p.Code] = i
+	}
+
+	for rows.Next() {
+		var code, currency string
+		var price int64
+		if err = rows.Scan(&code, &currency, &price); err != nil {
+			return nil, err
+		}
+
+		products[index[code]].Prices[currency] = models.Money(price)
+	}
+
+	return products, rows.Err()
+}
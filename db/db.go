@@ -11,6 +11,7 @@ import (
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -32,12 +33,13 @@ func NewDB() DB {
 	}
 }
 
-// EventExists will check to see if an event has already been processed
-func (db DB) EventExists(event events.Event, tx pgx.Tx) (bool, error) {
+// EventExists will check to see if an event has already been processed by the consumer. Every consumer of an event
+// processes it once, whichever of them processes it first.
+func (db DB) EventExists(consumer string, event events.Event, tx pgx.Tx) (bool, error) {
 	var id string
 	var err error
 
-	if err = tx.QueryRow(context.Background(), "select id from events.processed_events where id=$1 and event_name=$2", event.ID(), event.Name()).Scan(&id); err != nil {
+	if err = tx.QueryRow(context.Background(), "select id from events.processed_events where consumer=$1 and id=$2 and event_name=$3", consumer, event.ID(), event.Name()).Scan(&id); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			log.WithField("code", pgErr.Code).
@@ -51,11 +53,11 @@ func (db DB) EventExists(event events.Event, tx pgx.Tx) (bool, error) {
 	return len(id) > 0, nil
 }
 
-// InsertEvent will insert a row into the processed_events table to indicate an event was processed.
-func (db DB) InsertEvent(event events.Event, tx pgx.Tx) error {
+// InsertEvent will insert a row into the processed_events table to indicate an event was processed by the consumer.
+func (db DB) InsertEvent(consumer string, event events.Event, tx pgx.Tx) error {
 	var err error
 
-	if _, err = tx.Exec(context.Background(), "insert into events.processed_events (id, event_name, processed_timestamp) values ($1, $2, $3)", event.ID(), event.Name(), time.Now()); err != nil {
+	if _, err = tx.Exec(context.Background(), "insert into events.processed_events (consumer, id, event_name, processed_timestamp) values ($1, $2, $3, $4)", consumer, event.ID(), event.Name(), time.Now()); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			log.WithField("code", pgErr.Code).
@@ -83,3 +85,18 @@ func (db DB) Connect() (*pgx.Conn, error) {
 
 	return conn, nil
 }
+
+// ConnectPool creates a new pool of connections to the database, the pool should be closed when it is no longer needed
+func (db DB) ConnectPool(ctx context.Context) (*pgxpool.Pool, error) {
+	url := fmt.Sprintf("postgres://%s:%s@%s/%s", db.Username, db.Password, db.Address, db.Database)
+	log.WithField("address", db.Address).Info("attempting to connect to DB")
+
+	pool, err := pgxpool.Connect(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("successfully connected to DB")
+
+	return pool, nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// ErrFraudAssessmentNotFound is returned when an order hasn't been scored for fraud
+var ErrFraudAssessmentNotFound = errors.New("fraud assessment not found")
+
+// LockOrderVelocity will lock the velocity of the email address and of the address until the end of the transaction,
+// so concurrent orders from the same customer are counted one after the other
+func (db DB) LockOrderVelocity(emailAddress, addressKey string, tx pgx.Tx) error {
+	// the first order from a customer has no rows to lock, so the orders are serialised on their keys instead, taken
+	// in the same order by every transaction so two orders can't each hold the lock the other is waiting for
+	if _, err := tx.Exec(context.Background(), "select pg_advisory_xact_lock(k) from unnest(array[hashtext('email:' || $1), hashtext('address:' || $2)]) k order by k",
+		strings.ToLower(emailAddress), addressKey); err != nil {
+		logError(err, "encountered an issue locking the velocity of the customer")
+		return err
+	}
+
+	return nil
+}
+
+// OrderVelocity will return how many other orders came from the email address and were shipped to the address
+// since the time
+func (db DB) OrderVelocity(orderID uuid.UUID, emailAddress, addressKey string, since time.Time, tx pgx.Tx) (int, int, error) {
+	var byEmail, byAddress int
+	if err := tx.QueryRow(context.Background(), "select count(*) filter (where email_address=$2), count(*) filter (where address_key=$3) from fraud.order_velocity where order_id<>$1 and received_timestamp>=$4",
+		orderID, strings.ToLower(emailAddress), addressKey, since).Scan(&byEmail, &byAddress); err != nil {
+		logError(err, "encountered an issue counting the orders from the customer")
+		return 0, 0, err
+	}
+
+	return byEmail, byAddress, nil
+}
+
+// InsertOrderVelocity will insert a row into the velocity table for an order, so it counts towards the velocity of
+// the orders after it
+func (db DB) InsertOrderVelocity(orderID uuid.UUID, emailAddress, addressKey string, received time.Time, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "insert into fraud.order_velocity (order_id, email_address, address_key, received_timestamp) values ($1, $2, $3, $4) on conflict (order_id) do nothing",
+		orderID, strings.ToLower(emailAddress), addressKey, received); err != nil {
+		logError(err, "encountered an issue inserting the velocity of the order into the DB")
+		return err
+	}
+
+	return nil
+}
+
+// InsertFraudAssessment will insert a row into the assessments table for the fraud score of an order
+func (db DB) InsertFraudAssessment(orderID uuid.UUID, a models.FraudAssessment, tx pgx.Tx) error {
+	reasons, err := json.Marshal(a.Reasons)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), "insert into fraud.assessments (order_id, score, decision, reasons, assessed_timestamp) values ($1, $2, $3, $4, $5)",
+		orderID, a.Score, a.Decision, reasons, time.Now()); err != nil {
+		logError(err, "encountered an issue inserting the fraud assessment into the DB")
+		return err
+	}
+
+	return nil
+}
+
+// GetFraudAssessment will return the fraud score of the order
+func (db DB) GetFraudAssessment(orderID uuid.UUID, tx pgx.Tx) (models.FraudAssessment, error) {
+	var a models.FraudAssessment
+	var reasons []byte
+	if err := tx.QueryRow(context.Background(), "select score, decision, reasons from fraud.assessments where order_id=$1", orderID).
+		Scan(&a.Score, &a.Decision, &reasons); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.FraudAssessment{}, ErrFraudAssessmentNotFound
+		}
+
+		logError(err, "encountered an issue querying for the fraud assessment")
+		return models.FraudAssessment{}, err
+	}
+
+	err := json.Unmarshal(reasons, &a.Reasons)
+
+	return a, err
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// ErrHeldOrderNotFound is returned when an order isn't held for review
+var ErrHeldOrderNotFound = errors.New("held order not found")
+
+// InsertHeldOrder will insert a row into the held orders table for an order held for review by the event with the
+// ID, and record it was held in the audit trail. An order that was already held stays as it is, whether it is still
+// waiting to be reviewed or was approved or rejected, so an event that is delivered again doesn't re-open its review.
+func (db DB) InsertHeldOrder(h models.HeldOrder, eventID uuid.UUID, tx pgx.Tx) error {
+	body, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(context.Background(), "insert into orders.held_orders (order_id, event_id, stage, status, body, held_timestamp) values ($1, $2, $3, $4, $5, $6) "+
+		"on conflict (order_id) do nothing",
+		h.Order.ID, eventID, h.Stage, h.Status, body, h.HeldAt)
+	if err != nil {
+		logError(err, "encountered an issue inserting the held order into the DB")
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	return db.insertHoldAuditEntry(h.Order.ID, models.HoldAuditEntry{
+		Status:     h.Status,
+		Stage:      h.Stage,
+		Note:       strings.Join(h.Reasons, "; "),
+		RecordedAt: h.HeldAt,
+	}, tx)
+}
+
+// ReviewHeldOrder will record the review of a held order, along with its status, in the held orders table and the
+// audit trail
+func (db DB) ReviewHeldOrder(h models.HeldOrder, tx pgx.Tx) error {
+	body, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), "update orders.held_orders set status=$2, body=$3, reviewed_timestamp=$4 where order_id=$1", h.Order.ID, h.Status, body, h.Review.ReviewedAt); err != nil {
+		logError(err, "encountered an issue recording the review of the held order")
+		return err
+	}
+
+	return db.insertHoldAuditEntry(h.Order.ID, models.HoldAuditEntry{
+		Status:     h.Status,
+		Stage:      h.Stage,
+		Operator:   h.Review.Operator,
+		Note:       h.Review.Note,
+		RecordedAt: h.Review.ReviewedAt,
+	}, tx)
+}
+
+// GetHeldOrder will return the held order with the ID
+func (db DB) GetHeldOrder(orderID uuid.UUID, tx pgx.Tx) (models.HeldOrder, error) {
+	h, _, err := queryHeldOrder(tx, "select body, event_id from orders.held_orders where order_id=$1", orderID)
+	return h, err
+}
+
+// LockHeldOrder will return the held order with the ID, along with the ID of the event that held it, locked until
+// the end of the transaction
+func (db DB) LockHeldOrder(orderID uuid.UUID, tx pgx.Tx) (models.HeldOrder, uuid.UUID, error) {
+	return queryHeldOrder(tx, "select body, event_id from orders.held_orders where order_id=$1 for update", orderID)
+}
+
+// ListHeldOrders will return every held order with the status, the ones held longest first
+func (db DB) ListHeldOrders(status models.HoldStatus, tx pgx.Tx) ([]models.HeldOrder, error) {
+	rows, err := tx.Query(context.Background(), "select body from orders.held_orders where status=$1 order by held_timestamp", status)
+	if err != nil {
+		logError(err, "encountered an issue querying for held orders")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var held []models.HeldOrder
+	for rows.Next() {
+		var body []byte
+		if err = rows.Scan(&body); err != nil {
+			return nil, err
+		}
+
+		var h models.HeldOrder
+		if err = json.Unmarshal(body, &h); err != nil {
+			return nil, err
+		}
+		held = append(held, h)
+	}
+
+	return held, rows.Err()
+}
+
+// ListHoldAuditEntries will return the audit trail of the held order, in the order things happened to it
+func (db DB) ListHoldAuditEntries(orderID uuid.UUID, tx pgx.Tx) ([]models.HoldAuditEntry, error) {
+	rows, err := tx.Query(context.Background(), "select status, stage, operator, note, recorded_timestamp from orders.held_order_audit where order_id=$1 order by id", orderID)
+	if err != nil {
+		logError(err, "encountered an issue querying for the audit trail of the held order")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.HoldAuditEntry
+	for rows.Next() {
+		var e models.HoldAuditEntry
+		if err = rows.Scan(&e.Status, &e.Stage, &e.Operator, &e.Note, &e.RecordedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func (db DB) insertHoldAuditEntry(orderID uuid.UUID, e models.HoldAuditEntry, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "insert into orders.held_order_audit (order_id, status, stage, operator, note, recorded_timestamp) values ($1, $2, $3, $4, $5, $6)",
+		orderID, e.Status, e.Stage, e.Operator, e.Note, e.RecordedAt); err != nil {
+		logError(err, "encountered an issue inserting into the audit trail of the held order")
+		return err
+	}
+
+	return nil
+}
+
+func queryHeldOrder(tx pgx.Tx, sql string, args ...interface{}) (models.HeldOrder, uuid.UUID, error) {
+	var body []byte
+	var eventID uuid.UUID
+	if err := tx.QueryRow(context.Background(), sql, args...).Scan(&body, &eventID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.HeldOrder{}, uuid.Nil, ErrHeldOrderNotFound
+		}
+
+		logError(err, "encountered an issue querying for the held order")
+		return models.HeldOrder{}, uuid.Nil, err
+	}
+
+	var h models.HeldOrder
+	err := json.Unmarshal(body, &h)
+
+	return h, eventID, err
+}
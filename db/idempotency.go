@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+// IdempotencyKey represents a request that was made with an Idempotency-Key header, along with the response
+// it got once it has been processed
+type IdempotencyKey struct {
+	Key              string
+	RequestHash      string
+	StatusCode       int // zero while the request is still being processed
+	ContentType      string
+	Body             []byte
+	Partial          bool // the request was only partly processed, so it is processed again when it is made again
+	CreatedTimestamp time.Time
+}
+
+// Completed returns true if the request has been processed and its response stored
+func (k IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
+
+// ReserveIdempotencyKey will insert a row for the key, unless there already is one that was created after the
+// expiry. A row with the response of a partly processed request is reserved again, keeping its response. It returns
+// the row for the key and whether it was reserved by this call.
+func (db DB) ReserveIdempotencyKey(key IdempotencyKey, expiry time.Time, tx pgx.Tx) (IdempotencyKey, bool, error) {
+	var err error
+
+	if _, err = tx.Exec(context.Background(), "delete from orders.idempotency_keys where key=$1 and created_timestamp < $2", key.Key, expiry); err != nil {
+		logError(err, "encountered an issue deleting an expired idempotency key")
+		return IdempotencyKey{}, false, err
+	}
+
+	var inserted string
+	err = tx.QueryRow(context.Background(), "insert into orders.idempotency_keys (key, request_hash, created_timestamp) values ($1, $2, $3) on conflict (key) do nothing returning key", key.Key, key.RequestHash, key.CreatedTimestamp).Scan(&inserted)
+	if err == nil {
+		return key, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		logError(err, "encountered an issue inserting the idempotency key")
+		return IdempotencyKey{}, false, err
+	}
+
+	var existing IdempotencyKey
+	if err = tx.QueryRow(context.Background(), "select key, request_hash, status_code, content_type, body, partial, created_timestamp from orders.idempotency_keys where key=$1 for update", key.Key).
+		Scan(&existing.Key, &existing.RequestHash, &existing.StatusCode, &existing.ContentType, &existing.Body, &existing.Partial, &existing.CreatedTimestamp); err != nil {
+		logError(err, "encountered an issue querying for the idempotency key")
+		return IdempotencyKey{}, false, err
+	}
+
+	if !existing.Partial || !existing.Completed() || existing.RequestHash != key.RequestHash {
+		return existing, false, nil
+	}
+
+	if _, err = tx.Exec(context.Background(), "update orders.idempotency_keys set status_code=0 where key=$1", key.Key); err != nil {
+		logError(err, "encountered an issue reserving the idempotency key again")
+		return IdempotencyKey{}, false, err
+	}
+
+	return existing, true, nil
+}
+
+// CompleteIdempotencyKey will store the response to the request made with the key
+func (db DB) CompleteIdempotencyKey(key IdempotencyKey, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "update orders.idempotency_keys set status_code=$2, content_type=$3, body=$4, partial=$5 where key=$1", key.Key, key.StatusCode, key.ContentType, key.Body, key.Partial); err != nil {
+		logError(err, "encountered an issue storing the response of the idempotency key")
+		return err
+	}
+
+	return nil
+}
+
+// DeleteIdempotencyKey will delete the row for the key, so the request can be made again with it
+func (db DB) DeleteIdempotencyKey(key IdempotencyKey, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "delete from orders.idempotency_keys where key=$1", key.Key); err != nil {
+		logError(err, "encountered an issue deleting the idempotency key")
+		return err
+	}
+
+	return nil
+}
+
+func logError(err error, msg string) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		log.WithField("code", pgErr.Code).
+			WithField("message", pgErr.Message).
+			Error(msg)
+	}
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// uniqueViolation is the Postgres error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+var (
+	// ErrDuplicateOrder is returned when an order with the same external order ID was already received from the sales channel
+	ErrDuplicateOrder = errors.New("an order with this external order ID was already received from the sales channel")
+
+	// ErrOrderNotFound is returned when there is no order matching a lookup
+	ErrOrderNotFound = errors.New("order not found")
+)
+
+// InsertOrder will insert a row into the orders table for an order that was received
+func (db DB) InsertOrder(order models.Order, tx pgx.Tx) error {
+	body, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	// orders without an external order ID aren't constrained, so store null rather than an empty string
+	var externalOrderID *string
+	if len(order.ExternalOrderID) > 0 {
+		externalOrderID = &order.ExternalOrderID
+	}
+
+	if _, err = tx.Exec(context.Background(), "insert into orders.orders (id, sales_channel, external_order_id, body, received_timestamp) values ($1, $2, $3, $4, $5)", order.ID, order.SalesChannel, externalOrderID, body, time.Now()); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return ErrDuplicateOrder
+		}
+
+		logError(err, "encountered an issue inserting the order into the DB")
+		return err
+	}
+
+	return nil
+}
+
+// DeleteOrder will delete the row of an order that couldn't be published
+func (db DB) DeleteOrder(id uuid.UUID, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "delete from orders.orders where id=$1", id); err != nil {
+		logError(err, "encountered an issue deleting the order from the DB")
+		return err
+	}
+
+	return nil
+}
+
+// GetOrder will return the order with the ID
+func (db DB) GetOrder(id uuid.UUID, tx pgx.Tx) (models.Order, error) {
+	return queryOrder(tx, "select body from orders.orders where id=$1", id)
+}
+
+// LockOrder will return the order with the ID, locked until the end of the transaction so changes that depend on
+// it (e.g. its returns) are made one at a time
+func (db DB) LockOrder(id uuid.UUID, tx pgx.Tx) (models.Order, error) {
+	return queryOrder(tx, "select body from orders.orders where id=$1 for update", id)
+}
+
+// FindOrderByExternalID will return the order received from the sales channel with the external order ID
+func (db DB) FindOrderByExternalID(salesChannel, externalOrderID string, tx pgx.Tx) (models.Order, error) {
+	return queryOrder(tx, "select body from orders.orders where sales_channel=$1 and external_order_id=$2", salesChannel, externalOrderID)
+}
+
+func queryOrder(tx pgx.Tx, sql string, args ...interface{}) (models.Order, error) {
+	var body []byte
+	if err := tx.QueryRow(context.Background(), sql, args...).Scan(&body); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Order{}, ErrOrderNotFound
+		}
+
+		logError(err, "encountered an issue querying for the order")
+		return models.Order{}, err
+	}
+
+	var order models.Order
+	err := json.Unmarshal(body, &order)
+
+	return order, err
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+var (
+	// ErrPaymentNotFound is returned when there is no payment for an order
+	ErrPaymentNotFound = errors.New("payment not found")
+
+	// ErrRefundNotFound is returned when there is no refund for a return
+	ErrRefundNotFound = errors.New("refund not found")
+)
+
+// InsertPayment will insert a row into the payments table for the outcome of authorising the payment of an order
+func (db DB) InsertPayment(p models.Payment, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "insert into payments.payments (order_id, status, authorization_id, amount, currency, decline_reason, authorized_timestamp) values ($1, $2, $3, $4, $5, $6, $7)",
+		p.OrderID, p.Status, p.AuthorizationID, int64(p.Amount), p.Currency, p.DeclineReason, time.Now()); err != nil {
+		logError(err, "encountered an issue inserting the payment into the DB")
+		return err
+	}
+
+	return nil
+}
+
+// GetPayment will return the payment of the order
+func (db DB) GetPayment(orderID uuid.UUID, tx pgx.Tx) (models.Payment, error) {
+	var p models.Payment
+	var amount int64
+	if err := tx.QueryRow(context.Background(), "select order_id, status, authorization_id, amount, currency, decline_reason from payments.payments where order_id=$1", orderID).
+		Scan(&p.OrderID, &p.Status, &p.AuthorizationID, &amount, &p.Currency, &p.DeclineReason); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Payment{}, ErrPaymentNotFound
+		}
+
+		logError(err, "encountered an issue querying for the payment")
+		return models.Payment{}, err
+	}
+	p.Amount = models.Money(amount)
+
+	return p, nil
+}
+
+// CapturePayment will record the payment of the order was captured
+func (db DB) CapturePayment(orderID uuid.UUID, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "update payments.payments set status=$2, captured_timestamp=$3 where order_id=$1", orderID, models.PaymentCaptured, time.Now()); err != nil {
+		logError(err, "encountered an issue recording the capture of the payment")
+		return err
+	}
+
+	return nil
+}
+
+// InsertRefund will insert a row into the refunds table for a refund of the payment of an order
+func (db DB) InsertRefund(r models.Refund, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "insert into payments.refunds (return_id, order_id, refund_id, amount, refunded_timestamp) values ($1, $2, $3, $4, $5)",
+		r.ReturnID, r.OrderID, r.RefundID, int64(r.Amount), time.Now()); err != nil {
+		logError(err, "encountered an issue inserting the refund into the DB")
+		return err
+	}
+
+	return nil
+}
+
+// GetRefund will return the refund of the return, in the currency of the payment it was refunded from
+func (db DB) GetRefund(returnID uuid.UUID, tx pgx.Tx) (models.Refund, error) {
+	var r models.Refund
+	var amount int64
+	if err := tx.QueryRow(context.Background(), "select r.return_id, r.order_id, r.refund_id, r.amount, p.currency from payments.refunds r join payments.payments p on p.order_id = r.order_id where r.return_id=$1", returnID).
+		Scan(&r.ReturnID, &r.OrderID, &r.RefundID, &amount, &r.Currency); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Refund{}, ErrRefundNotFound
+		}
+
+		logError(err, "encountered an issue querying for the refund")
+		return models.Refund{}, err
+	}
+	r.Amount = models.Money(amount)
+
+	return r, nil
+}
+
+// RefundedAmount will return how much of the payment of the order has been refunded
+func (db DB) RefundedAmount(orderID uuid.UUID, tx pgx.Tx) (models.Money, error) {
+	var amount int64
+	if err := tx.QueryRow(context.Background(), "select coalesce(sum(amount), 0) from payments.refunds where order_id=$1", orderID).Scan(&amount); err != nil {
+		logError(err, "encountered an issue adding up the refunds of the payment")
+		return 0, err
+	}
+
+	return models.Money(amount), nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// ErrPickQueueEmpty is returned when there is no order waiting to be picked
+var ErrPickQueueEmpty = errors.New("no order is waiting to be picked")
+
+// InsertPick will insert a row into the pick queue for an order waiting to be picked, orders with a higher priority
+// are picked first and orders with the same priority by when they are due. An order that is already queued stays
+// as it is, along with the shipments already picked of it.
+func (db DB) InsertPick(o models.Order, priority int, due time.Time, tx pgx.Tx) error {
+	body, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), "insert into warehouse.pick_queue (order_id, priority, due_timestamp, body, queued_timestamp) values ($1, $2, $3, $4, $5) on conflict (order_id) do nothing",
+		o.ID, priority, due, body, time.Now()); err != nil {
+		logError(err, "encountered an issue inserting the order into the pick queue")
+		return err
+	}
+
+	return nil
+}
+
+// NextPick will return the next order to pick and the shipments already picked of it, locked until the end of the
+// transaction. Orders locked by another transaction are skipped, so several pickers can take orders from the queue
+// at once.
+func (db DB) NextPick(tx pgx.Tx) (models.Order, []models.Shipment, error) {
+	var body, shipments []byte
+	if err := tx.QueryRow(context.Background(), "select body, shipments from warehouse.pick_queue where picked_timestamp is null order by priority desc, due_timestamp, queued_timestamp limit 1 for update skip locked").Scan(&body, &shipments); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Order{}, nil, ErrPickQueueEmpty
+		}
+
+		logError(err, "encountered an issue querying for the next order to pick")
+		return models.Order{}, nil, err
+	}
+
+	var o models.Order
+	if err := json.Unmarshal(body, &o); err != nil {
+		return models.Order{}, nil, err
+	}
+
+	var picked []models.Shipment
+	err := json.Unmarshal(shipments, &picked)
+
+	return o, picked, err
+}
+
+// RecordPick will record the shipments picked and packed of the order, taking it off the pick queue once every
+// product of it has been picked
+func (db DB) RecordPick(orderID uuid.UUID, shipments []models.Shipment, done bool, tx pgx.Tx) error {
+	body, err := json.Marshal(shipments)
+	if err != nil {
+		return err
+	}
+
+	var picked *time.Time
+	if done {
+		now := time.Now()
+		picked = &now
+	}
+
+	if _, err = tx.Exec(context.Background(), "update warehouse.pick_queue set shipments=$2, picked_timestamp=$3 where order_id=$1", orderID, body, picked); err != nil {
+		logError(err, "encountered an issue recording the shipment picked of the order")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+var (
+	// ErrDuplicatePromotion is returned when there already is a promotion with the same code
+	ErrDuplicatePromotion = errors.New("a promotion with this code already exists")
+
+	// ErrPromotionNotFound is returned when there is no promotion with the code
+	ErrPromotionNotFound = errors.New("promotion not found")
+)
+
+// InsertPromotion will insert a row into the promotions table for a new promotion
+func (db DB) InsertPromotion(p models.Promotion, tx pgx.Tx) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), "insert into promotions.promotions (code, body, updated_timestamp) values ($1, $2, $3)", p.Code, body, time.Now()); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return ErrDuplicatePromotion
+		}
+
+		logError(err, "encountered an issue inserting the promotion into the DB")
+		return err
+	}
+
+	return nil
+}
+
+// UpdatePromotion will replace a promotion, the promotion keeps the redemptions it already has
+func (db DB) UpdatePromotion(p models.Promotion, tx pgx.Tx) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(context.Background(), "update promotions.promotions set body=$2, updated_timestamp=$3 where code=$1", p.Code, body, time.Now())
+	if err != nil {
+		logError(err, "encountered an issue updating the promotion in the DB")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPromotionNotFound
+	}
+
+	return nil
+}
+
+// GetPromotion will return the promotion with the code
+func (db DB) GetPromotion(code string, tx pgx.Tx) (models.Promotion, error) {
+	promotions, err := queryPromotions(tx, "select body from promotions.promotions where code=$1", code)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	if len(promotions) == 0 {
+		return models.Promotion{}, ErrPromotionNotFound
+	}
+
+	return promotions[0], nil
+}
+
+// LockPromotion will return the promotion with the code, locking it until the end of the transaction so its
+// redemptions can be counted and added to without another order redeeming it in between
+func (db DB) LockPromotion(code string, tx pgx.Tx) (models.Promotion, error) {
+	promotions, err := queryPromotions(tx, "select body from promotions.promotions where code=$1 for update", code)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	if len(promotions) == 0 {
+		return models.Promotion{}, ErrPromotionNotFound
+	}
+
+	return promotions[0], nil
+}
+
+// ListPromotions will return every promotion, ordered by code
+func (db DB) ListPromotions(tx pgx.Tx) ([]models.Promotion, error) {
+	return queryPromotions(tx, "select body from promotions.promotions order by code")
+}
+
+// Promotions will return the promotions with the codes, by code. Codes without a promotion aren't in the result.
+func (db DB) Promotions(codes []string, tx pgx.Tx) (map[string]models.Promotion, error) {
+	promotions, err := queryPromotions(tx, "select body from promotions.promotions where code = any($1)", codes)
+	if err != nil {
+		return nil, err
+	}
+
+	byCode := make(map[string]models.Promotion, len(promotions))
+	for _, p := range promotions {
+		byCode[p.Code] = p
+	}
+
+	return byCode, nil
+}
+
+// PromotionRedemptions will return how many times the promotion has been redeemed altogether and by the customer
+// with the email address, redemptions that were reversed aren't counted
+func (db DB) PromotionRedemptions(code, emailAddress string, tx pgx.Tx) (int, int, error) {
+	var redeemed, redeemedByCustomer int
+	if err := tx.QueryRow(context.Background(), "select count(*), count(*) filter (where customer_email=$2) from promotions.redemptions where promotion_code=$1 and reversed_timestamp is null", code, strings.ToLower(emailAddress)).
+		Scan(&redeemed, &redeemedByCustomer); err != nil {
+		logError(err, "encountered an issue counting the redemptions of the promotion")
+		return 0, 0, err
+	}
+
+	return redeemed, redeemedByCustomer, nil
+}
+
+// InsertPromotionRedemption will record the promotion was redeemed by an order. An order redeems a promotion once,
+// recording it again does nothing.
+func (db DB) InsertPromotionRedemption(code string, orderID uuid.UUID, emailAddress string, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "insert into promotions.redemptions (order_id, promotion_code, customer_email, redeemed_timestamp) values ($1, $2, $3, $4) on conflict (order_id) do nothing", orderID, code, strings.ToLower(emailAddress), time.Now()); err != nil {
+		logError(err, "encountered an issue inserting the redemption of the promotion")
+		return err
+	}
+
+	return nil
+}
+
+// DeletePromotionRedemption will delete the redemption of a promotion by an order that couldn't be published
+func (db DB) DeletePromotionRedemption(orderID uuid.UUID, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "delete from promotions.redemptions where order_id=$1", orderID); err != nil {
+		logError(err, "encountered an issue deleting the redemption of the promotion")
+		return err
+	}
+
+	return nil
+}
+
+// ReversePromotionRedemption will reverse the redemption of a promotion by an order, so it no longer counts towards
+// the usage limits of the promotion. It returns false if the order has no redemption that wasn't already reversed.
+func (db DB) ReversePromotionRedemption(orderID uuid.UUID, tx pgx.Tx) (bool, error) {
+	tag, err := tx.Exec(context.Background(), "update promotions.redemptions set reversed_timestamp=$2 where order_id=$1 and reversed_timestamp is null", orderID, time.Now())
+	if err != nil {
+		logError(err, "encountered an issue reversing the redemption of the promotion")
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func queryPromotions(tx pgx.Tx, sql string, args ...interface{}) ([]models.Promotion, error) {
+	rows, err := tx.Query(context.Background(), sql, args...)
+	if err != nil {
+		logError(err, "encountered an issue querying for promotions")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promotions []models.Promotion
+	for rows.Next() {
+		var body []byte
+		if err = rows.Scan(&body); err != nil {
+			return nil, err
+		}
+
+		var p models.Promotion
+		if err = json.Unmarshal(body, &p); err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, p)
+	}
+
+	return promotions, rows.Err()
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// ErrReturnNotFound is returned when an order has no return with the ID
+var ErrReturnNotFound = errors.New("return not found")
+
+// InsertReturn will insert a row into the returns table for a return that was authorised
+func (db DB) InsertReturn(r models.Return, tx pgx.Tx) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), "insert into orders.returns (id, order_id, status, body, authorized_timestamp) values ($1, $2, $3, $4, $5)", r.ID, r.OrderID, r.Status, body, time.Now()); err != nil {
+		logError(err, "encountered an issue inserting the return into the DB")
+		return err
+	}
+
+	return nil
+}
+
+// ReceiveReturn will record the products of the return were received back
+func (db DB) ReceiveReturn(r models.Return, tx pgx.Tx) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), "update orders.returns set status=$2, body=$3, received_timestamp=$4 where id=$1", r.ID, r.Status, body, time.Now()); err != nil {
+		logError(err, "encountered an issue recording the return was received")
+		return err
+	}
+
+	return nil
+}
+
+// ReopenReturn will record the return as it was before it was received, so it can be scanned again
+func (db DB) ReopenReturn(r models.Return, tx pgx.Tx) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), "update orders.returns set status=$2, body=$3, received_timestamp=null where id=$1", r.ID, r.Status, body); err != nil {
+		logError(err, "encountered an issue reopening the return")
+		return err
+	}
+
+	return nil
+}
+
+// GetReturn will return the return of the order with the ID
+func (db DB) GetReturn(orderID, id uuid.UUID, tx pgx.Tx) (models.Return, error) {
+	return queryReturn(tx, "select body from orders.returns where order_id=$1 and id=$2", orderID, id)
+}
+
+// LockReturn will return the return of the order with the ID, locked until the end of the transaction
+func (db DB) LockReturn(orderID, id uuid.UUID, tx pgx.Tx) (models.Return, error) {
+	return queryReturn(tx, "select body from orders.returns where order_id=$1 and id=$2 for update", orderID, id)
+}
+
+// ListReturns will return every return of the order, in the order they were authorised
+func (db DB) ListReturns(orderID uuid.UUID, tx pgx.Tx) ([]models.Return, error) {
+	rows, err := tx.Query(context.Background(), "select body from orders.returns where order_id=$1 order by authorized_timestamp", orderID)
+	if err != nil {
+		logError(err, "encountered an issue querying for the returns")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var returns []models.Return
+	for rows.Next() {
+		var body []byte
+		if err = rows.Scan(&body); err != nil {
+			return nil, err
+		}
+
+		var r models.Return
+		if err = json.Unmarshal(body, &r); err != nil {
+			return nil, err
+		}
+		returns = append(returns, r)
+	}
+
+	return returns, rows.Err()
+}
+
+func queryReturn(tx pgx.Tx, sql string, args ...interface{}) (models.Return, error) {
+	var body []byte
+	if err := tx.QueryRow(context.Background(), sql, args...).Scan(&body); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Return{}, ErrReturnNotFound
+		}
+
+		logError(err, "encountered an issue querying for the return")
+		return models.Return{}, err
+	}
+
+	var r models.Return
+	err := json.Unmarshal(body, &r)
+
+	return r, err
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+var (
+	// ErrBookingNotFound is returned when a shipment hasn't been booked with a carrier yet
+	ErrBookingNotFound = errors.New("booking not found")
+
+	// ErrShipmentNotFound is returned when there is no shipment with the ID
+	ErrShipmentNotFound = errors.New("shipment not found")
+)
+
+// InsertBooking will insert a row into the bookings table for the carrier, service and cost chosen for a shipment
+// before it's handed to the carrier. A shipment that is already booked stays with the carrier it was booked with.
+func (db DB) InsertBooking(s models.Shipment, tx pgx.Tx) error {
+	if _, err := tx.Exec(context.Background(), "insert into shipping.bookings (shipment_id, order_id, carrier, service, cost, currency, booked_timestamp) values ($1, $2, $3, $4, $5, $6, $7) on conflict (shipment_id) do nothing",
+		s.ID, s.OrderID, s.Carrier, s.Service, int64(s.Cost), s.Currency, time.Now()); err != nil {
+		logError(err, "encountered an issue inserting the booking of the shipment into the DB")
+		return err
+	}
+
+	return nil
+}
+
+// GetBooking will return the shipment with the carrier, service and cost it was booked with
+func (db DB) GetBooking(shipmentID uuid.UUID, tx pgx.Tx) (models.Shipment, error) {
+	var s models.Shipment
+	var cost int64
+	if err := tx.QueryRow(context.Background(), "select shipment_id, order_id, carrier, service, cost, currency from shipping.bookings where shipment_id=$1", shipmentID).
+		Scan(&s.ID, &s.OrderID, &s.Carrier, &s.Service, &cost, &s.Currency); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Shipment{}, ErrBookingNotFound
+		}
+
+		logError(err, "encountered an issue querying for the booking of the shipment")
+		return models.Shipment{}, err
+	}
+	s.Cost = models.Money(cost)
+
+	return s, nil
+}
+
+// InsertShipment will insert a row into the shipments table for a shipment that was handed to the carrier
+func (db DB) InsertShipment(s models.Shipment, tx pgx.Tx) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), "insert into shipping.shipments (id, order_id, tracking_number, body, shipped_timestamp) values ($1, $2, $3, $4, $5)",
+		s.ID, s.OrderID, s.TrackingNumber, body, s.ShippedAt); err != nil {
+		logError(err, "encountered an issue inserting the shipment into the DB")
+		return err
+	}
+
+	return nil
+}
+
+// GetShipment will return the shipment with the ID
+func (db DB) GetShipment(id uuid.UUID, tx pgx.Tx) (models.Shipment, error) {
+	var body []byte
+	if err := tx.QueryRow(context.Background(), "select body from shipping.shipments where id=$1", id).Scan(&body); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Shipment{}, ErrShipmentNotFound
+		}
+
+		logError(err, "encountered an issue querying for the shipment")
+		return models.Shipment{}, err
+	}
+
+	var s models.Shipment
+	err := json.Unmarshal(body, &s)
+
+	return s, err
+}
+
+// ListShipments will return every shipment of the order, in the order they shipped. The shipments of the order are
+// locked until the end of the transaction, so two shipments of the same order can't both ship the rest of it.
+func (db DB) ListShipments(orderID uuid.UUID, tx pgx.Tx) ([]models.Shipment, error) {
+	// there are no rows to lock before the first shipment of an order, so its shipments are serialised on its ID instead
+	if _, err := tx.Exec(context.Background(), "select pg_advisory_xact_lock(hashtext($1))", orderID.String()); err != nil {
+		logError(err, "encountered an issue locking the shipments of the order")
+		return nil, err
+	}
+
+	rows, err := tx.Query(context.Background(), "select body from shipping.shipments where order_id=$1 order by shipped_timestamp", orderID)
+	if err != nil {
+		logError(err, "encountered an issue querying for the shipments of the order")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shipments []models.Shipment
+	for rows.Next() {
+		var body []byte
+		if err = rows.Scan(&body); err != nil {
+			return nil, err
+		}
+
+		var s models.Shipment
+		if err = json.Unmarshal(body, &s); err != nil {
+			return nil, err
+		}
+		shipments = append(shipments, s)
+	}
+
+	return shipments, rows.Err()
+}
@@ -0,0 +1,212 @@
+package dispatcher
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	log "github.com/sirupsen/logrus"
+)
+
+// queueSize is how many messages a partition worker can have waiting before dispatching blocks the consumer
+const queueSize = 100
+
+// seekTimeoutMs is how long to wait for a paused partition to be rewound to the message that isn't due yet
+const seekTimeoutMs = 1000
+
+// maxWait is the longest a message is waited for on the polling goroutine when its partition can't be paused, well
+// below the default max.poll.interval.ms of 5 minutes
+const maxWait = 30 * time.Second
+
+// Dispatcher hands messages to a worker per topic partition, so messages from the same partition are
+// processed in order while different partitions are processed concurrently. When it isn't concurrent
+// every message is processed on the goroutine that dispatched it.
+//
+// A message that isn't due yet isn't waited for, since the consumer is considered failed if it isn't polled for
+// longer than max.poll.interval.ms. Its partition is paused and rewound to it instead, and resumed by Resume once
+// the message is due, so it is read again then.
+//
+// Dispatch, Resume, Drain and Close are expected to be called from the goroutine polling the consumer.
+type Dispatcher struct {
+	consumer   *kafka.Consumer
+	concurrent bool
+	wait       func(*kafka.Message) time.Duration
+	handle     func(*kafka.Message)
+	workers    map[string]*worker
+	paused     map[string]pausedPartition
+}
+
+type worker struct {
+	msgs chan *kafka.Message
+	wg   sync.WaitGroup
+}
+
+// pausedPartition is a partition that was paused until a message from it is due
+type pausedPartition struct {
+	partition kafka.TopicPartition
+	due       time.Time
+}
+
+// New creates a dispatcher for the consumer that calls handle for every message dispatched, once wait says it is due
+func New(kc *kafka.Consumer, concurrent bool, wait func(*kafka.Message) time.Duration, handle func(*kafka.Message)) *Dispatcher {
+	return &Dispatcher{
+		consumer:   kc,
+		concurrent: concurrent,
+		wait:       wait,
+		handle:     handle,
+		workers:    make(map[string]*worker),
+		paused:     make(map[string]pausedPartition),
+	}
+}
+
+// Dispatch hands the message to the worker for its partition, starting the worker if there isn't one yet. A message
+// that isn't due yet pauses its partition instead.
+func (d *Dispatcher) Dispatch(msg *kafka.Message) {
+	k := key(msg.TopicPartition)
+
+	// messages fetched before their partition was paused are read again once it is resumed
+	if _, found := d.paused[k]; found {
+		return
+	}
+
+	if wait := d.wait(msg); wait > 0 && d.pause(msg, wait) {
+		return
+	}
+
+	if !d.concurrent {
+		d.handle(msg)
+		return
+	}
+
+	w, found := d.workers[k]
+	if !found {
+		log.WithField("partition", k).Info("starting partition worker")
+
+		w = &worker{msgs: make(chan *kafka.Message, queueSize)}
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for msg := range w.msgs {
+				d.handle(msg)
+			}
+		}()
+
+		d.workers[k] = w
+	}
+
+	w.msgs <- msg
+}
+
+// pause pauses the partition of a message that isn't due yet and rewinds it to the message, returning false if the
+// partition couldn't be paused, in which case the message has been waited for instead, for up to maxWait
+func (d *Dispatcher) pause(msg *kafka.Message, wait time.Duration) bool {
+	k := key(msg.TopicPartition)
+	partition := kafka.TopicPartition{Topic: msg.TopicPartition.Topic, Partition: msg.TopicPartition.Partition}
+
+	err := d.consumer.Pause([]kafka.TopicPartition{partition})
+	if err == nil {
+		if err = d.consumer.Seek(msg.TopicPartition, seekTimeoutMs); err != nil {
+			if resumeErr := d.consumer.Resume([]kafka.TopicPartition{partition}); resumeErr != nil {
+				log.WithField("partition", k).
+					WithField("error", resumeErr).
+					Error("an issue occurred trying to resume the partition")
+			}
+		}
+	}
+	if err != nil {
+		log.WithField("partition", k).
+			WithField("wait", wait.String()).
+			WithField("error", err).
+			Error("unable to pause the partition until the message is due, waiting for it instead")
+
+		if wait > maxWait {
+			wait = maxWait
+		}
+		time.Sleep(wait)
+
+		return false
+	}
+
+	log.WithField("partition", k).
+		WithField("wait", wait.String()).
+		Info("pausing the partition until the message is due")
+
+	d.paused[k] = pausedPartition{partition: partition, due: time.Now().Add(wait)}
+	return true
+}
+
+// Resume resumes the partitions that were paused until a message was due, once it is. It should be called every
+// time the consumer is polled.
+func (d *Dispatcher) Resume() {
+	now := time.Now()
+	for k, p := range d.paused {
+		if now.Before(p.due) {
+			continue
+		}
+
+		if err := d.consumer.Resume([]kafka.TopicPartition{p.partition}); err != nil {
+			log.WithField("partition", k).
+				WithField("error", err).
+				Error("an issue occurred trying to resume the partition")
+
+			continue
+		}
+
+		log.WithField("partition", k).Info("resuming the partition")
+		delete(d.paused, k)
+	}
+}
+
+// Drain stops the workers of the specified partitions, waiting for them to finish the messages they were
+// already given, and forgets they were paused. It should be called before the partitions are revoked from the consumer.
+func (d *Dispatcher) Drain(partitions []kafka.TopicPartition) {
+	for _, tp := range partitions {
+		k := key(tp)
+		delete(d.paused, k)
+
+		w, found := d.workers[k]
+		if !found {
+			continue
+		}
+
+		log.WithField("partition", k).Info("draining partition worker")
+
+		close(w.msgs)
+		w.wg.Wait()
+		delete(d.workers, k)
+	}
+}
+
+// Close stops every worker, waiting for them to finish the messages they were already given
+func (d *Dispatcher) Close() {
+	for k, w := range d.workers {
+		log.WithField("partition", k).Info("draining partition worker")
+
+		close(w.msgs)
+		w.wg.Wait()
+		delete(d.workers, k)
+	}
+}
+
+// Rebalance is a rebalance callback that drains the workers of partitions that are being revoked
+func (d *Dispatcher) Rebalance(_ *kafka.Consumer, event kafka.Event) error {
+	switch e := event.(type) {
+	case kafka.AssignedPartitions:
+		log.WithField("partitions", e.Partitions).Info("partitions assigned")
+	case kafka.RevokedPartitions:
+		log.WithField("partitions", e.Partitions).Info("partitions revoked")
+		d.Drain(e.Partitions)
+	}
+
+	return nil
+}
+
+func key(tp kafka.TopicPartition) string {
+	var topic string
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+
+	return fmt.Sprintf("%s[%d]", topic, tp.Partition)
+}
@@ -0,0 +1,32 @@
+package email
+
+import (
+	"fmt"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// Amount returns the amount as a line of an email labelled with the label, e.g. <div>Total: 12.34 USD</div>, or
+// nothing if there is no currency, since orders received before they were priced have none
+func Amount(label string, amount models.Money, currency string) string {
+	if len(currency) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("<div>%s: %s</div>", label, amount.Format(currency))
+}
+
+// Totals returns the totals of an order as the lines of an email, the discount only if there is one and labelled
+// with the promotion code it came from
+func Totals(t models.Totals, currency, promotionCode string) string {
+	var discount string
+	if t.Discount > 0 {
+		discount = Amount(fmt.Sprintf("Discount (%s)", promotionCode), -t.Discount, currency)
+	}
+
+	return Amount("Subtotal", t.Subtotal, currency) +
+		discount +
+		Amount("Shipping", t.Shipping, currency) +
+		Amount("Tax", t.Tax, currency) +
+		Amount("Total", t.Total, currency)
+}
@@ -0,0 +1,44 @@
+package email
+
+import (
+	"testing"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+func TestTotals(t *testing.T) {
+	tests := []struct {
+		name          string
+		totals        models.Totals
+		currency      string
+		promotionCode string
+		want          string
+	}{
+		{
+			name:     "without a discount",
+			totals:   models.Totals{Subtotal: 2598, Shipping: 599, Tax: 188, Total: 3385},
+			currency: "USD",
+			want:     "<div>Subtotal: 25.98 USD</div><div>Shipping: 5.99 USD</div><div>Tax: 1.88 USD</div><div>Total: 33.85 USD</div>",
+		},
+		{
+			name:          "with a discount",
+			totals:        models.Totals{Subtotal: 2598, Discount: 260, Shipping: 599, Tax: 169, Total: 3106},
+			currency:      "USD",
+			promotionCode: "SAVE10",
+			want:          "<div>Subtotal: 25.98 USD</div><div>Discount (SAVE10): -2.60 USD</div><div>Shipping: 5.99 USD</div><div>Tax: 1.69 USD</div><div>Total: 31.06 USD</div>",
+		},
+		{
+			name:   "received before orders were priced",
+			totals: models.Totals{},
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Totals(tt.totals, tt.currency, tt.promotionCode); got != tt.want {
+				t.Errorf("Totals() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
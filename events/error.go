@@ -3,6 +3,7 @@ package events
 import (
 	"time"
 
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
 	"github.com/google/uuid"
 )
 
@@ -10,6 +11,7 @@ import (
 type Error struct {
 	EventBase BaseEvent
 	EventBody Event
+	Cause     models.Error
 }
 
 // ID returns the unique identifier of the event
@@ -31,3 +33,12 @@ func (e Error) Timestamp() time.Time {
 func (e Error) Body() interface{} {
 	return e.EventBody
 }
+
+// Key returns the key of the event that caused the error, if it has one
+func (e Error) Key() string {
+	if keyed, ok := e.EventBody.(Keyed); ok {
+		return keyed.Key()
+	}
+
+	return ""
+}
@@ -0,0 +1,40 @@
+package events
+
+import (
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/google/uuid"
+)
+
+// FraudCheckPassed represents an order that passed the fraud check, so its payment can be authorised. The fraud
+// assessment of the order is set.
+type FraudCheckPassed struct {
+	EventBase BaseEvent
+	EventBody models.Order
+}
+
+// ID returns the unique identifier of the event
+func (fp FraudCheckPassed) ID() uuid.UUID {
+	return fp.EventBase.EventID
+}
+
+// Name returns the name of the event
+func (fp FraudCheckPassed) Name() string {
+	return "FraudCheckPassed"
+}
+
+// Timestamp returns the unique timestamp of the event
+func (fp FraudCheckPassed) Timestamp() time.Time {
+	return fp.EventBase.EventTimestamp
+}
+
+// Body returns the body content of the event
+func (fp FraudCheckPassed) Body() interface{} {
+	return fp.EventBody
+}
+
+// Key returns the ID of the order, so all events for an order are published to the same partition
+func (fp FraudCheckPassed) Key() string {
+	return fp.EventBody.ID.String()
+}
@@ -0,0 +1,7 @@
+package events
+
+// Keyed is implemented by events that should be published with a message key. Events with the same key are
+// published to the same partition, so they are consumed in the order they were published.
+type Keyed interface {
+	Key() string
+}
@@ -32,3 +32,8 @@ func (n Notification) Timestamp() time.Time {
 func (n Notification) Body() interface{} {
 	return n.EventBody
 }
+
+// Key returns the recipient of the notification, so all events for a recipient are published to the same partition
+func (n Notification) Key() string {
+	return n.EventBody.Recipient
+}
@@ -32,3 +32,8 @@ func (or OrderConfirmed) Timestamp() time.Time {
 func (or OrderConfirmed) Body() interface{} {
 	return or.EventBody
 }
+
+// Key returns the ID of the order, so all events for an order are published to the same partition
+func (or OrderConfirmed) Key() string {
+	return or.EventBody.ID.String()
+}
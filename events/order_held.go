@@ -0,0 +1,40 @@
+package events
+
+import (
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/google/uuid"
+)
+
+// OrderHeld represents an order a stage of the pipeline held for someone to review, it goes no further until someone
+// reviews it.
+type OrderHeld struct {
+	EventBase BaseEvent
+	EventBody models.HeldOrder
+}
+
+// ID returns the unique identifier of the event
+func (oh OrderHeld) ID() uuid.UUID {
+	return oh.EventBase.EventID
+}
+
+// Name returns the name of the event
+func (oh OrderHeld) Name() string {
+	return "OrderHeld"
+}
+
+// Timestamp returns the unique timestamp of the event
+func (oh OrderHeld) Timestamp() time.Time {
+	return oh.EventBase.EventTimestamp
+}
+
+// Body returns the body content of the event
+func (oh OrderHeld) Body() interface{} {
+	return oh.EventBody
+}
+
+// Key returns the ID of the order, so all events for an order are published to the same partition
+func (oh OrderHeld) Key() string {
+	return oh.EventBody.Order.ID.String()
+}
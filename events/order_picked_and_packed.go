@@ -32,3 +32,8 @@ func (n OrderPickedAndPacked) Timestamp() time.Time {
 func (n OrderPickedAndPacked) Body() interface{} {
 	return n.EventBody
 }
+
+// Key returns the ID of the order, so all events for an order are published to the same partition
+func (n OrderPickedAndPacked) Key() string {
+	return n.EventBody.ID.String()
+}
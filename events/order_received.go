@@ -32,3 +32,8 @@ func (or OrderReceived) Timestamp() time.Time {
 func (or OrderReceived) Body() interface{} {
 	return or.EventBody
 }
+
+// Key returns the ID of the order, so all events for an order are published to the same partition
+func (or OrderReceived) Key() string {
+	return or.EventBody.ID.String()
+}
@@ -0,0 +1,39 @@
+package events
+
+import (
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/google/uuid"
+)
+
+// OrderShipped represents an order that was handed over to the carrier, so its payment can be captured
+type OrderShipped struct {
+	EventBase BaseEvent
+	EventBody models.Order
+}
+
+// ID returns the unique identifier of the event
+func (os OrderShipped) ID() uuid.UUID {
+	return os.EventBase.EventID
+}
+
+// Name returns the name of the event
+func (os OrderShipped) Name() string {
+	return "OrderShipped"
+}
+
+// Timestamp returns the unique timestamp of the event
+func (os OrderShipped) Timestamp() time.Time {
+	return os.EventBase.EventTimestamp
+}
+
+// Body returns the body content of the event
+func (os OrderShipped) Body() interface{} {
+	return os.EventBody
+}
+
+// Key returns the ID of the order, so all events for an order are published to the same partition
+func (os OrderShipped) Key() string {
+	return os.EventBody.ID.String()
+}
@@ -45,3 +45,14 @@ func TranslateToOrderTimeMetricEvent(ot metrics.OrderTime) Event {
 
 	return event
 }
+
+// Key returns the ID of the order the metric was recorded for, if it was tagged with one
+func (otm OrderTimeMetric) Key() string {
+	for _, tag := range otm.EventBody.Tags {
+		if tag.Name == "order_id" {
+			return tag.Value
+		}
+	}
+
+	return ""
+}
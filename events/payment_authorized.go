@@ -0,0 +1,39 @@
+package events
+
+import (
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/google/uuid"
+)
+
+// PaymentAuthorized represents an order whose payment was authorised, so it can be fulfilled. The payment of the order is set.
+type PaymentAuthorized struct {
+	EventBase BaseEvent
+	EventBody models.Order
+}
+
+// ID returns the unique identifier of the event
+func (pa PaymentAuthorized) ID() uuid.UUID {
+	return pa.EventBase.EventID
+}
+
+// Name returns the name of the event
+func (pa PaymentAuthorized) Name() string {
+	return "PaymentAuthorized"
+}
+
+// Timestamp returns the unique timestamp of the event
+func (pa PaymentAuthorized) Timestamp() time.Time {
+	return pa.EventBase.EventTimestamp
+}
+
+// Body returns the body content of the event
+func (pa PaymentAuthorized) Body() interface{} {
+	return pa.EventBody
+}
+
+// Key returns the ID of the order, so all events for an order are published to the same partition
+func (pa PaymentAuthorized) Key() string {
+	return pa.EventBody.ID.String()
+}
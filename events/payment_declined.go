@@ -0,0 +1,39 @@
+package events
+
+import (
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/google/uuid"
+)
+
+// PaymentDeclined represents an order whose payment was declined, so it won't be fulfilled. The payment of the order is set.
+type PaymentDeclined struct {
+	EventBase BaseEvent
+	EventBody models.Order
+}
+
+// ID returns the unique identifier of the event
+func (pd PaymentDeclined) ID() uuid.UUID {
+	return pd.EventBase.EventID
+}
+
+// Name returns the name of the event
+func (pd PaymentDeclined) Name() string {
+	return "PaymentDeclined"
+}
+
+// Timestamp returns the unique timestamp of the event
+func (pd PaymentDeclined) Timestamp() time.Time {
+	return pd.EventBase.EventTimestamp
+}
+
+// Body returns the body content of the event
+func (pd PaymentDeclined) Body() interface{} {
+	return pd.EventBody
+}
+
+// Key returns the ID of the order, so all events for an order are published to the same partition
+func (pd PaymentDeclined) Key() string {
+	return pd.EventBody.ID.String()
+}
@@ -0,0 +1,44 @@
+package events
+
+import (
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/google/uuid"
+)
+
+// Rejection represents an event that a consumer decided not to process because of a business rule
+type Rejection struct {
+	EventBase BaseEvent
+	EventBody models.Rejection
+}
+
+// ID returns the unique identifier of the event
+func (r Rejection) ID() uuid.UUID {
+	return r.EventBase.EventID
+}
+
+// Name returns the name of the event
+func (r Rejection) Name() string {
+	return "Rejection"
+}
+
+// Timestamp returns the unique timestamp of the event
+func (r Rejection) Timestamp() time.Time {
+	return r.EventBase.EventTimestamp
+}
+
+// Body returns the body content of the event
+func (r Rejection) Body() interface{} {
+	return r.EventBody
+}
+
+// Key returns the ID of the order that was rejected, so it is published to the same partition as the rest of
+// the events for the order. Rejections that aren't for an order aren't keyed.
+func (r Rejection) Key() string {
+	if r.EventBody.OrderID == uuid.Nil {
+		return ""
+	}
+
+	return r.EventBody.OrderID.String()
+}
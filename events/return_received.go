@@ -0,0 +1,40 @@
+package events
+
+import (
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/google/uuid"
+)
+
+// ReturnReceived represents the products of a return being scanned back in by the warehouse, so they can be restocked
+// and refunded.
+type ReturnReceived struct {
+	EventBase BaseEvent
+	EventBody models.Return
+}
+
+// ID returns the unique identifier of the event
+func (rr ReturnReceived) ID() uuid.UUID {
+	return rr.EventBase.EventID
+}
+
+// Name returns the name of the event
+func (rr ReturnReceived) Name() string {
+	return "ReturnReceived"
+}
+
+// Timestamp returns the unique timestamp of the event
+func (rr ReturnReceived) Timestamp() time.Time {
+	return rr.EventBase.EventTimestamp
+}
+
+// Body returns the body content of the event
+func (rr ReturnReceived) Body() interface{} {
+	return rr.EventBody
+}
+
+// Key returns the ID of the order the products are returned from, so all events for an order are published to the same partition
+func (rr ReturnReceived) Key() string {
+	return rr.EventBody.OrderID.String()
+}
@@ -0,0 +1,98 @@
+package events
+
+import (
+	"fmt"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+func init() {
+	// version 2 of the events about an order fills in the defaults the order service gives orders that don't
+	// specify them, orders received before the fields existed were published without them
+	for _, name := range []string{
+		OrderReceived{}.Name(),
+		FraudCheckPassed{}.Name(),
+		OrderConfirmed{}.Name(),
+		OrderPickedAndPacked{}.Name(),
+		PaymentAuthorized{}.Name(),
+		PaymentDeclined{}.Name(),
+		OrderShipped{}.Name(),
+	} {
+		RegisterUpcaster(name, 1, func(event map[string]interface{}) error {
+			return orderDefaults(event["EventBody"])
+		})
+	}
+
+	RegisterUpcaster(OrderHeld{}.Name(), 1, func(event map[string]interface{}) error {
+		body, err := object(event["EventBody"], "EventBody")
+		if err != nil {
+			return err
+		}
+
+		return orderDefaults(body["order"])
+	})
+
+	// version 2 of ReturnReceived fills in the country of the addresses of its customer
+	RegisterUpcaster(ReturnReceived{}.Name(), 1, func(event map[string]interface{}) error {
+		body, err := object(event["EventBody"], "EventBody")
+		if err != nil {
+			return err
+		}
+
+		return customerDefaults(body["customer"])
+	})
+}
+
+// orderDefaults fills in the sales channel, service level and countries of an order that don't have one
+func orderDefaults(value interface{}) error {
+	order, err := object(value, "order")
+	if err != nil || order == nil {
+		return err
+	}
+
+	setDefault(order, "salesChannel", models.DefaultSalesChannel)
+	setDefault(order, "serviceLevel", string(models.StandardShipping))
+
+	return customerDefaults(order["customer"])
+}
+
+// customerDefaults fills in the country of the addresses of a customer that don't have one
+func customerDefaults(value interface{}) error {
+	customer, err := object(value, "customer")
+	if err != nil || customer == nil {
+		return err
+	}
+
+	for _, name := range []string{"shippingAddress", "billingAddress"} {
+		address, err := object(customer[name], name)
+		if err != nil {
+			return err
+		}
+		if address != nil {
+			setDefault(address, "country", models.DefaultCountry)
+		}
+	}
+
+	return nil
+}
+
+// object returns the JSON value as an object, nil if it's missing or null
+func object(value interface{}, name string) (map[string]interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	o, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s should be an object", name)
+	}
+
+	return o, nil
+}
+
+// setDefault sets the field of the object to the value, unless it has one
+func setDefault(o map[string]interface{}, field, value string) {
+	if s, _ := o[field].(string); len(s) == 0 {
+		o[field] = value
+	}
+}
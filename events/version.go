@@ -0,0 +1,71 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Upcaster transforms an event published with one version of its schema into the next version. The event is
+// in its legacy JSON representation, e.g. {"EventBase":{...},"EventBody":{...}}, whatever format it was
+// published in, and is changed in place.
+type Upcaster func(event map[string]interface{}) error
+
+var (
+	upcasters   = make(map[string][]Upcaster)
+	upcastersMu sync.RWMutex
+)
+
+// RegisterUpcaster registers the upcaster transforming version from of the event into version from+1, which
+// becomes the current version of the event. Upcasters have to be registered in order starting from version 1,
+// usually from an init function next to the event.
+func RegisterUpcaster(name string, from int, up Upcaster) {
+	upcastersMu.Lock()
+	defer upcastersMu.Unlock()
+
+	if expected := len(upcasters[name]) + 1; from != expected {
+		panic(fmt.Sprintf("the next upcaster of %s should be from version %d, not %d", name, expected, from))
+	}
+
+	upcasters[name] = append(upcasters[name], up)
+}
+
+// SchemaVersion returns the current version of the schema of the event, every event starts at version 1
+func SchemaVersion(name string) int {
+	upcastersMu.RLock()
+	defer upcastersMu.RUnlock()
+
+	return len(upcasters[name]) + 1
+}
+
+// Upcast runs the value of an event published with the specified version of its schema through every upcaster
+// up to the current version. Values published with the current version, or a newer one, are returned as they are.
+func Upcast(name string, version int, value []byte) ([]byte, error) {
+	upcastersMu.RLock()
+	chain := upcasters[name]
+	upcastersMu.RUnlock()
+
+	if version < 1 {
+		version = 1
+	}
+	if version > len(chain) {
+		return value, nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(value))
+	d.UseNumber()
+
+	var event map[string]interface{}
+	if err := d.Decode(&event); err != nil {
+		return nil, err
+	}
+
+	for v := version; v <= len(chain); v++ {
+		if err := chain[v-1](event); err != nil {
+			return nil, fmt.Errorf("could not upcast %s from version %d to %d: %w", name, v, v+1, err)
+		}
+	}
+
+	return json.Marshal(event)
+}
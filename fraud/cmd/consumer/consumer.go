@@ -0,0 +1,335 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/codec"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/dispatcher"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/fraud/internal/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/fraud/internal/scoring"
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Consumer represents the subscription to the specified Kafka topics
+type Consumer struct {
+	Broker string
+	Group  string
+	Topics []string
+	Rules  scoring.Rules
+}
+
+// pollTimeout is how long to wait for a message before checking if the consumer should shut down
+const pollTimeout = 500 * time.Millisecond
+
+// consumerName is the name the events this consumer processed are recorded under, so another service consuming the
+// same events still processes them
+const consumerName = "fraud"
+
+// SubscribeAndListen will subscribe to the Kafka topics and start polling and listening for events until the
+// context is cancelled. The message being processed when the context is cancelled is finished and committed
+// before the consumer leaves the group.
+// Adpated from https://github.com/confluentinc/confluent-kafka-go#examples
+func (c *Consumer) SubscribeAndListen(ctx context.Context) error {
+
+	kc, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":     c.Broker,
+		"broker.address.family": "v4",
+		"group.id":              c.Group + "-fraud",
+		"session.timeout.ms":    6000,
+		"enable.auto.commit":    false,
+		"auto.offset.reset":     "earliest"})
+
+	if err != nil {
+		log.WithField("error", err).Error("Failed to create consumer")
+
+		return err
+	}
+
+	log.WithField("consumer", kc).Info("Created Consumer")
+
+	pool, err := db.NewDB().ConnectPool(ctx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
+		kc.Close()
+
+		return err
+	}
+
+	defer func() {
+		log.Info("closing connection pool to database")
+		pool.Close()
+	}()
+
+	// each message is committed by the worker that processed it, once it has been handled. Retried messages that
+	// aren't due yet pause their retry topic partition until they are, rather than holding up the poll.
+	d := dispatcher.New(kc, config.PartitionWorkers(), hdlr.RetryWait, func(msg *kafka.Message) {
+		c.handleMessage(pool, msg)
+		commitMessage(kc, msg)
+	})
+
+	var topics []string
+	for _, topic := range c.Topics {
+		topics = append(topics, topic, config.RetryTopicName(topic))
+	}
+
+	if err = kc.SubscribeTopics(topics, d.Rebalance); err != nil {
+		log.WithField("error", err).
+			WithField("topics", c.Topics).
+			Error("Failed to subscribe to topics")
+		kc.Close()
+
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Warn("Closing consumer...")
+			d.Close()
+
+			return kc.Close()
+		default:
+		}
+
+		d.Resume()
+
+		msg, err := kc.ReadMessage(pollTimeout)
+		if err != nil {
+			if kerr, ok := err.(kafka.Error); ok && kerr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+
+			// The client will automatically try to recover from all errors.
+			log.WithField("error", err).Error(msg)
+
+			log.Warn("Closing consumer...")
+			d.Close()
+			kc.Close()
+
+			return err
+		}
+
+		log.WithField("topic", msg.TopicPartition).Info(string(msg.Value))
+
+		d.Dispatch(msg)
+	}
+}
+
+// commitMessage will commit the offset of a message once it has been handled one way or another
+func commitMessage(kc *kafka.Consumer, msg *kafka.Message) {
+	if _, err := kc.CommitMessage(msg); err != nil {
+		log.WithField("error", err).
+			WithField("topic", msg.TopicPartition).
+			Error("an issue occurred trying to commit the offset of the message")
+	}
+}
+
+// handleMessage will route a message to the handler for the event it holds, based on its headers. Messages
+// without an event name header were published before headers were added, so they are assumed to hold an OrderReceived.
+func (c *Consumer) handleMessage(pool *pgxpool.Pool, msg *kafka.Message) {
+	ctx := headers.NewContext(context.Background(), headers.FromMessage(msg))
+
+	switch name := headers.Get(msg.Headers, headers.EventName); name {
+	case "", events.OrderReceived{}.Name():
+		c.handleOrderReceived(ctx, pool, msg)
+	default:
+		log.WithField("event.name", name).
+			WithField("topic", msg.TopicPartition).
+			Debug("skipping an event this consumer doesn't handle")
+	}
+}
+
+// handleOrderReceived will score a single OrderReceived message for fraud and pass it on, hold it for review or
+// reject it, every failure is handed off to be retried or dead lettered
+func (c *Consumer) handleOrderReceived(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
+
+	var event events.OrderReceived
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
+
+	var order models.Order
+	if order, err = extractOrder(event); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to extract order information from the order recieved event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	var assessment models.FraudAssessment
+	if err = hdlr.Retry(func() (err error) {
+		assessment, err = c.scoreOrder(pool, event, order)
+		return err
+	}); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+	order.Fraud = &assessment
+
+	// rejected orders go to the Rejections topic like those rejected by any other stage
+	if assessment.Decision == models.FraudReject {
+		hdlr.HandleRejection(event, fmt.Errorf("order [%s] was rejected with a fraud score of %d: %s", order.ID, assessment.Score, strings.Join(assessment.Reasons, ", ")))
+		return
+	}
+
+	if err = hdlr.Retry(func() error { return publishFraudEvent(ctx, order) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to publish a fraud event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+}
+
+func extractOrder(event events.OrderReceived) (models.Order, error) {
+	log.Info("attempting to extract order from event")
+
+	body := event.Body()
+	order, ok := body.(models.Order)
+	if !ok {
+		return models.Order{}, hdlr.NewPermanentError(errors.New("event body can't be cast as an order"))
+	}
+
+	return order, nil
+}
+
+// scoreOrder scores the order for fraud, counting the other orders from the customer within the velocity window
+// before it was received, unless the event was already processed, in which case the assessment it got is returned so
+// the outcome can be published again. The order counts towards the velocity of later orders whatever its outcome.
+func (c *Consumer) scoreOrder(pool *pgxpool.Pool, event events.Event, order models.Order) (assessment models.FraudAssessment, err error) {
+	fraud := db.NewDB()
+
+	// begin a transaction
+	tx, err := pool.Begin(context.Background())
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to start a database transaction")
+		return assessment, err
+	}
+
+	defer func() {
+		if err != nil {
+			log.Info("rolling back DB transaction")
+			if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+				log.WithField("error", rbErr).Error("an issue occurred trying to roll back the transaction")
+			}
+
+			return
+		}
+
+		log.Info("committing DB transaction")
+		if err = tx.Commit(context.Background()); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to commit the transaction")
+		}
+	}()
+
+	// check to see if event has already been processed
+	eventAlreadyProcessed, err := fraud.EventExists(consumerName, event, tx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to check if an event was already processed")
+		return assessment, err
+	}
+
+	// if event has already been processed, the assessment is already stored
+	if eventAlreadyProcessed {
+		log.WithField("event.id", event.ID()).
+			WithField("event.name", event.Name()).
+			Info("event was processed previously")
+
+		return fraud.GetFraudAssessment(order.ID, tx)
+	}
+
+	// event hasn't been processed yet, score the order
+	email := strings.ToLower(order.Customer.EmailAddress)
+	address := scoring.AddressKey(order.Customer.ShippingAddress)
+	received := event.Timestamp()
+
+	if err = fraud.LockOrderVelocity(email, address, tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to lock the velocity of the customer")
+		return assessment, err
+	}
+
+	var v scoring.Velocity
+	if v.OrdersByEmail, v.OrdersByAddress, err = fraud.OrderVelocity(order.ID, email, address, received.Add(-c.Rules.VelocityWindow), tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to count the orders from the customer")
+		return assessment, err
+	}
+
+	if assessment, err = handlers.ScoreOrder(c.Rules, order, v); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to score the order")
+		return assessment, err
+	}
+
+	if err = fraud.InsertOrderVelocity(order.ID, email, address, received, tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to insert the velocity of the order")
+		return assessment, err
+	}
+
+	if err = fraud.InsertFraudAssessment(order.ID, assessment, tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to insert the fraud assessment")
+		return assessment, err
+	}
+
+	// mark the event as processed
+	if err = fraud.InsertEvent(consumerName, event, tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to insert the event")
+		return assessment, err
+	}
+
+	return assessment, nil
+}
+
+// publishFraudEvent publishes the order with its fraud assessment, as a FraudCheckPassed event if it passed or an
+// OrderHeld event if it was held for review
+func publishFraudEvent(ctx context.Context, o models.Order) error {
+	base := events.BaseEvent{
+		EventID:        uuid.New(),
+		EventTimestamp: time.Now(),
+	}
+
+	var e events.Event
+	topic := config.FraudCheckPassedTopicName
+	if o.Fraud.Decision == models.FraudHold {
+		e = events.OrderHeld{
+			EventBase: base,
+			EventBody: models.HeldOrder{
+				Order:   o,
+				Stage:   models.FraudStage,
+				Reasons: o.Fraud.Reasons,
+				HeldAt:  base.EventTimestamp,
+			},
+		}
+		topic = config.OrderHeldTopicName
+	} else {
+		e = events.FraudCheckPassed{EventBase: base, EventBody: o}
+	}
+
+	log.WithField("event", e).Info("transformed order to event")
+
+	if err := publisher.PublishEvent(e, topic, publisher.WithContext(ctx)); err != nil {
+		return err
+	}
+
+	log.WithField("event", e).Info("published event")
+
+	return nil
+}
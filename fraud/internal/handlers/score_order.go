@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/fraud/internal/scoring"
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/google/uuid"
+)
+
+// ScoreOrder will score an order for fraud given how many other orders came from the same customer recently, and
+// decide whether it passes, is held for review or is rejected. An order without an ID is a permanent error.
+func ScoreOrder(rules scoring.Rules, order models.Order, v scoring.Velocity) (models.FraudAssessment, error) {
+	if order.ID == uuid.Nil {
+		return models.FraudAssessment{}, hdlr.NewPermanentError(errors.New("an order without an ID can't be scored for fraud"))
+	}
+
+	a := rules.Score(order, v)
+
+	log.WithField("order.id", order.ID).
+		WithField("fraud.score", a.Score).
+		WithField("fraud.decision", a.Decision).
+		WithField("fraud.reasons", a.Reasons).
+		Info("scored order for fraud")
+
+	return a, nil
+}
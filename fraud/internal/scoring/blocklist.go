@@ -0,0 +1,65 @@
+package scoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// Blocklist is the email addresses, email domains and shipping postal codes orders can't come from
+type Blocklist struct {
+	Emails       []string `json:"emails,omitempty"`
+	EmailDomains []string `json:"emailDomains,omitempty"`
+	PostalCodes  []string `json:"postalCodes,omitempty"`
+}
+
+// Email returns true if the email address, or its domain, is blocked
+func (b Blocklist) Email(email string) bool {
+	_, domain, _ := strings.Cut(email, "@")
+	for _, blocked := range b.Emails {
+		if strings.EqualFold(blocked, email) {
+			return true
+		}
+	}
+	for _, blocked := range b.EmailDomains {
+		if strings.EqualFold(blocked, domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PostalCode returns true if the postal code is blocked
+func (b Blocklist) PostalCode(code string) bool {
+	for _, blocked := range b.PostalCodes {
+		if models.PostalCode(blocked) == models.PostalCode(code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadBlocklist reads the blocklist from a JSON file holding an object with its lists, nothing is blocked if the
+// file isn't specified
+func LoadBlocklist(file string) (Blocklist, error) {
+	if len(file) == 0 {
+		return Blocklist{}, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Blocklist{}, err
+	}
+
+	var b Blocklist
+	if err = json.Unmarshal(data, &b); err != nil {
+		return Blocklist{}, fmt.Errorf("unable to read the fraud blocklist in %s: %w", file, err)
+	}
+
+	return b, nil
+}
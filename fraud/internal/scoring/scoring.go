@@ -0,0 +1,121 @@
+package scoring
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// The score each rule adds when an order breaks it
+const (
+	blockedScore            = 100
+	emailVelocityScore      = 40
+	addressVelocityScore    = 40
+	quantityScore           = 30
+	countryMismatchScore    = 50
+	postalCodeMismatchScore = 20
+)
+
+// Velocity is how many other orders came from the email address and were shipped to the shipping address of an
+// order within the velocity window before it
+type Velocity struct {
+	OrdersByEmail   int
+	OrdersByAddress int
+}
+
+// Rules scores orders for fraud. An order scoring at least the hold score is held for review, and at least the
+// reject score is rejected.
+type Rules struct {
+	HoldScore           int
+	RejectScore         int
+	VelocityWindow      time.Duration
+	MaxOrdersPerEmail   int
+	MaxOrdersPerAddress int
+	MaxQuantity         int
+	Blocklist           Blocklist
+}
+
+// New returns the rules configured by the FRAUD_ environment variables
+func New() (Rules, error) {
+	blocklist, err := LoadBlocklist(config.FraudBlocklistFile())
+	if err != nil {
+		return Rules{}, err
+	}
+
+	rules := Rules{
+		HoldScore:           config.FraudHoldScore(),
+		RejectScore:         config.FraudRejectScore(),
+		VelocityWindow:      config.FraudVelocityWindow(),
+		MaxOrdersPerEmail:   config.FraudMaxOrdersPerEmail(),
+		MaxOrdersPerAddress: config.FraudMaxOrdersPerAddress(),
+		MaxQuantity:         config.FraudMaxQuantity(),
+		Blocklist:           blocklist,
+	}
+
+	if rules.HoldScore <= 0 || rules.RejectScore < rules.HoldScore {
+		return Rules{}, fmt.Errorf("%s should be greater than zero and no more than %s", config.FraudHoldScoreEnvVar, config.FraudRejectScoreEnvVar)
+	}
+
+	return rules, nil
+}
+
+// Score assesses the order given its velocity, adding up the score of every rule it breaks
+func (r Rules) Score(o models.Order, v Velocity) models.FraudAssessment {
+	var a models.FraudAssessment
+	add := func(score int, reason string) {
+		a.Score += score
+		a.Reasons = append(a.Reasons, reason)
+	}
+
+	customer := o.Customer
+	if r.Blocklist.Email(customer.EmailAddress) {
+		add(blockedScore, "the email address is blocked")
+	}
+	if r.Blocklist.PostalCode(customer.ShippingAddress.PostalCode) {
+		add(blockedScore, "the shipping postal code is blocked")
+	}
+
+	if r.MaxOrdersPerEmail > 0 && v.OrdersByEmail >= r.MaxOrdersPerEmail {
+		add(emailVelocityScore, fmt.Sprintf("%d other orders came from the email address in the last %.0f minutes", v.OrdersByEmail, r.VelocityWindow.Minutes()))
+	}
+	if r.MaxOrdersPerAddress > 0 && v.OrdersByAddress >= r.MaxOrdersPerAddress {
+		add(addressVelocityScore, fmt.Sprintf("%d other orders were shipped to the address in the last %.0f minutes", v.OrdersByAddress, r.VelocityWindow.Minutes()))
+	}
+
+	for _, p := range o.Products {
+		if r.MaxQuantity > 0 && p.Quantity > r.MaxQuantity {
+			add(quantityScore, fmt.Sprintf("%d of product [%s] is over the limit of %d", p.Quantity, p.ProductCode, r.MaxQuantity))
+		}
+	}
+
+	if billing := customer.BillingAddress; billing != nil {
+		switch {
+		case !strings.EqualFold(billing.Country, customer.ShippingAddress.Country):
+			add(countryMismatchScore, "the billing address is in another country than the shipping address")
+		case models.PostalCode(billing.PostalCode) != models.PostalCode(customer.ShippingAddress.PostalCode):
+			add(postalCodeMismatchScore, "the billing postal code doesn't match the shipping postal code")
+		}
+	}
+
+	switch {
+	case a.Score >= r.RejectScore:
+		a.Decision = models.FraudReject
+	case a.Score >= r.HoldScore:
+		a.Decision = models.FraudHold
+	default:
+		a.Decision = models.FraudPass
+	}
+
+	return a
+}
+
+// AddressKey identifies an address for counting the orders shipped to it, so the same address written slightly
+// differently counts as one
+func AddressKey(a models.Address) string {
+	line1 := strings.Join(strings.Fields(strings.ToLower(a.Line1)), " ")
+
+	return strings.ToUpper(a.Country) + "|" + models.PostalCode(a.PostalCode) + "|" + line1
+}
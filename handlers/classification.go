@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrorClass describes how a failure to process an event should be handled
+type ErrorClass string
+
+const (
+	// Retryable failures are transient (e.g. the database or broker is unavailable) and the event should be tried again
+	Retryable ErrorClass = "retryable"
+
+	// Permanent failures will never succeed no matter how many times they are tried (e.g. the payload is invalid)
+	Permanent ErrorClass = "permanent"
+
+	// Rejected failures are business decisions not to process the event (e.g. the order can't be shipped)
+	Rejected ErrorClass = "rejected"
+)
+
+// ClassifiedError is an error that knows how the consumer should handle it
+type ClassifiedError struct {
+	Class ErrorClass
+	Err   error
+}
+
+// Error returns the message of the underlying error
+func (ce ClassifiedError) Error() string {
+	return ce.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (ce ClassifiedError) Unwrap() error {
+	return ce.Err
+}
+
+// NewRetryableError marks an error as transient, processing the event again may succeed
+func NewRetryableError(err error) error {
+	return ClassifiedError{Class: Retryable, Err: err}
+}
+
+// NewPermanentError marks an error as permanent, the event will never be processed successfully
+func NewPermanentError(err error) error {
+	return ClassifiedError{Class: Permanent, Err: err}
+}
+
+// NewRejectedError marks an error as a business rejection of the event
+func NewRejectedError(err error) error {
+	return ClassifiedError{Class: Rejected, Err: err}
+}
+
+// Classify returns the class of the error. Errors that haven't been classified are assumed to be retryable,
+// since they mostly come from the database or the broker.
+func Classify(err error) ErrorClass {
+	var ce ClassifiedError
+	if errors.As(err, &ce) {
+		return ce.Class
+	}
+
+	return Retryable
+}
+
+// Retry will call fn until it succeeds, returns an error that isn't retryable or runs out of attempts,
+// backing off between each attempt
+func Retry(fn func() error) error {
+	backoff := config.RetryBackoff()
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || Classify(err) != Retryable || attempt >= config.RetryAttempts() {
+			return err
+		}
+
+		log.WithField("error", err).
+			WithField("attempt", attempt+1).
+			WithField("backoff", backoff.String()).
+			Warn("a retryable error occurred, retrying")
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -1,34 +1,149 @@
 package handlers
 
 import (
+	"errors"
+	"strconv"
 	"time"
 
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
 
+	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
+// HandleFailure will decide what to do with an event that failed to be processed based on the class of the error:
+// retryable events go to the retry topic, rejected events publish a rejection and everything else goes to the DLQ
+func HandleFailure(event events.Event, msg *kafka.Message, err error) {
+	switch Classify(err) {
+	case Retryable:
+		HandleRetry(event, msg, err)
+	case Rejected:
+		HandleRejection(event, err)
+	default:
+		HandleError(event, err)
+	}
+}
+
+// HandleRetry will publish the message to the retry topic of the topic it was consumed from, or to the DLQ
+// once it has been retried too many times
+func HandleRetry(event events.Event, msg *kafka.Message, err error) {
+	attempt := retryAttempt(msg) + 1
+	if attempt > config.RetryTopicAttempts() {
+		log.WithField("event.id", event.ID()).
+			WithField("attempts", attempt-1).
+			Warn("event has been retried too many times")
+
+		HandleError(event, err)
+		return
+	}
+
+	// the message is republished as it is, keeping its key and headers, apart from counting the attempt
+	topic := config.RetryTopicName(*msg.TopicPartition.Topic)
+	hs := headers.Set(append([]kafka.Header(nil), msg.Headers...), headers.RetryAttempt, strconv.Itoa(attempt))
+	if pubErr := publisher.PublishMessage(msg.Value, topic, publisher.WithKey(string(msg.Key)), publisher.WithHeaders(hs...)); pubErr != nil {
+		log.WithField("error", pubErr).
+			WithField("topic", topic).
+			Error("an issue ocurred publishing an event to the retry topic")
+
+		HandleError(event, errors.Join(err, pubErr))
+	}
+}
+
+// RetryWait returns how long until a message from a retry topic is due to be processed again, backing off longer on
+// every trip through the retry topic so a failing event isn't consumed again straight away. Messages that aren't being
+// retried, or are already due, don't have to wait.
+func RetryWait(msg *kafka.Message) time.Duration {
+	attempt := retryAttempt(msg)
+	if attempt == 0 || msg.TimestampType == kafka.TimestampNotAvailable {
+		return 0
+	}
+
+	backoff := config.RetryTopicBackoff() << (attempt - 1)
+	if wait := time.Until(msg.Timestamp.Add(backoff)); wait > 0 {
+		return wait
+	}
+
+	return 0
+}
+
+// HandleRejection will publish a rejection event to Kafka
+func HandleRejection(event events.Event, err error) {
+	rejection := models.Rejection{
+		EventID:   event.ID(),
+		EventName: event.Name(),
+		Reason:    err.Error(),
+	}
+
+	if order, ok := event.Body().(models.Order); ok {
+		rejection.OrderID = order.ID
+	}
+
+	e := events.Rejection{
+		EventBase: events.BaseEvent{
+			EventID:        uuid.New(),
+			EventTimestamp: time.Now(),
+		},
+		EventBody: rejection,
+	}
+
+	if pubErr := publisher.PublishEvent(e, config.RejectionsTopicName); pubErr != nil {
+		log.WithField("error", pubErr).
+			WithField("topic", config.RejectionsTopicName).
+			Error("an issue ocurred publishing a rejection event to Kafka")
+
+		HandleError(event, errors.Join(err, pubErr))
+	}
+}
+
 // HandleError will publish an error event to Kafka
-func HandleError(event events.Event) {
+func HandleError(event events.Event, err error) {
+	cause := models.NewError(err)
+	cause.Class = string(Classify(err))
+
+	publishError(translateToErrorEvent(event, cause))
+}
+
+// HandleUnreadableMessage will publish an error event to Kafka for a message that couldn't be decoded into an event
+func HandleUnreadableMessage(msg *kafka.Message, err error) {
+	cause := models.NewError(err)
+	cause.Class = string(Permanent)
+	cause.Topic = *msg.TopicPartition.Topic
+	cause.Payload = string(msg.Value)
+
+	publishError(translateToErrorEvent(nil, cause), publisher.WithKey(string(msg.Key)))
+}
+
+func publishError(e events.Event, opts ...publisher.Option) {
 	var err error
 
-	e := translateToErrorEvent(event)
-	if err = publisher.PublishEvent(e, config.ErrorsTopicName); err != nil {
+	if err = publisher.PublishEvent(e, config.ErrorsTopicName, opts...); err != nil {
 		log.WithField("error", err).
 			WithField("topic", config.ErrorsTopicName).
 			Error("an issue ocurred publishing an error event to Kafka")
 	}
 }
 
-func translateToErrorEvent(event events.Event) events.Event {
+func translateToErrorEvent(event events.Event, cause models.Error) events.Event {
 	return events.Error{
 		EventBase: events.BaseEvent{
 			EventID:        uuid.New(),
 			EventTimestamp: time.Now(),
 		},
 		EventBody: event,
+		Cause:     cause,
 	}
 }
+
+func retryAttempt(msg *kafka.Message) int {
+	attempt, err := strconv.Atoi(headers.Get(msg.Headers, headers.RetryAttempt))
+	if err != nil {
+		return 0
+	}
+
+	return attempt
+}
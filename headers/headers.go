@@ -0,0 +1,103 @@
+package headers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	// EventName is the header holding the name of the event in the message, consumers use it to route a
+	// message before decoding it
+	EventName = "event-name"
+
+	// SchemaVersion is the header holding the version of the schema the event was encoded with
+	SchemaVersion = "schema-version"
+
+	// ContentType is the header holding the media type of the message value
+	ContentType = "content-type"
+
+	// CorrelationID is the header holding the ID that correlates every message published for the same request
+	CorrelationID = "correlation-id"
+
+	// Producer is the header holding the name of the service that published the message
+	Producer = "producer"
+
+	// TraceParent is the header holding the W3C trace context of the message
+	TraceParent = "traceparent"
+
+	// RetryAttempt is the header counting how many times a message went through a retry topic
+	RetryAttempt = "retry-attempt"
+)
+
+// Metadata is the information carried from a message (or request) to the messages published while handling it
+type Metadata struct {
+	CorrelationID string
+	TraceParent   string
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the metadata
+func NewContext(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, contextKey{}, md)
+}
+
+// FromContext returns the metadata carried by the context, if there is any
+func FromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(contextKey{}).(Metadata)
+	return md, ok
+}
+
+// FromMessage returns the metadata in the headers of a message
+func FromMessage(msg *kafka.Message) Metadata {
+	return Metadata{
+		CorrelationID: Get(msg.Headers, CorrelationID),
+		TraceParent:   Get(msg.Headers, TraceParent),
+	}
+}
+
+// Get returns the value of the header with the key, or an empty string if there isn't one
+func Get(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
+
+// Set returns the headers with the value of the header with the key replaced, or added if there isn't one
+func Set(headers []kafka.Header, key, value string) []kafka.Header {
+	for i, h := range headers {
+		if h.Key == key {
+			headers[i].Value = []byte(value)
+			return headers
+		}
+	}
+
+	return append(headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+// ChildTraceParent returns a W3C trace context for a new span in the same trace as the parent, or for a new
+// trace if the parent isn't a valid trace context
+func ChildTraceParent(parent string) string {
+	// version-traceid-parentid-flags
+	parts := strings.Split(parent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "00-" + randomHex(16) + "-" + randomHex(8) + "-01"
+	}
+
+	return parts[0] + "-" + parts[1] + "-" + randomHex(8) + "-" + parts[3]
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
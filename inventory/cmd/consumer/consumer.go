@@ -2,14 +2,16 @@ package consumer
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"time"
 
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/codec"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/dispatcher"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
 	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/inventory/internal/handlers"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
@@ -17,24 +19,35 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/jackc/pgx/v4/pgxpool"
 )
 
-// Consumer represents the subscription to a specified Kafka topic
+// Consumer represents the subscription to the specified Kafka topics
 type Consumer struct {
 	Broker string
 	Group  string
-	Topic  string
+	Topics []string
 }
 
-// SubscribeAndListen will subscribe to a Kafka topic and start polling and listening for events
+// pollTimeout is how long to wait for a message before checking if the consumer should shut down
+const pollTimeout = 500 * time.Millisecond
+
+// consumerName is the name the events this consumer processed are recorded under, so another service consuming the
+// same events still processes them
+const consumerName = "inventory"
+
+// SubscribeAndListen will subscribe to the Kafka topics and start polling and listening for events until the
+// context is cancelled. The message being processed when the context is cancelled is finished and committed
+// before the consumer leaves the group.
 // Adpated from https://github.com/confluentinc/confluent-kafka-go#examples
-func (c *Consumer) SubscribeAndListen() error {
+func (c *Consumer) SubscribeAndListen(ctx context.Context) error {
 
 	kc, err := kafka.NewConsumer(&kafka.ConfigMap{
 		"bootstrap.servers":     c.Broker,
 		"broker.address.family": "v4",
 		"group.id":              c.Group + "-inventory",
 		"session.timeout.ms":    6000,
+		"enable.auto.commit":    false,
 		"auto.offset.reset":     "earliest"})
 
 	if err != nil {
@@ -45,21 +58,63 @@ func (c *Consumer) SubscribeAndListen() error {
 
 	log.WithField("consumer", kc).Info("Created Consumer")
 
-	if err = kc.SubscribeTopics([]string{c.Topic}, nil); err != nil {
+	pool, err := db.NewDB().ConnectPool(ctx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
+		kc.Close()
+
+		return err
+	}
+
+	defer func() {
+		log.Info("closing connection pool to database")
+		pool.Close()
+	}()
+
+	// each message is committed by the worker that processed it, once it has been handled. Retried messages that
+	// aren't due yet pause their retry topic partition until they are, rather than holding up the poll.
+	d := dispatcher.New(kc, config.PartitionWorkers(), hdlr.RetryWait, func(msg *kafka.Message) {
+		handleMessage(pool, msg)
+		commitMessage(kc, msg)
+	})
+
+	var topics []string
+	for _, topic := range c.Topics {
+		topics = append(topics, topic, config.RetryTopicName(topic))
+	}
+
+	if err = kc.SubscribeTopics(topics, d.Rebalance); err != nil {
 		log.WithField("error", err).
-			WithField("topic", c.Topic).
-			Error("Failed to subscribe to topic")
+			WithField("topics", c.Topics).
+			Error("Failed to subscribe to topics")
+		kc.Close()
 
 		return err
 	}
 
 	for {
-		msg, err := kc.ReadMessage(-1)
+		select {
+		case <-ctx.Done():
+			log.Warn("Closing consumer...")
+			d.Close()
+
+			return kc.Close()
+		default:
+		}
+
+		d.Resume()
+
+		msg, err := kc.ReadMessage(pollTimeout)
 		if err != nil {
+			if kerr, ok := err.(kafka.Error); ok && kerr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+
 			// The client will automatically try to recover from all errors.
 			log.WithField("error", err).Error(msg)
 
 			log.Warn("Closing consumer...")
+			d.Close()
 			kc.Close()
 
 			return err
@@ -67,78 +122,136 @@ func (c *Consumer) SubscribeAndListen() error {
 
 		log.WithField("topic", msg.TopicPartition).Info(string(msg.Value))
 
-		var event events.OrderReceived
-		if err = json.Unmarshal([]byte(string(msg.Value)), &event); err != nil {
-			log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+		d.Dispatch(msg)
+	}
+}
 
-			continue
-		}
+// commitMessage will commit the offset of a message once it has been handled one way or another
+func commitMessage(kc *kafka.Consumer, msg *kafka.Message) {
+	if _, err := kc.CommitMessage(msg); err != nil {
+		log.WithField("error", err).
+			WithField("topic", msg.TopicPartition).
+			Error("an issue occurred trying to commit the offset of the message")
+	}
+}
 
-		var order models.Order
-		if order, err = extractOrder(event); err != nil {
-			log.WithField("error", err).Error("an issue occurred trying to extract order information from the order recieved event")
+// handleMessage will route a message to the handler for the event it holds, based on its headers. Orders are
+// confirmed once their payment is authorised, and returned products are restocked once they are received.
+func handleMessage(pool *pgxpool.Pool, msg *kafka.Message) {
+	ctx := headers.NewContext(context.Background(), headers.FromMessage(msg))
+
+	switch name := headers.Get(msg.Headers, headers.EventName); name {
+	case events.PaymentAuthorized{}.Name():
+		handlePaymentAuthorized(ctx, pool, msg)
+	case events.ReturnReceived{}.Name():
+		handleReturnReceived(ctx, pool, msg)
+	default:
+		log.WithField("event.name", name).
+			WithField("topic", msg.TopicPartition).
+			Debug("skipping an event this consumer doesn't handle")
+	}
+}
 
-			hdlr.HandleError(event)
-			continue
-		}
+// handlePaymentAuthorized will process a single PaymentAuthorized message, every failure is handed off to be retried or dead lettered
+func handlePaymentAuthorized(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
 
-		if err = processEvent(event, order); err != nil {
-			log.WithField("error", err).Error("an issue occurred trying to process the event")
+	var event events.PaymentAuthorized
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
 
-			hdlr.HandleError(event)
-			continue
-		}
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
 
-		if err = publishOrderConfirmedEvent(order); err != nil {
-			log.WithField("error", err).Error("an issue occurred trying to publish an order confirmed event")
+	var order models.Order
+	if order, err = extractOrder(event); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to extract order information from the payment authorized event")
 
-			hdlr.HandleError(event)
-			continue
-		}
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	if err = hdlr.Retry(func() error {
+		return processEvent(pool, event, func() error { return handlers.DecrementInventory(order) })
+	}); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	if err = hdlr.Retry(func() error { return publishOrderConfirmedEvent(ctx, order) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to publish an order confirmed event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
 	}
 }
 
-func extractOrder(event events.OrderReceived) (models.Order, error) {
+// handleReturnReceived will process a single ReturnReceived message, every failure is handed off to be retried or dead lettered
+func handleReturnReceived(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
+
+	var event events.ReturnReceived
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
+
+	if err = hdlr.Retry(func() error {
+		return processEvent(pool, event, func() error { return handlers.RestockInventory(event.EventBody) })
+	}); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+}
+
+func extractOrder(event events.PaymentAuthorized) (models.Order, error) {
 	log.Info("attempting to extract order from event")
 
 	body := event.Body()
 	order, ok := body.(models.Order)
 	if !ok {
-		return models.Order{}, errors.New("event body can't be cast as an order")
+		return models.Order{}, hdlr.NewPermanentError(errors.New("event body can't be cast as an order"))
 	}
 
 	return order, nil
 }
 
-func processEvent(event events.Event, order models.Order) error {
-	var err error
-
+// processEvent updates the inventory for an event with the update, unless the event was already processed
+func processEvent(pool *pgxpool.Pool, event events.Event, update func() error) (err error) {
 	db := db.NewDB()
-	conn, err := db.Connect()
-	if err != nil {
-		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
-		return err
-	}
 
 	// begin a transaction
-	tx, err := conn.Begin(context.Background())
+	tx, err := pool.Begin(context.Background())
 	if err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to start a database transaction")
 		return err
 	}
 
 	defer func() {
+		if err != nil {
+			log.Info("rolling back DB transaction")
+			if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+				log.WithField("error", rbErr).Error("an issue occurred trying to roll back the transaction")
+			}
+
+			return
+		}
+
 		log.Info("committing DB transaction")
 		if err = tx.Commit(context.Background()); err != nil {
 			log.WithField("error", err).Error("an issue occurred trying to commit the transaction")
 		}
-
-		log.Info("closing connection to database")
-		conn.Close(context.Background())
 	}()
 
 	// check to see if event has already been processed
-	eventAlreadyProcessed, err := db.EventExists(event, tx)
+	eventAlreadyProcessed, err := db.EventExists(consumerName, event, tx)
 	if err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to check if an event was already processed")
 		return err
@@ -153,15 +266,15 @@ func processEvent(event events.Event, order models.Order) error {
 		return nil
 	}
 
-	// event hasn't been processed yet, decrement the inventory
-	if err = handlers.DecrementInventory(order); err != nil {
-		log.WithField("error", err).Error("an issue occurred trying to decrement the inventory")
+	// event hasn't been processed yet, update the inventory
+	if err = update(); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to update the inventory")
 
 		return err
 	}
 
 	// mark the event as processed
-	if err = db.InsertEvent(event, tx); err != nil {
+	if err = db.InsertEvent(consumerName, event, tx); err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to insert the event")
 		return err
 	}
@@ -169,14 +282,14 @@ func processEvent(event events.Event, order models.Order) error {
 	return nil
 }
 
-func publishOrderConfirmedEvent(o models.Order) error {
+func publishOrderConfirmedEvent(ctx context.Context, o models.Order) error {
 	// publish an order confirmed event
 	e := translateOrderToEvent(o)
 
 	log.WithField("event", e).Info("transformed order to event")
 
 	var err error
-	if err = publisher.PublishEvent(e, config.OrderConfirmedTopicName); err != nil {
+	if err = publisher.PublishEvent(e, config.OrderConfirmedTopicName, publisher.WithContext(ctx)); err != nil {
 		return err
 	}
 
@@ -1,15 +1,25 @@
 package handlers
 
 import (
+	"fmt"
+
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
 	log "github.com/sirupsen/logrus"
 )
 
-// DecrementInventory will decrement the inventory of produts by the specific quantity in the order
+// DecrementInventory will decrement the inventory of produts by the specific quantity in the order.
+// An order with lines that can't be decremented is a permanent error.
 func DecrementInventory(order models.Order) error {
 	log.WithField("order.id", order.ID).
 		Info("attempting to decrement inventory from order")
 
+	for i, p := range order.Products {
+		if len(p.ProductCode) == 0 || p.Quantity <= 0 {
+			return hdlr.NewPermanentError(fmt.Errorf("product [%d] in order [%s] has no product code or quantity", i, order.ID))
+		}
+	}
+
 	// We are not actually connecting to an inventory system, so just log it for now
 	for _, p := range order.Products {
 		log.WithField("order.id", order.ID).
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"fmt"
+
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// RestockInventory will increment the inventory of products by the quantity received back in a return.
+// A return with lines that can't be restocked is a permanent error.
+func RestockInventory(ret models.Return) error {
+	log.WithField("return.id", ret.ID).
+		Info("attempting to restock inventory from return")
+
+	for i, l := range ret.Lines {
+		if len(l.ProductCode) == 0 || l.Quantity <= 0 {
+			return hdlr.NewPermanentError(fmt.Errorf("line [%d] in return [%s] has no product code or quantity", i, ret.ID))
+		}
+	}
+
+	// We are not actually connecting to an inventory system, so just log it for now
+	for _, l := range ret.Lines {
+		log.WithField("order.id", ret.OrderID).
+			WithField("return.id", ret.ID).
+			WithField("product.code", l.ProductCode).
+			WithField("product.quantity", l.Quantity).
+			Info("restocking inventory for product")
+	}
+
+	return nil
+}
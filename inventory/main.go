@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/inventory/cmd/consumer"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -21,25 +23,36 @@ func init() {
 
 	// Only log the warning severity or above.
 	log.SetLevel(config.LogLevel())
+
+	// Identify this service as the producer of the events it publishes
+	publisher.ProducerName = "inventory"
 }
 
 func main() {
 	startTime := time.Now()
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		sig := <-sigs
 		log.WithField("uptime", time.Since(startTime).String()).
 			WithField("signal", sig.String()).
-			Error("interrupt signal detected")
-		os.Exit(0)
+			Warn("interrupt signal detected, shutting down")
+		cancel()
 	}()
 
 	c := consumer.Consumer{
 		Broker: config.BrokerAddress(),
 		Group:  config.ConsumerGroup(),
-		Topic:  config.OrderReceivedTopicName,
+		Topics: []string{config.PaymentAuthorizedTopicName, config.ReturnReceivedTopicName},
+	}
+
+	if err := c.SubscribeAndListen(ctx); err != nil {
+		log.Fatal(err)
 	}
 
-	log.Fatal(c.SubscribeAndListen())
+	// flush anything still waiting to be delivered before exiting
+	publisher.Close()
+
+	log.WithField("uptime", time.Since(startTime).String()).Info("shutdown complete")
 }
@@ -0,0 +1,23 @@
+package models
+
+// DefaultTaxCategory is the tax category of products that don't specify one
+const DefaultTaxCategory = "standard"
+
+// CatalogueProduct represents a product in the catalogue, only active products can be ordered. The tax category
+// decides which tax rules apply to it, e.g. food or clothing.
+type CatalogueProduct struct {
+	Code        string           `json:"code"`
+	Name        string           `json:"name"`
+	WeightGrams int              `json:"weightGrams"`
+	Dimensions  Dimensions       `json:"dimensions"`
+	TaxCategory string           `json:"taxCategory,omitempty"` // standard if not specified
+	Active      bool             `json:"active"`
+	Prices      map[string]Money `json:"prices"` // unit price by ISO 4217 currency code
+}
+
+// Dimensions are the size of a product once it's packed, in millimetres
+type Dimensions struct {
+	LengthMm int `json:"lengthMm"`
+	WidthMm  int `json:"widthMm"`
+	HeightMm int `json:"heightMm"`
+}
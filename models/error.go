@@ -1,11 +1,18 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // Error represents an error that has occurred in the system
 type Error struct {
 	Message   string
 	Timestamp time.Time
+	Class     string
+	Topic     string `json:",omitempty"`
+	Payload   string `json:",omitempty"` // raw message value, only set when the message couldn't be decoded
 }
 
 // NewError will create a new Error object from a base error
@@ -15,3 +22,11 @@ func NewError(err error) Error {
 		Timestamp: time.Now(),
 	}
 }
+
+// Rejection represents an event that was deliberately not processed because of a business rule
+type Rejection struct {
+	EventID   uuid.UUID `json:"eventId"`
+	EventName string    `json:"eventName"`
+	OrderID   uuid.UUID `json:"orderId,omitempty"`
+	Reason    string    `json:"reason"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// FraudDecision is what happens to an order once it has been scored for fraud
+type FraudDecision string
+
+const (
+	// FraudPass orders go on to have their payment authorised
+	FraudPass FraudDecision = "pass"
+
+	// FraudHold orders are held until someone reviews them
+	FraudHold FraudDecision = "hold"
+
+	// FraudReject orders are rejected, they won't be fulfilled
+	FraudReject FraudDecision = "reject"
+)
+
+// FraudAssessment represents the outcome of scoring an order for fraud. The score is the sum of the scores of every
+// rule the order broke, and the reasons say which rules those were.
+type FraudAssessment struct {
+	Score    int           `json:"score"`
+	Decision FraudDecision `json:"decision"`
+	Reasons  []string      `json:"reasons,omitempty"`
+}
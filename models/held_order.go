@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// FraudStage is the stage of the pipeline that holds orders that might be fraudulent
+const FraudStage = "fraud"
+
+// HoldStatus is where a held order is in its review
+type HoldStatus string
+
+const (
+	// HoldPending orders are waiting for someone to review them
+	HoldPending HoldStatus = "held"
+
+	// HoldApproved orders were reviewed and resumed from the stage that held them
+	HoldApproved HoldStatus = "approved"
+
+	// HoldRejected orders were reviewed and rejected, they go no further
+	HoldRejected HoldStatus = "rejected"
+)
+
+// HeldOrder represents an order a stage of the pipeline held for someone to review, along with why it was held and
+// the review once it has been reviewed
+type HeldOrder struct {
+	Order   Order      `json:"order"`
+	Stage   string     `json:"stage"` // the stage that held the order, e.g. fraud
+	Reasons []string   `json:"reasons"`
+	HeldAt  time.Time  `json:"heldAt"`
+	Status  HoldStatus `json:"status,omitempty"`
+	Review  *Review    `json:"review,omitempty"`
+}
+
+// Review represents the decision an operator made about a held order, with a note of why
+type Review struct {
+	Operator   string    `json:"operator"`
+	Note       string    `json:"note"`
+	ReviewedAt time.Time `json:"reviewedAt"`
+}
+
+// HoldAuditEntry represents something that happened to a held order, it was either held by a stage or reviewed by
+// an operator
+type HoldAuditEntry struct {
+	Status     HoldStatus `json:"status"`
+	Stage      string     `json:"stage"`
+	Operator   string     `json:"operator,omitempty"`
+	Note       string     `json:"note"`
+	RecordedAt time.Time  `json:"recordedAt"`
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultCurrency is the currency of orders that don't specify one
+const DefaultCurrency = "USD"
+
+// ErrOverflow is returned when an amount is too large to be represented as Money
+var ErrOverflow = errors.New("the amount is too large")
+
+// Money is an amount in the minor units of its currency (e.g. cents), so arithmetic on it is exact. It is
+// marshalled as a whole number of minor units, the currency is kept alongside it.
+type Money int64
+
+// minorUnits is how many decimals each supported ISO 4217 currency has
+var minorUnits = map[string]int{
+	"USD": 2,
+	"CAD": 2,
+	"MXN": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"AUD": 2,
+	"BRL": 2,
+	"INR": 2,
+	"JPY": 0,
+}
+
+// SupportedCurrency returns true if orders can be placed in the currency
+func SupportedCurrency(currency string) bool {
+	_, found := minorUnits[currency]
+	return found
+}
+
+// Times returns the amount multiplied by a quantity, or ErrOverflow if the product is too large
+func (m Money) Times(quantity int) (Money, error) {
+	if m == 0 || quantity == 0 {
+		return 0, nil
+	}
+
+	product := m * Money(quantity)
+	if product/Money(quantity) != m || (quantity == -1 && m < 0 && product < 0) {
+		return 0, ErrOverflow
+	}
+
+	return product, nil
+}
+
+// Sum returns the sum of the amounts, or ErrOverflow if it is too large
+func Sum(amounts ...Money) (Money, error) {
+	var sum Money
+	for _, amount := range amounts {
+		next := sum + amount
+		if (amount > 0 && next < sum) || (amount < 0 && next > sum) {
+			return 0, ErrOverflow
+		}
+		sum = next
+	}
+
+	return sum, nil
+}
+
+// BasisPoints returns the specified part of the amount in basis points (hundredths of a percent), rounded half
+// away from zero to the nearest minor unit
+func (m Money) BasisPoints(bps int64) Money {
+	product := int64(m) * bps
+	if product < 0 {
+		return Money((product - 5000) / 10000)
+	}
+
+	return Money((product + 5000) / 10000)
+}
+
+// Format returns the amount in the major units of the currency followed by its code, e.g. 12.34 USD
+func (m Money) Format(currency string) string {
+	decimals := minorUnits[currency]
+
+	sign := ""
+	amount := int64(m)
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	if decimals == 0 {
+		return fmt.Sprintf("%s%d %s", sign, amount, currency)
+	}
+
+	scale := int64(1)
+	for i := 0; i < decimals; i++ {
+		scale *= 10
+	}
+
+	fraction := fmt.Sprintf("%d", amount%scale)
+	return fmt.Sprintf("%s%d.%s%s %s", sign, amount/scale, strings.Repeat("0", decimals-len(fraction)), fraction, currency)
+}
+
+// Totals are the amounts of an order, they're always computed by the order service. The total is the subtotal less
+// the discount, plus shipping and tax, the tax is the sum of the tax of the products.
+type Totals struct {
+	Subtotal Money `json:"subtotal"`
+	Discount Money `json:"discount"`
+	Shipping Money `json:"shipping"`
+	Tax      Money `json:"tax"`
+	Total    Money `json:"total"`
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMoneyTimes(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   Money
+		quantity int
+		want     Money
+		wantErr  error
+	}{
+		{name: "one", amount: 1299, quantity: 1, want: 1299},
+		{name: "several", amount: 1299, quantity: 3, want: 3897},
+		{name: "zero quantity", amount: 1299, quantity: 0, want: 0},
+		{name: "zero amount", amount: 0, quantity: math.MaxInt64, want: 0},
+		{name: "negative", amount: -250, quantity: 4, want: -1000},
+		{name: "largest", amount: math.MaxInt64, quantity: 1, want: math.MaxInt64},
+		{name: "overflows", amount: math.MaxInt64/2 + 1, quantity: 2, wantErr: ErrOverflow},
+		{name: "overflows to positive", amount: math.MaxInt64, quantity: 3, wantErr: ErrOverflow},
+		{name: "large quantity", amount: 1299, quantity: math.MaxInt64 / 1000, wantErr: ErrOverflow},
+		{name: "smallest negated", amount: math.MinInt64, quantity: -1, wantErr: ErrOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.amount.Times(tt.quantity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Times() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Times() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSum(t *testing.T) {
+	tests := []struct {
+		name    string
+		amounts []Money
+		want    Money
+		wantErr error
+	}{
+		{name: "nothing", want: 0},
+		{name: "amounts", amounts: []Money{2598, 599, 188}, want: 3385},
+		{name: "with negatives", amounts: []Money{2598, -260, 599}, want: 2937},
+		{name: "largest", amounts: []Money{math.MaxInt64 - 1, 1}, want: math.MaxInt64},
+		{name: "overflows", amounts: []Money{math.MaxInt64, 1}, wantErr: ErrOverflow},
+		{name: "underflows", amounts: []Money{math.MinInt64, -1}, wantErr: ErrOverflow},
+		{name: "comes back in range", amounts: []Money{math.MaxInt64, 1, -2}, wantErr: ErrOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sum(tt.amounts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Sum() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Sum() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyBasisPoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Money
+		bps    int64
+		want   Money
+	}{
+		{name: "exact", amount: 10000, bps: 725, want: 725},
+		{name: "rounds down", amount: 2598, bps: 725, want: 188},   // 188.355
+		{name: "rounds up", amount: 1299, bps: 725, want: 94},      // 94.1775
+		{name: "half rounds up", amount: 10, bps: 5000, want: 5},   // 5
+		{name: "half a cent", amount: 1, bps: 5000, want: 1},       // 0.5
+		{name: "just under half", amount: 1, bps: 4999, want: 0},   // 0.4999
+		{name: "negative half", amount: -1, bps: 5000, want: -1},   // -0.5
+		{name: "negative", amount: -2598, bps: 725, want: -188},    // -188.355
+		{name: "everything", amount: 2598, bps: 10000, want: 2598}, // 100%
+		{name: "nothing", amount: 2598, bps: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.amount.BasisPoints(tt.bps); got != tt.want {
+				t.Errorf("BasisPoints(%d) = %d, want %d", tt.bps, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		currency string
+		want     string
+	}{
+		{amount: 1234, currency: "USD", want: "12.34 USD"},
+		{amount: 5, currency: "USD", want: "0.05 USD"},
+		{amount: 50, currency: "EUR", want: "0.50 EUR"},
+		{amount: 0, currency: "GBP", want: "0.00 GBP"},
+		{amount: -1234, currency: "USD", want: "-12.34 USD"},
+		{amount: -5, currency: "USD", want: "-0.05 USD"},
+		{amount: 800, currency: "JPY", want: "800 JPY"},
+		{amount: -800, currency: "JPY", want: "-800 JPY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.amount.Format(tt.currency); got != tt.want {
+				t.Errorf("Format(%s) = %s, want %s", tt.currency, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,20 +1,59 @@
 package models
 
 import (
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 )
 
-// Order represents a collection of products that should be shipped to the specified shipping address
+const (
+	// DefaultSalesChannel is the sales channel of orders that don't specify one
+	DefaultSalesChannel = "direct"
+
+	// DefaultCountry is the country of addresses that don't specify one
+	DefaultCountry = "US"
+)
+
+// Order represents a collection of products that should be shipped to the specified shipping address. The ID is
+// always assigned by the order service, the sales channel the order came from can give its own reference to the
+// order as the external order ID, which is unique within the sales channel. The service level decides how fast the
+// order is shipped, and the stages of the pipeline are measured against it from when the order was received. An
+// order can ship in several shipments, the events of the warehouse and the shipper are each about one of them.
 type Order struct {
-	ID       uuid.UUID `json:"id,omitempty"`
-	Products []Product `json:"products"`
-	Customer Customer  `json:"customer"`
+	ID              uuid.UUID        `json:"id,omitempty"`
+	ExternalOrderID string           `json:"externalOrderId,omitempty"`
+	SalesChannel    string           `json:"salesChannel,omitempty"`
+	Currency        string           `json:"currency,omitempty"` // ISO 4217 code, USD if not specified
+	PromotionCode   string           `json:"promotionCode,omitempty"`
+	ServiceLevel    ServiceLevel     `json:"serviceLevel,omitempty"` // standard if not specified
+	ReceivedAt      *time.Time       `json:"receivedAt,omitempty"`   // set by the order service
+	Products        []Product        `json:"products"`
+	Customer        Customer         `json:"customer"`
+	Totals          Totals           `json:"totals"`
+	Fraud           *FraudAssessment `json:"fraud,omitempty"`        // set by the fraud service once the order is scored
+	Payment         *Payment         `json:"payment,omitempty"`      // set by the payment service once the payment is authorised
+	Shipment        *Shipment        `json:"shipment,omitempty"`     // set by the warehouse to the shipment it picked and packed
+	FullyShipped    bool             `json:"fullyShipped,omitempty"` // set by the shipper once every product has shipped
 }
 
-// Product represents a single product in an order
+// Shipped returns true if the order shipped of an OrderShipped event has nothing left to ship, which is when its
+// payment is captured. Orders shipped before shipments shipped whole.
+func (o Order) Shipped() bool {
+	return o.Shipment == nil || o.FullyShipped
+}
+
+// Product represents a single product in an order, the name and prices come from the catalogue and prices are in
+// the currency of the order. The discount is the part of the line total taken off by the promotion of the order,
+// and the tax is charged on what is left of the line total.
 type Product struct {
 	ProductCode string `json:"productCode"`
+	Name        string `json:"name,omitempty"`
 	Quantity    int    `json:"quantity"`
+	UnitPrice   Money  `json:"unitPrice,omitempty"`
+	LineTotal   Money  `json:"lineTotal,omitempty"`
+	Discount    Money  `json:"discount,omitempty"`
+	Tax         Money  `json:"tax,omitempty"`
 }
 
 // Address represents an customers address
@@ -24,12 +63,20 @@ type Address struct {
 	City       string `json:"city"`
 	State      string `json:"state"`
 	PostalCode string `json:"postalCode"`
+	Country    string `json:"country,omitempty"` // ISO 3166-1 alpha-2 code, US if not specified
+}
+
+// PostalCode normalises a postal code so it can be compared, e.g. sw1a 1aa and SW1A1AA
+func PostalCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(code, " ", ""))
 }
 
-// Customer represents information about the customer placing the order
+// Customer represents information about the customer placing the order, the billing address is the address of the
+// card the order is paid with if the sales channel knows it
 type Customer struct {
-	FirstName       string  `json:"firstName"`
-	LastName        string  `json:"lastName"`
-	EmailAddress    string  `json:"emailAddress"`
-	ShippingAddress Address `json:"shippingAddress"`
+	FirstName       string   `json:"firstName"`
+	LastName        string   `json:"lastName"`
+	EmailAddress    string   `json:"emailAddress"`
+	ShippingAddress Address  `json:"shippingAddress"`
+	BillingAddress  *Address `json:"billingAddress,omitempty"`
 }
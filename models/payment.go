@@ -0,0 +1,38 @@
+package models
+
+import "github.com/google/uuid"
+
+// PaymentStatus is how far the payment of an order has got
+type PaymentStatus string
+
+const (
+	// PaymentAuthorized payments have been approved by the gateway, but the customer hasn't been charged yet
+	PaymentAuthorized PaymentStatus = "authorized"
+
+	// PaymentDeclined payments were refused by the gateway, the order can't be fulfilled
+	PaymentDeclined PaymentStatus = "declined"
+
+	// PaymentCaptured payments have been charged to the customer, once the order was shipped
+	PaymentCaptured PaymentStatus = "captured"
+)
+
+// Payment represents the payment of the total of an order, in the currency of the order. The authorization ID is
+// the gateway's reference to the payment.
+type Payment struct {
+	OrderID         uuid.UUID     `json:"orderId"`
+	Status          PaymentStatus `json:"status"`
+	AuthorizationID string        `json:"authorizationId,omitempty"`
+	Amount          Money         `json:"amount"`
+	Currency        string        `json:"currency,omitempty"`
+	DeclineReason   string        `json:"declineReason,omitempty"`
+}
+
+// Refund represents money given back to the customer from a captured payment, for the products of a return. The
+// refund ID is the gateway's reference to the refund.
+type Refund struct {
+	ReturnID uuid.UUID `json:"returnId"`
+	OrderID  uuid.UUID `json:"orderId"`
+	RefundID string    `json:"refundId"`
+	Amount   Money     `json:"amount"`
+	Currency string    `json:"currency,omitempty"`
+}
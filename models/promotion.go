@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// PromotionType is how a promotion discounts an order
+type PromotionType string
+
+const (
+	// PercentageOff promotions take a percentage off the eligible products
+	PercentageOff PromotionType = "percentage"
+
+	// FixedAmountOff promotions take an amount off the eligible products, in the currency of the order
+	FixedAmountOff PromotionType = "fixed"
+
+	// FreeShipping promotions waive the shipping of orders with an eligible product
+	FreeShipping PromotionType = "free_shipping"
+)
+
+// Promotion represents a discount code customers can apply to an order. A promotion can only be redeemed while it
+// is active and within its validity window, and up to its usage limits, a limit of zero is no limit.
+type Promotion struct {
+	Code                      string           `json:"code"`
+	Type                      PromotionType    `json:"type"`
+	PercentOff                int              `json:"percentOff,omitempty"`   // percentage promotions only
+	AmountsOff                map[string]Money `json:"amountsOff,omitempty"`   // fixed promotions only, by ISO 4217 currency code
+	ProductCodes              []string         `json:"productCodes,omitempty"` // the eligible products, every product if empty
+	ValidFrom                 *time.Time       `json:"validFrom,omitempty"`
+	ValidUntil                *time.Time       `json:"validUntil,omitempty"`
+	MaxRedemptions            int              `json:"maxRedemptions,omitempty"`
+	MaxRedemptionsPerCustomer int              `json:"maxRedemptionsPerCustomer,omitempty"`
+	Active                    bool             `json:"active"`
+}
+
+// Eligible returns true if the promotion applies to the product
+func (p Promotion) Eligible(productCode string) bool {
+	if len(p.ProductCodes) == 0 {
+		return true
+	}
+
+	for _, code := range p.ProductCodes {
+		if code == productCode {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,36 @@
+package models
+
+import "github.com/google/uuid"
+
+// ReturnStatus is how far the return of products from an order has got
+type ReturnStatus string
+
+const (
+	// ReturnAuthorized returns have been opened, the customer can send the products back
+	ReturnAuthorized ReturnStatus = "authorized"
+
+	// ReturnReceived returns have been scanned back in by the warehouse, the products are restocked and refunded
+	ReturnReceived ReturnStatus = "received"
+)
+
+// ReturnLine represents the quantity of a product of the order being returned. The refund is the part of what was
+// paid for the line (less its discount, plus its tax) that is given back for the quantity returned.
+type ReturnLine struct {
+	ProductCode string `json:"productCode"`
+	Quantity    int    `json:"quantity"`
+	Refund      Money  `json:"refund,omitempty"`
+}
+
+// Return represents a return authorization for some of the products of an order. The ID, status, refund and customer
+// are set by the order service, the refund is in the currency of the order and doesn't include its shipping. Every
+// return authorised has the customer of the order, it's only a pointer so the Avro schema stays nullable.
+type Return struct {
+	ID       uuid.UUID    `json:"id,omitempty"`
+	OrderID  uuid.UUID    `json:"orderId,omitempty"`
+	Status   ReturnStatus `json:"status,omitempty"`
+	Reason   string       `json:"reason,omitempty"`
+	Lines    []ReturnLine `json:"lines"`
+	Currency string       `json:"currency,omitempty"`
+	Refund   Money        `json:"refund,omitempty"`
+	Customer *Customer    `json:"customer"`
+}
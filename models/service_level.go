@@ -0,0 +1,20 @@
+package models
+
+// ServiceLevel is how fast an order is shipped, it decides how soon the warehouse picks the order and the carrier
+// service it ships with
+type ServiceLevel string
+
+const (
+	// StandardShipping orders are picked in the order they were confirmed and ship with the cheapest service, orders
+	// that don't specify a service level are standard
+	StandardShipping ServiceLevel = "standard"
+
+	// ExpeditedShipping orders jump the pick queue and ship with a two day service
+	ExpeditedShipping ServiceLevel = "expedited"
+
+	// OvernightShipping orders are picked first and ship with a next day service
+	OvernightShipping ServiceLevel = "overnight"
+)
+
+// ServiceLevels lists every service level, slowest first
+var ServiceLevels = []ServiceLevel{StandardShipping, ExpeditedShipping, OvernightShipping}
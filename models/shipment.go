@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Shipment represents part of an order handed to a carrier in one go, e.g. the products one warehouse picked, or
+// the ones left once a backorder came in. An order ships in one or more shipments, and is fully shipped once every
+// product ordered is in one of them. The tracking number, carrier and what the carrier charges to ship it are set
+// by the shipper once it's shipped, the cost is in the currency of the order.
+type Shipment struct {
+	ID             uuid.UUID      `json:"id"`
+	OrderID        uuid.UUID      `json:"orderId"`
+	Lines          []ShipmentLine `json:"lines"`
+	Packages       []Package      `json:"packages"`
+	TrackingNumber string         `json:"trackingNumber,omitempty"`
+	Carrier        string         `json:"carrier,omitempty"`
+	Service        string         `json:"service,omitempty"`
+	Cost           Money          `json:"cost,omitempty"`
+	Currency       string         `json:"currency,omitempty"`
+	ShippedAt      *time.Time     `json:"shippedAt,omitempty"`
+}
+
+// ShipmentLine represents how many of a product of the order are in a shipment
+type ShipmentLine struct {
+	ProductCode string `json:"productCode"`
+	Quantity    int    `json:"quantity"`
+}
+
+// Package represents a box of a shipment, along with the products packed in it. The weight, in grams, and the
+// dimensions are worked out by the shipper from the catalogue.
+type Package struct {
+	Lines       []ShipmentLine `json:"lines"`
+	WeightGrams int            `json:"weightGrams,omitempty"`
+	Dimensions  Dimensions     `json:"dimensions"`
+}
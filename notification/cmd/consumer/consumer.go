@@ -2,18 +2,23 @@ package consumer
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/codec"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/dispatcher"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
 	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/notification/internal/handlers"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 // Consumer represents the subscription to a specified Kafka topic
@@ -23,15 +28,25 @@ type Consumer struct {
 	Topic  string
 }
 
-// SubscribeAndListen will subscribe to a Kafka topic and start polling and listening for events
+// pollTimeout is how long to wait for a message before checking if the consumer should shut down
+const pollTimeout = 500 * time.Millisecond
+
+// consumerName is the name the events this consumer processed are recorded under, so another service consuming the
+// same events still processes them
+const consumerName = "notification"
+
+// SubscribeAndListen will subscribe to a Kafka topic and start polling and listening for events until the
+// context is cancelled. The message being processed when the context is cancelled is finished and committed
+// before the consumer leaves the group.
 // Adpated from https://github.com/confluentinc/confluent-kafka-go#examples
-func (c *Consumer) SubscribeAndListen() error {
+func (c *Consumer) SubscribeAndListen(ctx context.Context) error {
 
 	kc, err := kafka.NewConsumer(&kafka.ConfigMap{
 		"bootstrap.servers":     c.Broker,
 		"broker.address.family": "v4",
 		"group.id":              c.Group + "-notification",
 		"session.timeout.ms":    6000,
+		"enable.auto.commit":    false,
 		"auto.offset.reset":     "earliest"})
 
 	if err != nil {
@@ -42,22 +57,59 @@ func (c *Consumer) SubscribeAndListen() error {
 
 	log.WithField("consumer", kc).Info("Created Consumer")
 
-	err = kc.SubscribeTopics([]string{c.Topic}, nil)
+	pool, err := db.NewDB().ConnectPool(ctx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
+		kc.Close()
+
+		return err
+	}
+
+	defer func() {
+		log.Info("closing connection pool to database")
+		pool.Close()
+	}()
+
+	// each message is committed by the worker that processed it, once it has been handled. Retried messages that
+	// aren't due yet pause their retry topic partition until they are, rather than holding up the poll.
+	d := dispatcher.New(kc, config.PartitionWorkers(), hdlr.RetryWait, func(msg *kafka.Message) {
+		handleMessage(pool, msg)
+		commitMessage(kc, msg)
+	})
+
+	err = kc.SubscribeTopics([]string{c.Topic, config.RetryTopicName(c.Topic)}, d.Rebalance)
 	if err != nil {
 		log.WithField("error", err).
 			WithField("topic", c.Topic).
 			Error("Failed to subscribe to topic")
+		kc.Close()
 
 		return err
 	}
 
 	for {
-		msg, err := kc.ReadMessage(-1)
+		select {
+		case <-ctx.Done():
+			log.Warn("Closing consumer...")
+			d.Close()
+
+			return kc.Close()
+		default:
+		}
+
+		d.Resume()
+
+		msg, err := kc.ReadMessage(pollTimeout)
 		if err != nil {
+			if kerr, ok := err.(kafka.Error); ok && kerr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+
 			// The client will automatically try to recover from all errors.
 			log.WithField("error", err).Error(msg)
 
 			log.Warn("Closing consumer...")
+			d.Close()
 			kc.Close()
 
 			return err
@@ -65,27 +117,59 @@ func (c *Consumer) SubscribeAndListen() error {
 
 		log.WithField("topic", msg.TopicPartition).Info(string(msg.Value))
 
-		var event events.Notification
-		if err = json.Unmarshal([]byte(string(msg.Value)), &event); err != nil {
-			log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+		d.Dispatch(msg)
+	}
+}
 
-			continue
-		}
+// commitMessage will commit the offset of a message once it has been handled one way or another
+func commitMessage(kc *kafka.Consumer, msg *kafka.Message) {
+	if _, err := kc.CommitMessage(msg); err != nil {
+		log.WithField("error", err).
+			WithField("topic", msg.TopicPartition).
+			Error("an issue occurred trying to commit the offset of the message")
+	}
+}
 
-		notification, err := extractNotification(event)
-		if err != nil {
-			log.WithField("error", err).Error("an issue occurred trying to extract notification information from the notification event")
+// handleMessage will route a message to the handler for the event it holds, based on its headers. Messages
+// without an event name header were published before headers were added, so they are assumed to hold an Notification.
+func handleMessage(pool *pgxpool.Pool, msg *kafka.Message) {
+	ctx := headers.NewContext(context.Background(), headers.FromMessage(msg))
 
-			hdlr.HandleError(event)
-			continue
-		}
+	switch name := headers.Get(msg.Headers, headers.EventName); name {
+	case "", events.Notification{}.Name():
+		handleNotification(ctx, pool, msg)
+	default:
+		log.WithField("event.name", name).
+			WithField("topic", msg.TopicPartition).
+			Debug("skipping an event this consumer doesn't handle")
+	}
+}
 
-		if err = processEvent(event, notification); err != nil {
-			log.WithField("error", err).Error("an issue occurred trying to process the event")
+// handleNotification will process a single Notification message, every failure is handed off to be retried or dead lettered
+func handleNotification(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
 
-			hdlr.HandleError(event)
-			continue
-		}
+	var event events.Notification
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
+
+	notification, err := extractNotification(event)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to extract notification information from the notification event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	if err = hdlr.Retry(func() error { return processEvent(ctx, pool, event, notification) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
 	}
 }
 
@@ -95,41 +179,40 @@ func extractNotification(event events.Notification) (models.Notification, error)
 	body := event.Body()
 	notification, ok := body.(models.Notification)
 	if !ok {
-		return models.Notification{}, errors.New("event body can't be cast as a Notification")
+		return models.Notification{}, hdlr.NewPermanentError(errors.New("event body can't be cast as a Notification"))
 	}
 
 	return notification, nil
 }
 
-func processEvent(event events.Event, notification models.Notification) error {
-	var err error
-
+func processEvent(ctx context.Context, pool *pgxpool.Pool, event events.Event, notification models.Notification) (err error) {
 	db := db.NewDB()
-	conn, err := db.Connect()
-	if err != nil {
-		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
-		return err
-	}
 
 	// begin a transaction
-	tx, err := conn.Begin(context.Background())
+	tx, err := pool.Begin(context.Background())
 	if err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to start a database transaction")
 		return err
 	}
 
 	defer func() {
+		if err != nil {
+			log.Info("rolling back DB transaction")
+			if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+				log.WithField("error", rbErr).Error("an issue occurred trying to roll back the transaction")
+			}
+
+			return
+		}
+
 		log.Info("committing DB transaction")
 		if err = tx.Commit(context.Background()); err != nil {
 			log.WithField("error", err).Error("an issue occurred trying to commit the transaction")
 		}
-
-		log.Info("closing connection to database")
-		conn.Close(context.Background())
 	}()
 
 	// check to see if event has already been processed
-	eventAlreadyProcessed, err := db.EventExists(event, tx)
+	eventAlreadyProcessed, err := db.EventExists(consumerName, event, tx)
 	if err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to check if an event was already processed")
 		return err
@@ -155,11 +238,11 @@ func processEvent(event events.Event, notification models.Notification) error {
 	default:
 		log.WithField("notification.type", notification.Type).Error("notification type is not supported at this time")
 
-		return fmt.Errorf("notification type, \"%s\" is not supported", notification.Type)
+		return hdlr.NewPermanentError(fmt.Errorf("notification type, \"%s\" is not supported", notification.Type))
 	}
 
 	// mark the event as processed
-	if err = db.InsertEvent(event, tx); err != nil {
+	if err = db.InsertEvent(consumerName, event, tx); err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to insert the event")
 		return err
 	}
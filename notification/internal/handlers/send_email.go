@@ -1,14 +1,28 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"net/mail"
+
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
 	log "github.com/sirupsen/logrus"
 )
 
-// SendEmail will construct and send an email based on the supplied notification information
+// SendEmail will construct and send an email based on the supplied notification information.
+// A notification without a recipient is a permanent error, one with an undeliverable recipient is rejected.
 func SendEmail(notification models.Notification) error {
 	log.Info("attempting to send an email notification")
 
+	if len(notification.Recipient) == 0 {
+		return hdlr.NewPermanentError(errors.New("email notification has no recipient"))
+	}
+
+	if _, err := mail.ParseAddress(notification.Recipient); err != nil {
+		return hdlr.NewRejectedError(fmt.Errorf("email recipient [%s] is not a deliverable address: %w", notification.Recipient, err))
+	}
+
 	log.WithField("subject", notification.Subject).
 		WithField("body", notification.Body).
 		WithField("recipient", notification.Recipient).
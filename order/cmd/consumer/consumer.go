@@ -0,0 +1,284 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/codec"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/dispatcher"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/handlers"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Consumer represents the subscription to the specified Kafka topics
+type Consumer struct {
+	Broker string
+	Group  string
+	Topics []string
+}
+
+// pollTimeout is how long to wait for a message before checking if the consumer should shut down
+const pollTimeout = 500 * time.Millisecond
+
+// consumerName is the name the events this consumer processed are recorded under, so another service consuming the
+// same events still processes them
+const consumerName = "order"
+
+// SubscribeAndListen will subscribe to the Kafka topics and start polling and listening for events until the
+// context is cancelled. The message being processed when the context is cancelled is finished and committed
+// before the consumer leaves the group.
+// Adpated from https://github.com/confluentinc/confluent-kafka-go#examples
+func (c *Consumer) SubscribeAndListen(ctx context.Context) error {
+
+	kc, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":     c.Broker,
+		"broker.address.family": "v4",
+		"group.id":              c.Group + "-order",
+		"session.timeout.ms":    6000,
+		"enable.auto.commit":    false,
+		"auto.offset.reset":     "earliest"})
+
+	if err != nil {
+		log.WithField("error", err).Error("Failed to create consumer")
+
+		return err
+	}
+
+	log.WithField("consumer", kc).Info("Created Consumer")
+
+	pool, err := db.NewDB().ConnectPool(ctx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
+		kc.Close()
+
+		return err
+	}
+
+	defer func() {
+		log.Info("closing connection pool to database")
+		pool.Close()
+	}()
+
+	// each message is committed by the worker that processed it, once it has been handled. Retried messages that
+	// aren't due yet pause their retry topic partition until they are, rather than holding up the poll.
+	d := dispatcher.New(kc, config.PartitionWorkers(), hdlr.RetryWait, func(msg *kafka.Message) {
+		handleMessage(pool, msg)
+		commitMessage(kc, msg)
+	})
+
+	var topics []string
+	for _, topic := range c.Topics {
+		topics = append(topics, topic, config.RetryTopicName(topic))
+	}
+
+	if err = kc.SubscribeTopics(topics, d.Rebalance); err != nil {
+		log.WithField("error", err).
+			WithField("topics", c.Topics).
+			Error("Failed to subscribe to topic")
+		kc.Close()
+
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Warn("Closing consumer...")
+			d.Close()
+
+			return kc.Close()
+		default:
+		}
+
+		d.Resume()
+
+		msg, err := kc.ReadMessage(pollTimeout)
+		if err != nil {
+			if kerr, ok := err.(kafka.Error); ok && kerr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+
+			// The client will automatically try to recover from all errors.
+			log.WithField("error", err).Error(msg)
+
+			log.Warn("Closing consumer...")
+			d.Close()
+			kc.Close()
+
+			return err
+		}
+
+		log.WithField("topic", msg.TopicPartition).Info(string(msg.Value))
+
+		d.Dispatch(msg)
+	}
+}
+
+// commitMessage will commit the offset of a message once it has been handled one way or another
+func commitMessage(kc *kafka.Consumer, msg *kafka.Message) {
+	if _, err := kc.CommitMessage(msg); err != nil {
+		log.WithField("error", err).
+			WithField("topic", msg.TopicPartition).
+			Error("an issue occurred trying to commit the offset of the message")
+	}
+}
+
+// handleMessage will route a message to the handler for the event it holds, based on its headers. Messages
+// without an event name header were published before headers were added, so they are assumed to hold a Rejection.
+func handleMessage(pool *pgxpool.Pool, msg *kafka.Message) {
+	ctx := headers.NewContext(context.Background(), headers.FromMessage(msg))
+
+	switch name := headers.Get(msg.Headers, headers.EventName); name {
+	case "", events.Rejection{}.Name():
+		handleRejection(ctx, pool, msg)
+	case events.PaymentDeclined{}.Name():
+		handlePaymentDeclined(ctx, pool, msg)
+	case events.OrderHeld{}.Name():
+		handleOrderHeld(ctx, pool, msg)
+	default:
+		log.WithField("event.name", name).
+			WithField("topic", msg.TopicPartition).
+			Debug("skipping an event this consumer doesn't handle")
+	}
+}
+
+// handleRejection will process a single Rejection message, a rejected order gives back the promotion it redeemed.
+// Every failure is handed off to be retried or dead lettered.
+func handleRejection(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
+
+	var event events.Rejection
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
+
+	// rejections that aren't for an order have nothing to reverse
+	if event.EventBody.OrderID == uuid.Nil {
+		log.WithField("event.id", event.ID()).Debug("skipping a rejection that isn't for an order")
+		return
+	}
+
+	reverse := func(tx pgx.Tx) error { return handlers.ReversePromotion(event.EventBody.OrderID, tx) }
+	if err = hdlr.Retry(func() error { return processEvent(pool, event, reverse) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+}
+
+// handlePaymentDeclined will process a single PaymentDeclined message, an order that can't be paid for gives back the
+// promotion it redeemed. Every failure is handed off to be retried or dead lettered.
+func handlePaymentDeclined(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
+
+	var event events.PaymentDeclined
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
+
+	reverse := func(tx pgx.Tx) error { return handlers.ReversePromotion(event.EventBody.ID, tx) }
+	if err = hdlr.Retry(func() error { return processEvent(pool, event, reverse) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+}
+
+// handleOrderHeld will process a single OrderHeld message, the order is added to the queue of held orders waiting to
+// be reviewed. Every failure is handed off to be retried or dead lettered.
+func handleOrderHeld(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
+
+	var event events.OrderHeld
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
+
+	hold := func(tx pgx.Tx) error { return handlers.HoldOrder(event.EventBody, event.ID(), tx) }
+	if err = hdlr.Retry(func() error { return processEvent(pool, event, hold) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+}
+
+// processEvent makes the change for an event in a transaction, unless the event was already processed
+func processEvent(pool *pgxpool.Pool, event events.Event, change func(tx pgx.Tx) error) (err error) {
+	db := db.NewDB()
+
+	// begin a transaction
+	tx, err := pool.Begin(context.Background())
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to start a database transaction")
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			log.Info("rolling back DB transaction")
+			if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+				log.WithField("error", rbErr).Error("an issue occurred trying to roll back the transaction")
+			}
+
+			return
+		}
+
+		log.Info("committing DB transaction")
+		if err = tx.Commit(context.Background()); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to commit the transaction")
+		}
+	}()
+
+	// check to see if event has already been processed
+	eventAlreadyProcessed, err := db.EventExists(consumerName, event, tx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to check if an event was already processed")
+		return err
+	}
+
+	// if event has already been processed, nothing more to do
+	if eventAlreadyProcessed {
+		log.WithField("event.id", event.ID()).
+			WithField("event.name", event.Name()).
+			Info("event was processed previously")
+
+		return nil
+	}
+
+	// event hasn't been processed yet, make the change
+	if err = change(tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		return err
+	}
+
+	// mark the event as processed
+	if err = db.InsertEvent(consumerName, event, tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to insert the event")
+		return err
+	}
+
+	return nil
+}
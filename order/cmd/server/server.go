@@ -1,15 +1,21 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/jackc/pgx/v4/pgxpool"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/logger"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/openapi"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/tax"
 )
 
 // Server represents the web server hosting the service
@@ -17,9 +23,68 @@ type Server struct {
 	Port int
 }
 
-// ListenAndServe will start the web server and listen for requests
-func (s *Server) ListenAndServe() error {
+// ListenAndServe will start the web server and listen for requests until the context is cancelled, at which
+// point it stops accepting connections and waits for in-flight requests to finish
+func (s *Server) ListenAndServe(ctx context.Context) error {
 
+	// orders and idempotency keys are stored in the database
+	pool, err := db.NewDB().ConnectPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	// orders are taxed by the calculator configured by TAX_PROVIDER
+	taxes, err := tax.New()
+	if err != nil {
+		return err
+	}
+
+	// requests are validated against the OpenAPI document, which has to document every route
+	doc, err := openapi.New()
+	if err != nil {
+		return err
+	}
+
+	r := routes(pool, taxes, doc)
+
+	if err = openapi.Covers(doc, r); err != nil {
+		return err
+	}
+
+	address := fmt.Sprintf(":%d", s.Port)
+	log.WithField("address", address).Info("server starting")
+
+	srv := &http.Server{
+		Addr:    address,
+		Handler: r,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.WithField("timeout", config.ShutdownTimeout().String()).Info("server shutting down, draining requests")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout())
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// routes returns the router of the service, with its middlewares and every route it supports
+func routes(pool *pgxpool.Pool, taxes tax.Calculator, doc *openapi.Document) *chi.Mux {
 	// setup CHI router
 	r := chi.NewRouter()
 
@@ -29,13 +94,35 @@ func (s *Server) ListenAndServe() error {
 	r.Use(logger.NewStructuredLogger())  // uses structured logging like our app (logs only at debug level)
 	r.Use(middleware.Recoverer)          // handles any unhandles errors and returns a 500
 
+	// requests are validated against the OpenAPI document before they reach the handlers
+	r.Use(openapi.Validate(doc))
+
 	// setup supported routes
 	r.Get("/", handlers.Root)
 	r.Get("/health", handlers.Health)
-	r.Post("/orders", handlers.ReceiveOrder)
-
-	address := fmt.Sprintf(":%d", s.Port)
-	log.WithField("address", address).Info("server starting")
+	r.With(handlers.Idempotent(pool)).Post("/orders", handlers.ReceiveOrder(pool, taxes))
+	r.With(handlers.Idempotent(pool)).Post("/orders:batch", handlers.ReceiveOrders(pool, taxes))
+	r.Get("/orders", handlers.FindOrder(pool))
+	r.Get("/orders/{id}", handlers.GetOrder(pool))
+	r.Get("/orders/{id}/returns", handlers.ListReturns(pool))
+	r.With(handlers.Idempotent(pool)).Post("/orders/{id}/returns", handlers.CreateReturn(pool))
+	r.Get("/orders/{id}/returns/{returnId}", handlers.GetReturn(pool))
+	r.Post("/orders/{id}/returns/{returnId}/received", handlers.ReceiveReturn(pool))
+	r.Get("/catalogue/products", handlers.ListProducts(pool))
+	r.Post("/catalogue/products", handlers.CreateProduct(pool))
+	r.Get("/catalogue/products/{code}", handlers.GetProduct(pool))
+	r.Put("/catalogue/products/{code}", handlers.UpdateProduct(pool))
+	r.Delete("/catalogue/products/{code}", handlers.DeleteProduct(pool))
+	r.Get("/promotions", handlers.ListPromotions(pool))
+	r.Post("/promotions", handlers.CreatePromotion(pool))
+	r.Get("/promotions/{code}", handlers.GetPromotion(pool))
+	r.Put("/promotions/{code}", handlers.UpdatePromotion(pool))
+	r.Get("/held-orders", handlers.ListHeldOrders(pool))
+	r.Get("/held-orders/{id}", handlers.GetHeldOrder(pool))
+	r.Get("/held-orders/{id}/audit", handlers.GetHeldOrderAudit(pool))
+	r.Post("/held-orders/{id}/approve", handlers.ApproveHeldOrder(pool))
+	r.Post("/held-orders/{id}/reject", handlers.RejectHeldOrder(pool))
+	r.Get("/openapi.json", openapi.Handler(doc))
 
-	return http.ListenAndServe(address, r)
+	return r
 }
@@ -0,0 +1,18 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/openapi"
+)
+
+func TestRoutesDocumented(t *testing.T) {
+	doc, err := openapi.New()
+	if err != nil {
+		t.Fatalf("openapi.New() error = %v", err)
+	}
+
+	if err = openapi.Covers(doc, routes(nil, nil, doc)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+// ListProducts handler will return every product in the catalogue, or only the active ones if active=true
+//
+// Example cURL (localhost)
+// $ curl -v 'http://localhost:8080/catalogue/products?active=true'
+func ListProducts(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalogue := db.NewDB()
+
+		var products []models.CatalogueProduct
+		if err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			var err error
+			products, err = catalogue.ListCatalogueProducts(r.URL.Query().Get("active") == "true", tx)
+			return err
+		}); err != nil {
+			log.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		if products == nil {
+			products = []models.CatalogueProduct{}
+		}
+
+		writeJSON(w, http.StatusOK, products)
+	}
+}
+
+// CreateProduct handler will add a product to the catalogue. returns a HTTP 201 status code with the product, or
+// a HTTP 409 if there already is a product with the code
+//
+// Example cURL payload (localhost)
+// $ curl -v -H "Content-Type: application/json" -d '{"code":"12345","name":"Nitrile Gloves (100)","weightGrams":450,"dimensions":{"lengthMm":250,"widthMm":130,"heightMm":70},"active":true,"prices":{"USD":1299,"EUR":1199}}' http://localhost:8080/catalogue/products
+func CreateProduct(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := decodeProduct(w, r)
+		if !ok {
+			return
+		}
+
+		catalogue := db.NewDB()
+		err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			return catalogue.InsertCatalogueProduct(p, tx)
+		})
+		if writeCatalogueError(w, err) {
+			return
+		}
+
+		log.WithField("product.code", p.Code).Info("added product to the catalogue")
+
+		w.Header().Set("Location", productLocation(p.Code))
+		writeJSON(w, http.StatusCreated, p)
+	}
+}
+
+// GetProduct handler will return the product of the catalogue with the code in the path
+//
+// Example cURL (localhost)
+// $ curl -v http://localhost:8080/catalogue/products/12345
+func GetProduct(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalogue := db.NewDB()
+
+		var p models.CatalogueProduct
+		err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			var err error
+			p, err = catalogue.GetCatalogueProduct(chi.URLParam(r, "code"), tx)
+			return err
+		})
+		if writeCatalogueError(w, err) {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// UpdateProduct handler will replace the product of the catalogue with the code in the path, including its prices
+//
+// Example cURL payload (localhost)
+// $ curl -v -X PUT -H "Content-Type: application/json" -d '{"code":"12345","name":"Nitrile Gloves (100)","weightGrams":450,"dimensions":{"lengthMm":250,"widthMm":130,"heightMm":70},"active":false,"prices":{"USD":1299}}' http://localhost:8080/catalogue/products/12345
+func UpdateProduct(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := decodeProduct(w, r)
+		if !ok {
+			return
+		}
+
+		if p.Code != chi.URLParam(r, "code") {
+			validation.WriteErrors(w, validation.Errors{{Field: "code", Code: validation.Mismatch, Message: "should be the code in the path"}})
+			return
+		}
+
+		catalogue := db.NewDB()
+		err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			return catalogue.UpdateCatalogueProduct(p, tx)
+		})
+		if writeCatalogueError(w, err) {
+			return
+		}
+
+		log.WithField("product.code", p.Code).Info("updated product in the catalogue")
+
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// DeleteProduct handler will delete the product of the catalogue with the code in the path. Orders that were
+// already received keep the name and prices of their products, products that are no longer sold but might be
+// sold again should be made inactive instead.
+//
+// Example cURL (localhost)
+// $ curl -v -X DELETE http://localhost:8080/catalogue/products/12345
+func DeleteProduct(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := chi.URLParam(r, "code")
+
+		catalogue := db.NewDB()
+		err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			return catalogue.DeleteCatalogueProduct(code, tx)
+		})
+		if writeCatalogueError(w, err) {
+			return
+		}
+
+		log.WithField("product.code", code).Info("deleted product from the catalogue")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodeProduct decodes and validates the product in the body of the request, writing the problems with it if
+// there are any
+func decodeProduct(w http.ResponseWriter, r *http.Request) (models.CatalogueProduct, bool) {
+	var p models.CatalogueProduct
+	if err := validation.Decode(r.Body, &p); err != nil {
+		log.Error(err.Error())
+		writeValidationError(w, err)
+
+		return p, false
+	}
+
+	if errs := validation.CatalogueProduct(p); len(errs) > 0 {
+		log.WithField("errors", errs).Error("product is invalid")
+		validation.WriteErrors(w, errs)
+
+		return p, false
+	}
+
+	if p.Prices == nil {
+		p.Prices = make(map[string]models.Money)
+	}
+	if len(p.TaxCategory) == 0 {
+		p.TaxCategory = models.DefaultTaxCategory
+	}
+
+	return p, true
+}
+
+// writeCatalogueError writes the error of a catalogue operation, and returns true if there was one
+func writeCatalogueError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, db.ErrProductNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, db.ErrDuplicateProduct):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	return true
+}
+
+// productLocation returns the path the product can be looked up at
+func productLocation(code string) string {
+	return "/catalogue/products/" + url.PathEscape(code)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// GetOrder handler will return the order with the ID in the path, or a HTTP 404 status code if there isn't one
+//
+// Example cURL (localhost)
+// $ curl -v http://localhost:8080/orders/6e042f29-350b-4d51-8849-5e36456dfa48
+func GetOrder(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "invalid order ID", http.StatusBadRequest)
+			return
+		}
+
+		orders := db.NewDB()
+		var o models.Order
+		err = pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			o, err = orders.GetOrder(id, tx)
+			return err
+		})
+
+		writeOrder(w, o, err)
+	}
+}
+
+// FindOrder handler will return the order received from a sales channel with the sales channel's own ID for it,
+// or a HTTP 404 status code if there isn't one
+//
+// Example cURL (localhost)
+// $ curl -v 'http://localhost:8080/orders?salesChannel=web&externalOrderId=A-1001'
+func FindOrder(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		salesChannel := r.URL.Query().Get("salesChannel")
+		if len(salesChannel) == 0 {
+			salesChannel = defaultSalesChannel
+		}
+
+		externalOrderID := r.URL.Query().Get("externalOrderId")
+		if len(externalOrderID) == 0 {
+			http.Error(w, "externalOrderId is required", http.StatusBadRequest)
+			return
+		}
+
+		orders := db.NewDB()
+		var o models.Order
+		err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			var err error
+			o, err = orders.FindOrderByExternalID(salesChannel, externalOrderID, tx)
+			return err
+		})
+
+		writeOrder(w, o, err)
+	}
+}
+
+// writeOrder writes the result of an order lookup
+func writeOrder(w http.ResponseWriter, o models.Order, err error) {
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, o)
+	case errors.Is(err, db.ErrOrderNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// orderLocation returns the path the order can be looked up at
+func orderLocation(id uuid.UUID) string {
+	return "/orders/" + id.String()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithField("error", err.Error()).Error("unable to write the response")
+	}
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+)
+
+var (
+	// errHeldOrderAlreadyReviewed is returned when a held order that was already approved or rejected is reviewed again
+	errHeldOrderAlreadyReviewed = errors.New("the held order was already reviewed")
+
+	// errHeldOrderCantResume is returned when a held order is approved but the stage that held it can't be resumed
+	errHeldOrderCantResume = errors.New("orders held by the stage can't be resumed")
+)
+
+// ListHeldOrders handler will return the queue of orders with the status in the query, the ones held longest first.
+// Orders waiting to be reviewed are returned if no status is specified.
+//
+// Example cURL (localhost)
+// $ curl -v http://localhost:8080/held-orders
+func ListHeldOrders(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := models.HoldStatus(r.URL.Query().Get("status"))
+		if len(status) == 0 {
+			status = models.HoldPending
+		}
+
+		store := db.NewDB()
+
+		var list []models.HeldOrder
+		if err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			var err error
+			list, err = store.ListHeldOrders(status, tx)
+			return err
+		}); err != nil {
+			log.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		if list == nil {
+			list = []models.HeldOrder{}
+		}
+
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// GetHeldOrder handler will return the order with the ID in the path along with why it was held and its review, or
+// a HTTP 404 status code if it was never held
+//
+// Example cURL (localhost)
+// $ curl -v http://localhost:8080/held-orders/6e042f29-350b-4d51-8849-5e36456dfa48
+func GetHeldOrder(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "invalid order ID", http.StatusBadRequest)
+			return
+		}
+
+		store := db.NewDB()
+		var h models.HeldOrder
+		err = pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			h, err = store.GetHeldOrder(id, tx)
+			return err
+		})
+		if writeHeldOrderError(w, err) {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, h)
+	}
+}
+
+// GetHeldOrderAudit handler will return the audit trail of the held order with the ID in the path, every time it
+// was held and reviewed in the order it happened
+//
+// Example cURL (localhost)
+// $ curl -v http://localhost:8080/held-orders/6e042f29-350b-4d51-8849-5e36456dfa48/audit
+func GetHeldOrderAudit(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "invalid order ID", http.StatusBadRequest)
+			return
+		}
+
+		store := db.NewDB()
+		var entries []models.HoldAuditEntry
+		err = pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			if _, err := store.GetHeldOrder(id, tx); err != nil {
+				return err
+			}
+
+			entries, err = store.ListHoldAuditEntries(id, tx)
+			return err
+		})
+		if writeHeldOrderError(w, err) {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+// ApproveHeldOrder handler will approve the held order with the ID in the path, publishing the event that resumes the
+// pipeline from the stage that held it, e.g. a FraudCheckPassed event for an order held by the fraud stage. returns
+// a HTTP 200 status code with the held order, or a HTTP 409 if it was already reviewed.
+//
+// Example cURL payload (localhost)
+// $ curl -v -H "Content-Type: application/json" -d '{"operator":"jane.doe","note":"the customer confirmed the order by phone"}' http://localhost:8080/held-orders/6e042f29-350b-4d51-8849-5e36456dfa48/approve
+func ApproveHeldOrder(pool *pgxpool.Pool) http.HandlerFunc {
+	return reviewHeldOrder(pool, models.HoldApproved, func(ctx context.Context, h models.HeldOrder, _ uuid.UUID) error {
+		var e events.Event
+		var topic string
+		switch h.Stage {
+		case models.FraudStage:
+			e = events.FraudCheckPassed{
+				EventBase: events.BaseEvent{
+					EventID:        uuid.New(),
+					EventTimestamp: time.Now(),
+				},
+				EventBody: h.Order,
+			}
+			topic = config.FraudCheckPassedTopicName
+		default:
+			return fmt.Errorf("%w: %s", errHeldOrderCantResume, h.Stage)
+		}
+
+		return publisher.PublishEvent(e, topic, publisher.WithContext(ctx))
+	})
+}
+
+// RejectHeldOrder handler will reject the held order with the ID in the path, publishing a Rejection event so the
+// pipeline goes no further with it and anything it redeemed is reversed. returns a HTTP 200 status code with the held
+// order, or a HTTP 409 if it was already reviewed.
+//
+// Example cURL payload (localhost)
+// $ curl -v -H "Content-Type: application/json" -d '{"operator":"jane.doe","note":"the card was reported stolen"}' http://localhost:8080/held-orders/6e042f29-350b-4d51-8849-5e36456dfa48/reject
+func RejectHeldOrder(pool *pgxpool.Pool) http.HandlerFunc {
+	return reviewHeldOrder(pool, models.HoldRejected, func(ctx context.Context, h models.HeldOrder, eventID uuid.UUID) error {
+		e := events.Rejection{
+			EventBase: events.BaseEvent{
+				EventID:        uuid.New(),
+				EventTimestamp: time.Now(),
+			},
+			EventBody: models.Rejection{
+				EventID:   eventID,
+				EventName: events.OrderHeld{}.Name(),
+				OrderID:   h.Order.ID,
+				Reason:    fmt.Sprintf("rejected on review by %s: %s", h.Review.Operator, h.Review.Note),
+			},
+		}
+
+		return publisher.PublishEvent(e, config.RejectionsTopicName, publisher.WithContext(ctx))
+	})
+}
+
+// reviewHeldOrder returns a handler recording the review of the held order with the ID in the path with the status,
+// the held order is locked so it's only reviewed once and is only recorded as reviewed if the event is published
+func reviewHeldOrder(pool *pgxpool.Pool, status models.HoldStatus, publish func(ctx context.Context, h models.HeldOrder, eventID uuid.UUID) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "invalid order ID", http.StatusBadRequest)
+			return
+		}
+
+		var review models.Review
+		if err = validation.Decode(r.Body, &review); err != nil {
+			log.Error(err.Error())
+			writeValidationError(w, err)
+
+			return
+		}
+
+		if errs := validation.Review(review); len(errs) > 0 {
+			log.WithField("errors", errs).Error("review is invalid")
+			validation.WriteErrors(w, errs)
+
+			return
+		}
+
+		ctx := requestContext(r)
+
+		store := db.NewDB()
+		var h models.HeldOrder
+		err = pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			var eventID uuid.UUID
+			var err error
+			if h, eventID, err = store.LockHeldOrder(id, tx); err != nil {
+				return err
+			}
+
+			if h.Status != models.HoldPending {
+				return errHeldOrderAlreadyReviewed
+			}
+
+			review.ReviewedAt = time.Now()
+			h.Status = status
+			h.Review = &review
+			if err = store.ReviewHeldOrder(h, tx); err != nil {
+				return err
+			}
+
+			return publish(ctx, h, eventID)
+		})
+		if writeHeldOrderError(w, err) {
+			return
+		}
+
+		log.WithField("order.id", h.Order.ID).
+			WithField("status", h.Status).
+			WithField("operator", review.Operator).
+			Info("reviewed held order")
+
+		writeJSON(w, http.StatusOK, h)
+	}
+}
+
+// HoldOrder adds an order a stage of the pipeline held to the queue of orders waiting to be reviewed, the event is
+// the one that held it
+func HoldOrder(h models.HeldOrder, eventID uuid.UUID, tx pgx.Tx) error {
+	h.Status = models.HoldPending
+	h.Review = nil
+	if err := db.NewDB().InsertHeldOrder(h, eventID, tx); err != nil {
+		return err
+	}
+
+	log.WithField("order.id", h.Order.ID).
+		WithField("stage", h.Stage).
+		Info("order is held for review")
+
+	return nil
+}
+
+// writeHeldOrderError writes the error of a held order operation, and returns true if there was one
+func writeHeldOrderError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, db.ErrHeldOrderNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errHeldOrderAlreadyReviewed), errors.Is(err, errHeldOrderCantResume):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	return true
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+)
+
+const (
+	// idempotencyKeyHeader is the HTTP header a caller can use to safely retry a request, a request made again
+	// with the same key gets the original response instead of being processed again
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotentReplayedHeader is set on responses that were replayed from an earlier request
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotentKey is the context key of the idempotent request a handler is processing
+type idempotentKey struct{}
+
+// idempotentRequest is a request made with an Idempotency-Key header, as its handler sees it
+type idempotentRequest struct {
+	previous []byte // the response to the partly processed request this one is made again of
+	partial  bool
+}
+
+// partial marks the response to an idempotent request as the one of a partly processed request, because part of it
+// failed in a way that could succeed if it was made again. The response is stored, and the request is processed
+// again when it is made again with the key, with the stored response (see previousResponse) so the handler only
+// processes the parts that failed.
+func partial(r *http.Request) {
+	if req, ok := r.Context().Value(idempotentKey{}).(*idempotentRequest); ok {
+		req.partial = true
+	}
+}
+
+// previousResponse returns the body of the response stored for the partly processed request this one is made
+// again of, if it is one
+func previousResponse(r *http.Request) ([]byte, bool) {
+	if req, ok := r.Context().Value(idempotentKey{}).(*idempotentRequest); ok && req.previous != nil {
+		return req.previous, true
+	}
+
+	return nil, false
+}
+
+// Idempotent returns a middleware honouring the Idempotency-Key header. The first request made with a key is
+// processed and its response stored with a hash of the request, requests made again with the key get the stored
+// response, unless the handler marked it as partial. Reusing a key for a different request is rejected with a HTTP
+// 422 status code, and reusing it while the first request is still being processed with a HTTP 409. Responses with a
+// 5xx status code aren't stored, and the key is released if the request panics, so the request can be retried with
+// the same key. A partly processed request keeps its stored response when that happens.
+func Idempotent(pool *pgxpool.Pool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			if len(key) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			keys := db.NewDB()
+			requested := db.IdempotencyKey{
+				Key:              key,
+				RequestHash:      requestHash(r, body),
+				CreatedTimestamp: time.Now(),
+			}
+
+			var stored db.IdempotencyKey
+			var reserved bool
+			if err = pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+				stored, reserved, err = keys.ReserveIdempotencyKey(requested, time.Now().Add(-config.IdempotencyKeyTTL()), tx)
+				return err
+			}); err != nil {
+				log.WithField("idempotencyKey", key).Error(err.Error())
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			switch {
+			case stored.RequestHash != requested.RequestHash:
+				log.WithField("idempotencyKey", key).Warn("idempotency key reused for a different request")
+				http.Error(w, "Idempotency-Key has already been used for a different request", http.StatusUnprocessableEntity)
+
+				return
+			case reserved:
+			case stored.Completed():
+				log.WithField("idempotencyKey", key).Info("replaying response of idempotent request")
+				if len(stored.ContentType) > 0 {
+					w.Header().Set("Content-Type", stored.ContentType)
+				}
+				w.Header().Set(idempotentReplayedHeader, "true")
+				w.WriteHeader(stored.StatusCode)
+				w.Write(stored.Body)
+
+				return
+			default:
+				http.Error(w, "a request with this Idempotency-Key is still being processed", http.StatusConflict)
+
+				return
+			}
+
+			idempotent := &idempotentRequest{}
+			if stored.Partial {
+				log.WithField("idempotencyKey", key).Info("processing again the partly processed idempotent request")
+				idempotent.previous = stored.Body
+			}
+
+			// the key of a request that failed is released, unless the request was partly processed before, so the
+			// results of the parts that succeeded then aren't lost
+			release := func(tx pgx.Tx) error {
+				if stored.Partial {
+					return keys.CompleteIdempotencyKey(stored, tx)
+				}
+
+				return keys.DeleteIdempotencyKey(requested, tx)
+			}
+
+			var response bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&response)
+
+			// a handler that panics never finishes the request, so release the key before the panic is passed on
+			// to be recovered, otherwise every request made again with it would be a conflict
+			defer func() {
+				if p := recover(); p != nil {
+					if err := pool.BeginFunc(context.Background(), release); err != nil {
+						log.WithField("idempotencyKey", key).
+							WithField("error", err.Error()).
+							Error("unable to release the idempotency key of the request that panicked")
+					}
+
+					panic(p)
+				}
+			}()
+
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), idempotentKey{}, idempotent)))
+
+			requested.StatusCode = ww.Status()
+			if requested.StatusCode == 0 {
+				requested.StatusCode = http.StatusOK
+			}
+			requested.ContentType = ww.Header().Get("Content-Type")
+			requested.Body = response.Bytes()
+			requested.Partial = idempotent.partial
+
+			// the request is finished whatever happens to the key, so don't let a cancelled request leave it reserved
+			if err = pool.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+				if requested.StatusCode >= http.StatusInternalServerError {
+					return release(tx)
+				}
+
+				return keys.CompleteIdempotencyKey(requested, tx)
+			}); err != nil {
+				log.WithField("idempotencyKey", key).
+					WithField("error", err.Error()).
+					Error("unable to store the response of the idempotent request")
+			}
+		})
+	}
+}
+
+// requestHash identifies a request by its method, path and body
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/promotions"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+// ListPromotions handler will return every promotion, ordered by code
+//
+// Example cURL (localhost)
+// $ curl -v http://localhost:8080/promotions
+func ListPromotions(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := db.NewDB()
+
+		var list []models.Promotion
+		if err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			var err error
+			list, err = store.ListPromotions(tx)
+			return err
+		}); err != nil {
+			log.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		if list == nil {
+			list = []models.Promotion{}
+		}
+
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// CreatePromotion handler will add a promotion. returns a HTTP 201 status code with the promotion, or a HTTP 409
+// if there already is a promotion with the code
+//
+// Example cURL payload (localhost)
+// $ curl -v -H "Content-Type: application/json" -d '{"code":"SPRING10","type":"percentage","percentOff":10,"validFrom":"2024-03-01T00:00:00Z","validUntil":"2024-06-01T00:00:00Z","maxRedemptions":1000,"maxRedemptionsPerCustomer":1,"active":true}' http://localhost:8080/promotions
+func CreatePromotion(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := decodePromotion(w, r)
+		if !ok {
+			return
+		}
+
+		store := db.NewDB()
+		err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			return store.InsertPromotion(p, tx)
+		})
+		if writePromotionError(w, err) {
+			return
+		}
+
+		log.WithField("promotion.code", p.Code).Info("created promotion")
+
+		w.Header().Set("Location", promotionLocation(p.Code))
+		writeJSON(w, http.StatusCreated, p)
+	}
+}
+
+// GetPromotion handler will return the promotion with the code in the path
+//
+// Example cURL (localhost)
+// $ curl -v http://localhost:8080/promotions/SPRING10
+func GetPromotion(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := db.NewDB()
+
+		var p models.Promotion
+		err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			var err error
+			p, err = store.GetPromotion(chi.URLParam(r, "code"), tx)
+			return err
+		})
+		if writePromotionError(w, err) {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// UpdatePromotion handler will replace the promotion with the code in the path, it keeps the redemptions it already
+// has. Promotions are withdrawn by making them inactive.
+//
+// Example cURL payload (localhost)
+// $ curl -v -X PUT -H "Content-Type: application/json" -d '{"code":"SPRING10","type":"percentage","percentOff":10,"active":false}' http://localhost:8080/promotions/SPRING10
+func UpdatePromotion(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := decodePromotion(w, r)
+		if !ok {
+			return
+		}
+
+		if p.Code != chi.URLParam(r, "code") {
+			validation.WriteErrors(w, validation.Errors{{Field: "code", Code: validation.Mismatch, Message: "should be the code in the path"}})
+			return
+		}
+
+		store := db.NewDB()
+		err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			return store.UpdatePromotion(p, tx)
+		})
+		if writePromotionError(w, err) {
+			return
+		}
+
+		log.WithField("promotion.code", p.Code).Info("updated promotion")
+
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// ReversePromotion reverses the redemption of a promotion by an order that was cancelled or rejected, so it no
+// longer counts towards the usage limits of the promotion. Reversing an order without a redemption does nothing.
+func ReversePromotion(orderID uuid.UUID, tx pgx.Tx) error {
+	reversed, err := db.NewDB().ReversePromotionRedemption(orderID, tx)
+	if err != nil {
+		return err
+	}
+
+	if reversed {
+		log.WithField("order.id", orderID).Info("reversed the promotion redeemed by the order")
+	}
+
+	return nil
+}
+
+// redeemPromotion records the promotion of an order was redeemed by it, as part of the transaction storing the order.
+// The promotion is locked while its redemptions are counted, so concurrent orders can't take it over its usage
+// limits, and checked again in case it was changed since the order was priced. The problems with redeeming it are
+// returned as validation.Errors.
+func redeemPromotion(o models.Order, tx pgx.Tx) error {
+	if len(o.PromotionCode) == 0 {
+		return nil
+	}
+
+	store := db.NewDB()
+
+	p, err := store.LockPromotion(o.PromotionCode, tx)
+	if errors.Is(err, db.ErrPromotionNotFound) {
+		return validation.Errors{{Field: "promotionCode", Code: validation.NotFound, Message: "there is no promotion with the code"}}
+	}
+	if err != nil {
+		return err
+	}
+
+	redeemed, redeemedByCustomer, err := store.PromotionRedemptions(p.Code, o.Customer.EmailAddress, tx)
+	if err != nil {
+		return err
+	}
+
+	errs := promotions.Check(p, o, time.Now())
+	errs = append(errs, promotions.Limits(p, redeemed, redeemedByCustomer)...)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return store.InsertPromotionRedemption(p.Code, o.ID, o.Customer.EmailAddress, tx)
+}
+
+// decodePromotion decodes and validates the promotion in the body of the request, writing the problems with it if
+// there are any
+func decodePromotion(w http.ResponseWriter, r *http.Request) (models.Promotion, bool) {
+	var p models.Promotion
+	if err := validation.Decode(r.Body, &p); err != nil {
+		log.Error(err.Error())
+		writeValidationError(w, err)
+
+		return p, false
+	}
+
+	if errs := validation.Promotion(p); len(errs) > 0 {
+		log.WithField("errors", errs).Error("promotion is invalid")
+		validation.WriteErrors(w, errs)
+
+		return p, false
+	}
+
+	return p, true
+}
+
+// writePromotionError writes the error of a promotion operation, and returns true if there was one
+func writePromotionError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, db.ErrPromotionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, db.ErrDuplicatePromotion):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	return true
+}
+
+// promotionLocation returns the path the promotion can be looked up at
+func promotionLocation(code string) string {
+	return "/promotions/" + url.PathEscape(code)
+}
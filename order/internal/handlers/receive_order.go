@@ -1,113 +1,295 @@
 package handlers
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/metrics"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/pricing"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/promotions"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/tax"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
 	"github.com/google/uuid"
 )
 
-// ReceiveOrder handler will accept an order, validate the payload and publish an OrderReceived event to Kafka.
-// returns a HTTP 201 status code indicating an order was created
+const (
+	// correlationIDHeader is the HTTP header a caller can use to correlate the events published for their request
+	correlationIDHeader = "X-Correlation-ID"
+
+	// defaultSalesChannel is the sales channel of orders that don't specify one
+	defaultSalesChannel = models.DefaultSalesChannel
+)
+
+// ReceiveOrder handler will accept an order, validate the payload (returning every problem with it as RFC 7807
+// problem details), check its products against the catalogue and price them, apply its promotion code, work out its tax, store
+// it and publish an OrderReceived event to Kafka.
+// returns a HTTP 201 status code indicating an order was created, with the order and its location. Clients that retry
+// should send an Idempotency-Key header, so a retried order is only placed once (see Idempotent).
+//
+// The ID of the order is assigned by the service, clients can give their own reference to the order as the
+// externalOrderId, which has to be unique within the salesChannel (direct if not specified).
 //
 // Example cURL payload (localhost)
-// $ curl -v -H "Content-Type: application/json" -d '{"id":"6e042f29-350b-4d51-8849-5e36456dfa48","products":[{"productCode":"12345","quantity":2}],"customer":{"firstName":"Tom","lastName":"Hardy","emailAddress":"tom.hardy@email.com","shippingAddress":{"line1":"123 Anywhere St","city":"Anytown","state":"AL","postalCode":"12345"}}}' http://localhost:8080/orders
-func ReceiveOrder(w http.ResponseWriter, r *http.Request) {
-	var o models.Order
-	// Create a new ID for the order
-	o.ID = uuid.New()
+// $ curl -v -H "Content-Type: application/json" -d '{"externalOrderId":"A-1001","salesChannel":"web","products":[{"productCode":"12345","quantity":2}],"customer":{"firstName":"Tom","lastName":"Hardy","emailAddress":"tom.hardy@email.com","shippingAddress":{"line1":"123 Anywhere St","city":"Anytown","state":"AL","postalCode":"12345"}}}' http://localhost:8080/orders
+func ReceiveOrder(pool *pgxpool.Pool, taxes tax.Calculator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var o models.Order
 
-	var err error
+		var err error
 
-	if err = json.NewDecoder(r.Body).Decode(&o); err != nil {
-		log.Error(err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if err = validation.Decode(r.Body, &o); err != nil {
+			log.Error(err.Error())
+			writeValidationError(w, err)
 
-		return
-	}
+			return
+		}
 
-	log.WithField("order", o).Info("received new order")
+		log.WithField("order", o).Info("received new order")
 
-	if err = validate(o); err != nil {
-		log.WithField("orderID", o.ID).Error(err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if errs := validation.Order(o); len(errs) > 0 {
+			log.WithField("errors", errs).Error("order is invalid")
+			validation.WriteErrors(w, errs)
 
-		return
-	}
+			return
+		}
 
-	e := translateOrderToEvent(o)
+		prepareOrder(&o)
 
-	log.WithField("event", e).Info("transformed order to event")
+		priceErrs, err := priceOrders(r.Context(), pool, taxes, &o)
+		if err != nil {
+			log.WithField("orderID", o.ID).Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 
-	if err = publisher.PublishEvent(e, config.OrderReceivedTopicName); err != nil {
-		log.WithField("orderID", o.ID).Error(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(priceErrs[0]) > 0 {
+			log.WithField("errors", priceErrs[0]).Error("order can't be priced")
+			validation.WriteErrors(w, priceErrs[0])
 
-		return
-	}
+			return
+		}
 
-	// No issues publishing the order received event, lets publish the order count metric
-	tag := metrics.Tag{
-		Name:  "products_ordered",
-		Value: strconv.Itoa(len(o.Products)),
+		e := translateOrderToEvent(o)
+
+		log.WithField("event", e).Info("transformed order to event")
+
+		ctx := requestContext(r)
+
+		// the event is published once the order is stored, an order whose event can't be published is discarded so
+		// a failed request can be made again
+		orders := db.NewDB()
+		err = pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			if err := orders.InsertOrder(o, tx); err != nil {
+				return err
+			}
+
+			return redeemPromotion(o, tx)
+		})
+		if errors.Is(err, db.ErrDuplicateOrder) {
+			log.WithField("externalOrderID", o.ExternalOrderID).
+				WithField("salesChannel", o.SalesChannel).
+				Warn(err.Error())
+
+			var existing models.Order
+			if pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+				existing, err = orders.FindOrderByExternalID(o.SalesChannel, o.ExternalOrderID, tx)
+				return err
+			}) == nil {
+				w.Header().Set("Location", orderLocation(existing.ID))
+			}
+			http.Error(w, db.ErrDuplicateOrder.Error(), http.StatusConflict)
+
+			return
+		}
+		var redeemErrs validation.Errors
+		if errors.As(err, &redeemErrs) {
+			log.WithField("errors", redeemErrs).Error("promotion can't be redeemed")
+			validation.WriteErrors(w, redeemErrs)
+
+			return
+		}
+		if err != nil {
+			log.WithField("orderID", o.ID).Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		if err = publisher.PublishEvent(e, config.OrderReceivedTopicName, publisher.WithContext(ctx)); err != nil {
+			log.WithField("orderID", o.ID).Error(err.Error())
+			discardOrder(pool, o.ID)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		// No issues publishing the order received event, lets publish the order count metric
+		if err = publisher.PublishEvent(orderCountMetric(o), config.OrderCountTopicName, publisher.WithContext(ctx)); err != nil {
+			log.WithField("orderID", o.ID).
+				WithField("error", err.Error()).
+				Error("unable to publish order count metric")
+		}
+
+		log.WithField("event", e).Info("published event")
+
+		w.Header().Set("Location", orderLocation(o.ID))
+		writeJSON(w, http.StatusCreated, o)
 	}
-	tags := []metrics.Tag{tag}
-	m := metrics.NewOrderCount(tags)
-	me := events.TranslateToOrderCountMetricEvent(m)
-	if err = publisher.PublishEvent(me, config.OrderCountTopicName); err != nil {
-		log.WithField("orderID", o.ID).
+}
+
+// discardOrder deletes an order that was stored but whose event couldn't be published, along with the redemption of
+// its promotion, so it can be placed again. An order that can't be discarded is only logged, it stays stored without
+// having been received by the other services.
+func discardOrder(pool *pgxpool.Pool, id uuid.UUID) {
+	orders := db.NewDB()
+	if err := pool.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+		if err := orders.DeleteOrder(id, tx); err != nil {
+			return err
+		}
+
+		return orders.DeletePromotionRedemption(id, tx)
+	}); err != nil {
+		log.WithField("orderID", id).
 			WithField("error", err.Error()).
-			Error("unable to publish order count metric")
+			Error("unable to discard the order whose event couldn't be published")
 	}
+}
 
-	log.WithField("event", e).Info("published event")
+// prepareOrder assigns a new ID to a valid order and fills in the defaults of the fields it didn't specify
+func prepareOrder(o *models.Order) {
+	// Create a new ID for the order
+	o.ID = uuid.New()
+	if len(o.SalesChannel) == 0 {
+		o.SalesChannel = defaultSalesChannel
+	}
+	if len(o.Customer.ShippingAddress.Country) == 0 {
+		o.Customer.ShippingAddress.Country = validation.DefaultCountry
+	}
+	if o.Customer.BillingAddress != nil && len(o.Customer.BillingAddress.Country) == 0 {
+		o.Customer.BillingAddress.Country = validation.DefaultCountry
+	}
+	if len(o.Currency) == 0 {
+		o.Currency = models.DefaultCurrency
+	}
+	if len(o.ServiceLevel) == 0 {
+		o.ServiceLevel = models.StandardShipping
+	}
+	received := time.Now()
+	o.ReceivedAt = &received
 
-	w.WriteHeader(http.StatusCreated)
+	// promotion codes are matched case insensitively
+	o.PromotionCode = strings.ToUpper(strings.TrimSpace(o.PromotionCode))
 }
 
-// Validates the order payload has the necessary information and returns an error if it is invalid
-func validate(o models.Order) error {
-	if len(o.Products) == 0 {
-		return fmt.Errorf("there are no products in the order")
-	}
+// priceOrders checks the products of the orders against the catalogue and their promotions can be applied, then
+// prices and taxes them (see pricing.Price and pricing.Tax), and returns the problems with each. Orders whose total
+// is too large, or isn't greater than zero, are a problem too.
+func priceOrders(ctx context.Context, pool *pgxpool.Pool, taxes tax.Calculator, orders ...*models.Order) ([]validation.Errors, error) {
+	catalogue := db.NewDB()
 
-	for i, p := range o.Products {
-		if len(p.ProductCode) == 0 {
-			return fmt.Errorf("product code is required for product [%d]", i)
+	var codes []string
+	for _, o := range orders {
+		if len(o.PromotionCode) > 0 {
+			codes = append(codes, o.PromotionCode)
 		}
+	}
 
-		if p.Quantity <= 0 {
-			return fmt.Errorf("quantity should be greater than zero for product [%s]", p.ProductCode)
+	var products map[string]models.CatalogueProduct
+	var available map[string]models.Promotion
+	if err := pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var err error
+		if products, err = catalogue.CatalogueProducts(pricing.ProductCodes(orders...), tx); err != nil {
+			return err
 		}
-	}
 
-	if len(o.Customer.EmailAddress) == 0 {
-		return fmt.Errorf("email address is required")
+		available, err = catalogue.Promotions(codes, tx)
+		return err
+	}); err != nil {
+		return nil, err
 	}
 
-	if len(o.Customer.ShippingAddress.Line1) == 0 {
-		return fmt.Errorf("shipping address line 1 is required")
+	now := time.Now()
+	errs := make([]validation.Errors, len(orders))
+	for i, o := range orders {
+		var promotion *models.Promotion
+		if len(o.PromotionCode) > 0 {
+			p, found := available[o.PromotionCode]
+			if !found {
+				errs[i].Add("promotionCode", validation.NotFound, "there is no promotion with the code")
+				continue
+			}
+			if errs[i] = promotions.Check(p, *o, now); len(errs[i]) > 0 {
+				continue
+			}
+			promotion = &p
+		}
+
+		if errs[i] = pricing.Price(o, products, promotion); len(errs[i]) > 0 {
+			continue
+		}
+
+		if err := pricing.Tax(ctx, taxes, o, products); errors.Is(err, models.ErrOverflow) {
+			errs[i].Add("products", validation.OutOfRange, err.Error())
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		// a total that isn't positive can't be paid for, e.g. a promotion took off more than the order costs
+		if o.Totals.Total <= 0 {
+			errs[i].Add("products", validation.OutOfRange, "the total of the order should be greater than zero")
+		}
 	}
 
-	if len(o.Customer.ShippingAddress.City) == 0 {
-		return fmt.Errorf("shipping address city is required")
+	return errs, nil
+}
+
+// requestContext carries the callers correlation ID and trace context over to the events, if they sent them
+func requestContext(r *http.Request) context.Context {
+	return headers.NewContext(r.Context(), headers.Metadata{
+		CorrelationID: r.Header.Get(correlationIDHeader),
+		TraceParent:   r.Header.Get(headers.TraceParent),
+	})
+}
+
+// orderCountMetric returns the metric event counting an order that was received
+func orderCountMetric(o models.Order) events.Event {
+	tag := metrics.Tag{
+		Name:  "products_ordered",
+		Value: strconv.Itoa(len(o.Products)),
 	}
+	tags := []metrics.Tag{tag}
+	m := metrics.NewOrderCount(tags)
+
+	return events.TranslateToOrderCountMetricEvent(m)
+}
 
-	if len(o.Customer.ShippingAddress.PostalCode) == 0 {
-		return fmt.Errorf("shipping address postal code is required")
+// writeValidationError writes a problem describing why the request couldn't be decoded
+func writeValidationError(w http.ResponseWriter, err error) {
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		validation.WriteErrors(w, errs)
+		return
 	}
 
-	return nil
+	validation.WriteProblem(w, validation.Problem{
+		Status: http.StatusBadRequest,
+		Detail: err.Error(),
+	})
 }
 
 func translateOrderToEvent(o models.Order) events.Event {
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/tax"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+)
+
+const (
+	// MaxBatchSize is how many orders can be submitted in one batch
+	MaxBatchSize = 500
+
+	// NDJSONContentType is the media type of a batch submitted as one order per line
+	NDJSONContentType = "application/x-ndjson"
+
+	// maxLineSize is how long a line of a NDJSON batch can be
+	maxLineSize = 1024 * 1024
+)
+
+// BatchResult is the result of one order of a batch, the status is the one it would have got from POST /orders
+type BatchResult struct {
+	Index    int               `json:"index"`
+	Status   int               `json:"status"`
+	ID       *uuid.UUID        `json:"id,omitempty"`
+	Location string            `json:"location,omitempty"`
+	Detail   string            `json:"detail,omitempty"`
+	Errors   validation.Errors `json:"errors,omitempty"`
+}
+
+// BatchResponse holds the result of every order of a batch, in the order they were submitted
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// ReceiveOrders handler will accept a batch of orders, either as a JSON array or as NDJSON (one order per line),
+// validate and price each of them, store the valid ones and publish an OrderReceived event for each in one batch.
+// returns a HTTP 207 status code with the result of every order, so some orders of a batch can be created while
+// others are rejected. The batch as a whole is only rejected if it can't be read or is too large. A batch some orders
+// of which failed is processed again when it is retried with its Idempotency-Key, the orders that already got a
+// result keep it, so only the ones that failed are created.
+//
+// Example cURL payload (localhost)
+// $ curl -v -H "Content-Type: application/x-ndjson" --data-binary @orders.ndjson http://localhost:8080/orders:batch
+func ReceiveOrders(pool *pgxpool.Pool, taxes tax.Calculator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items, err := batchItems(r)
+		if err != nil {
+			log.Error(err.Error())
+			validation.WriteProblem(w, validation.Problem{Status: http.StatusBadRequest, Detail: err.Error()})
+
+			return
+		}
+
+		if len(items) > MaxBatchSize {
+			validation.WriteProblem(w, validation.Problem{
+				Status: http.StatusRequestEntityTooLarge,
+				Detail: fmt.Sprintf("a batch can't have more than %d orders", MaxBatchSize),
+			})
+
+			return
+		}
+
+		// the results of a batch processed before with the same key, of which only the failed orders are processed
+		var previous BatchResponse
+		if body, ok := previousResponse(r); ok {
+			if err = json.Unmarshal(body, &previous); err != nil {
+				log.Error(err.Error())
+				validation.WriteProblem(w, validation.Problem{Status: http.StatusInternalServerError, Detail: err.Error()})
+
+				return
+			}
+		}
+
+		log.WithField("orders", len(items)).
+			WithField("retried", len(previous.Results) > 0).
+			Info("received batch of orders")
+
+		results := make([]BatchResult, len(items))
+		var valid []models.Order
+		var validIndexes []int
+		for i, item := range items {
+			if i < len(previous.Results) && previous.Results[i].Status < http.StatusInternalServerError {
+				results[i] = previous.Results[i]
+				continue
+			}
+			results[i].Index = i
+
+			var o models.Order
+			if err = validation.Decode(bytes.NewReader(item), &o); err != nil {
+				results[i].Status = http.StatusBadRequest
+				if !errors.As(err, &results[i].Errors) {
+					results[i].Detail = err.Error()
+				}
+				continue
+			}
+
+			if errs := validation.Order(o); len(errs) > 0 {
+				results[i].Status = http.StatusBadRequest
+				results[i].Errors = errs
+				continue
+			}
+
+			prepareOrder(&o)
+			valid = append(valid, o)
+			validIndexes = append(validIndexes, i)
+		}
+
+		pointers := make([]*models.Order, len(valid))
+		for k := range valid {
+			pointers[k] = &valid[k]
+		}
+
+		priceErrs, err := priceOrders(r.Context(), pool, taxes, pointers...)
+		if err != nil {
+			log.Error(err.Error())
+			validation.WriteProblem(w, validation.Problem{Status: http.StatusInternalServerError, Detail: err.Error()})
+
+			return
+		}
+
+		// orders that can't be priced are dropped from the batch
+		var priced []models.Order
+		var pricedIndexes []int
+		for k, errs := range priceErrs {
+			if len(errs) > 0 {
+				results[validIndexes[k]].Status = http.StatusBadRequest
+				results[validIndexes[k]].Errors = errs
+				continue
+			}
+
+			priced = append(priced, valid[k])
+			pricedIndexes = append(pricedIndexes, validIndexes[k])
+		}
+		valid, validIndexes = priced, pricedIndexes
+
+		ctx := requestContext(r)
+
+		// every order gets a savepoint, so an order that can't be stored doesn't undo the others
+		orders := db.NewDB()
+		var stored []models.Order
+		var storedIndexes []int
+		err = pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			for k, o := range valid {
+				err := tx.BeginFunc(r.Context(), func(sp pgx.Tx) error {
+					if err := orders.InsertOrder(o, sp); err != nil {
+						return err
+					}
+
+					return redeemPromotion(o, sp)
+				})
+				if errors.Is(err, db.ErrDuplicateOrder) {
+					results[validIndexes[k]].Status = http.StatusConflict
+					results[validIndexes[k]].Detail = err.Error()
+					continue
+				}
+				if errors.As(err, &results[validIndexes[k]].Errors) {
+					results[validIndexes[k]].Status = http.StatusBadRequest
+					continue
+				}
+				if err != nil {
+					return err
+				}
+
+				stored = append(stored, o)
+				storedIndexes = append(storedIndexes, validIndexes[k])
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.Error(err.Error())
+			validation.WriteProblem(w, validation.Problem{Status: http.StatusInternalServerError, Detail: err.Error()})
+
+			return
+		}
+
+		// the events are published once the orders are stored, orders whose event can't be published are discarded
+		evs := make([]events.Event, len(stored))
+		for k, o := range stored {
+			evs[k] = translateOrderToEvent(o)
+		}
+
+		var created []models.Order
+		for k, err := range publisher.PublishEvents(evs, config.OrderReceivedTopicName, publisher.WithContext(ctx)) {
+			result := &results[storedIndexes[k]]
+			if err != nil {
+				log.WithField("orderID", stored[k].ID).Error(err.Error())
+				discardOrder(pool, stored[k].ID)
+
+				result.Status = http.StatusInternalServerError
+				result.Detail = "the order couldn't be published"
+				continue
+			}
+
+			id := stored[k].ID
+			result.Status = http.StatusCreated
+			result.ID = &id
+			result.Location = orderLocation(id)
+			created = append(created, stored[k])
+		}
+
+		// No issues publishing the order received events, lets publish the order count metrics
+		metricEvents := make([]events.Event, len(created))
+		for k, o := range created {
+			metricEvents[k] = orderCountMetric(o)
+		}
+		for k, err := range publisher.PublishEvents(metricEvents, config.OrderCountTopicName, publisher.WithContext(ctx)) {
+			if err != nil {
+				log.WithField("orderID", created[k].ID).
+					WithField("error", err.Error()).
+					Error("unable to publish order count metric")
+			}
+		}
+
+		log.WithField("orders", len(items)).
+			WithField("created", len(created)).
+			Info("processed batch of orders")
+
+		// orders that couldn't be published are processed again if the batch is retried with the same key
+		for _, result := range results {
+			if result.Status >= http.StatusInternalServerError {
+				partial(r)
+				break
+			}
+		}
+
+		writeJSON(w, http.StatusMultiStatus, BatchResponse{Results: results})
+	}
+}
+
+// batchItems splits the body of the request into the JSON documents of its orders
+func batchItems(r *http.Request) ([]json.RawMessage, error) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == NDJSONContentType {
+		var items []json.RawMessage
+
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				items = append(items, json.RawMessage(append([]byte(nil), line...)))
+			}
+		}
+
+		return items, scanner.Err()
+	}
+
+	var items []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		return nil, fmt.Errorf("the request body should be a JSON array of orders, or NDJSON: %w", err)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/email"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/returns"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+)
+
+var (
+	// errReturnAlreadyReceived is returned when the products of a return are received again
+	errReturnAlreadyReceived = errors.New("the products of the return were already received")
+
+	// errOrderNotReturnable is returned when a return is opened for an order that hasn't shipped and been paid for
+	errOrderNotReturnable = errors.New("the order can't be returned until it has shipped and its payment was captured")
+)
+
+// ListReturns handler will return every return of the order with the ID in the path, in the order they were authorised
+//
+// Example cURL (localhost)
+// $ curl -v http://localhost:8080/orders/6e042f29-350b-4d51-8849-5e36456dfa48/returns
+func ListReturns(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "invalid order ID", http.StatusBadRequest)
+			return
+		}
+
+		store := db.NewDB()
+
+		var list []models.Return
+		err = pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			if _, err := store.GetOrder(orderID, tx); err != nil {
+				return err
+			}
+
+			list, err = store.ListReturns(orderID, tx)
+			return err
+		})
+		if writeReturnError(w, err) {
+			return
+		}
+
+		if list == nil {
+			list = []models.Return{}
+		}
+
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// CreateReturn handler will open a return authorization for some of the products of the order with the ID in the
+// path, and notify the customer they can send them back. Only products of the order can be returned, and no more of
+// them than were ordered less what their other returns have. returns a HTTP 201 status code with the return and its
+// location, including the refund the customer will get once the products are received, or a HTTP 409 if the order
+// hasn't shipped and had its payment captured yet.
+//
+// Example cURL payload (localhost)
+// $ curl -v -H "Content-Type: application/json" -d '{"reason":"ordered the wrong size","lines":[{"productCode":"12345","quantity":1}]}' http://localhost:8080/orders/6e042f29-350b-4d51-8849-5e36456dfa48/returns
+func CreateReturn(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "invalid order ID", http.StatusBadRequest)
+			return
+		}
+
+		var ret models.Return
+		if err = validation.Decode(r.Body, &ret); err != nil {
+			log.Error(err.Error())
+			writeValidationError(w, err)
+
+			return
+		}
+
+		if errs := validation.Return(ret); len(errs) > 0 {
+			log.WithField("errors", errs).Error("return is invalid")
+			validation.WriteErrors(w, errs)
+
+			return
+		}
+
+		// the order is locked while its returns are added up, so concurrent returns can't take more than was ordered
+		store := db.NewDB()
+		err = pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			o, err := store.LockOrder(orderID, tx)
+			if err != nil {
+				return err
+			}
+
+			// the payment is only captured once the last of the order has shipped, so there is something to refund
+			p, err := store.GetPayment(orderID, tx)
+			switch {
+			case errors.Is(err, db.ErrPaymentNotFound):
+				return errOrderNotReturnable
+			case err != nil:
+				return err
+			case p.Status != models.PaymentCaptured:
+				return errOrderNotReturnable
+			}
+
+			existing, err := store.ListReturns(orderID, tx)
+			if err != nil {
+				return err
+			}
+
+			returned := returns.Returned(existing)
+			if errs := returns.Check(ret, o, returned); len(errs) > 0 {
+				return errs
+			}
+
+			returns.Refund(&ret, o, returned)
+			ret.ID = uuid.New()
+			ret.OrderID = o.ID
+			ret.Status = models.ReturnAuthorized
+			ret.Currency = o.Currency
+			ret.Customer = &o.Customer
+
+			return store.InsertReturn(ret, tx)
+		})
+		if writeReturnError(w, err) {
+			return
+		}
+
+		log.WithField("order.id", ret.OrderID).
+			WithField("return.id", ret.ID).
+			Info("authorised return")
+
+		notifyReturn(requestContext(r), ret,
+			fmt.Sprintf("Hello %s, your return has been authorised.", ret.Customer.FirstName),
+			fmt.Sprintf("Your return has been authorised, please send the products back with the return number %s.", ret.ID))
+
+		w.Header().Set("Location", returnLocation(ret))
+		writeJSON(w, http.StatusCreated, ret)
+	}
+}
+
+// GetReturn handler will return the return with the ID in the path of the order with the ID in the path
+//
+// Example cURL (localhost)
+// $ curl -v http://localhost:8080/orders/6e042f29-350b-4d51-8849-5e36456dfa48/returns/8d4f1b2a-6c3e-4f7a-9b0d-2e5c8a1f3b64
+func GetReturn(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID, returnID, ok := returnIDs(w, r)
+		if !ok {
+			return
+		}
+
+		store := db.NewDB()
+
+		var ret models.Return
+		err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			var err error
+			ret, err = store.GetReturn(orderID, returnID, tx)
+			return err
+		})
+		if writeReturnError(w, err) {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, ret)
+	}
+}
+
+// ReceiveReturn handler will record the products of the return with the ID in the path were scanned back in by the
+// warehouse, publish a ReturnReceived event to Kafka so they are restocked and refunded, and notify the customer.
+// returns a HTTP 200 status code with the return, or a HTTP 409 if its products were already received.
+//
+// Example cURL (localhost)
+// $ curl -v -X POST http://localhost:8080/orders/6e042f29-350b-4d51-8849-5e36456dfa48/returns/8d4f1b2a-6c3e-4f7a-9b0d-2e5c8a1f3b64/received
+func ReceiveReturn(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID, returnID, ok := returnIDs(w, r)
+		if !ok {
+			return
+		}
+
+		ctx := requestContext(r)
+
+		// the event is published once the return is recorded as received, a return whose event can't be published
+		// is reopened so a failed scan can be made again
+		store := db.NewDB()
+		var ret, authorized models.Return
+		err := pool.BeginFunc(r.Context(), func(tx pgx.Tx) error {
+			var err error
+			if authorized, err = store.LockReturn(orderID, returnID, tx); err != nil {
+				return err
+			}
+
+			if authorized.Status == models.ReturnReceived {
+				return errReturnAlreadyReceived
+			}
+
+			ret = authorized
+			ret.Status = models.ReturnReceived
+			return store.ReceiveReturn(ret, tx)
+		})
+		if writeReturnError(w, err) {
+			return
+		}
+
+		e := events.ReturnReceived{
+			EventBase: events.BaseEvent{
+				EventID:        uuid.New(),
+				EventTimestamp: time.Now(),
+			},
+			EventBody: ret,
+		}
+		if err = publisher.PublishEvent(e, config.ReturnReceivedTopicName, publisher.WithContext(ctx)); err != nil {
+			log.WithField("return.id", ret.ID).Error(err.Error())
+			if err := pool.BeginFunc(context.Background(), func(tx pgx.Tx) error { return store.ReopenReturn(authorized, tx) }); err != nil {
+				log.WithField("return.id", ret.ID).
+					WithField("error", err.Error()).
+					Error("unable to reopen the return whose event couldn't be published")
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		log.WithField("order.id", ret.OrderID).
+			WithField("return.id", ret.ID).
+			Info("received return")
+
+		notifyReturn(ctx, ret,
+			fmt.Sprintf("Hello %s, we have received your return.", ret.Customer.FirstName),
+			fmt.Sprintf("We have received the products of your return %s, your refund is on its way.", ret.ID))
+
+		writeJSON(w, http.StatusOK, ret)
+	}
+}
+
+// notifyReturn publishes an email to the customer of the return about it, listing its products. The return has
+// been stored by then, so a notification that can't be published is only logged.
+func notifyReturn(ctx context.Context, ret models.Return, subject, message string) {
+	var b strings.Builder
+	for _, l := range ret.Lines {
+		fmt.Fprintf(&b, "<div>%d of product [%s]", l.Quantity, l.ProductCode)
+		if len(ret.Currency) > 0 {
+			fmt.Fprintf(&b, ": %s", l.Refund.Format(ret.Currency))
+		}
+		b.WriteString("</div>")
+	}
+
+	event := events.Notification{
+		EventBase: events.BaseEvent{
+			EventID:        uuid.New(),
+			EventTimestamp: time.Now(),
+		},
+		EventBody: models.Notification{
+			Type:      models.Email,
+			Recipient: ret.Customer.EmailAddress,
+			From:      "returns@ppe4all.com",
+			Subject:   subject,
+			Body:      fmt.Sprintf("<div>%s Here is a review of the products in your return:</div><div>%s</div><div>%s</div>", message, b.String(), email.Amount("Refund", ret.Refund, ret.Currency)),
+		},
+	}
+
+	if err := publisher.PublishEvent(event, config.NotificationTopicName, publisher.WithContext(ctx)); err != nil {
+		log.WithField("return.id", ret.ID).
+			WithField("error", err.Error()).
+			Error("unable to publish the notification of the return")
+	}
+}
+
+// returnIDs parses the IDs of the order and the return in the path, writing a HTTP 400 status code if either isn't valid
+func returnIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	orderID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid order ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	returnID, err := uuid.Parse(chi.URLParam(r, "returnId"))
+	if err != nil {
+		http.Error(w, "invalid return ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return orderID, returnID, true
+}
+
+// writeReturnError writes the error of a return operation, and returns true if there was one
+func writeReturnError(w http.ResponseWriter, err error) bool {
+	var errs validation.Errors
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &errs):
+		log.WithField("errors", errs).Error("products can't be returned")
+		validation.WriteErrors(w, errs)
+	case errors.Is(err, db.ErrOrderNotFound), errors.Is(err, db.ErrReturnNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errReturnAlreadyReceived), errors.Is(err, errOrderNotReturnable):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	return true
+}
+
+// returnLocation returns the path the return can be looked up at
+func returnLocation(ret models.Return) string {
+	return orderLocation(ret.OrderID) + "/returns/" + ret.ID.String()
+}
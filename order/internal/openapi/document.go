@@ -0,0 +1,704 @@
+package openapi
+
+import (
+	"reflect"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+// JSONContentType is the media type of the bodies of requests and successful responses
+const JSONContentType = "application/json"
+
+// New returns the OpenAPI document of the order service. The schemas are generated from the types in the models
+// package, along with the rules the validation package enforces that can be expressed in a schema. It returns an
+// error if a pattern in the document isn't a valid regular expression.
+func New() (*Document, error) {
+	holdStatuses := []string{string(models.HoldPending), string(models.HoldApproved), string(models.HoldRejected)}
+
+	g := generator{
+		components: make(map[string]*Schema),
+		constraints: map[string]func(*Schema){
+			"Order.id": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "assigned by the service"
+			},
+			"Order.externalOrderId": func(s *Schema) {
+				s.MaxLength = intPtr(validation.MaxExternalOrderIDLength)
+				s.Description = "the sales channel's own reference to the order, unique within the sales channel"
+			},
+			"Order.salesChannel": func(s *Schema) {
+				s.MaxLength = intPtr(validation.MaxSalesChannelLength)
+				s.Description = "the sales channel the order was placed in, direct if not specified"
+			},
+			"Order.currency": func(s *Schema) {
+				s.Pattern = "^[A-Z]{3}$"
+				s.Description = "ISO 4217 currency code, " + models.DefaultCurrency + " if not specified"
+			},
+			"Order.serviceLevel": func(s *Schema) {
+				for _, l := range models.ServiceLevels {
+					s.Enum = append(s.Enum, string(l))
+				}
+				s.Description = "how fast the order is shipped, " + string(models.StandardShipping) + " if not specified. expedited ships to the US, Canada, Mexico and western Europe, overnight to the contiguous US only"
+			},
+			"Order.receivedAt": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "when the order was received, the stages of the pipeline are measured against its service level from then"
+			},
+			"Order.totals": func(s *Schema) {
+				s.ReadOnly = true
+			},
+			"Order.fraud": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "assessed by the fraud service once the order is received"
+			},
+			"Customer.billingAddress": func(s *Schema) {
+				s.Description = "the address of the card the order is paid with, orders billed to another country than they are shipped to are more likely to be held for review"
+			},
+			"Order.payment": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "authorised by the payment service once the order is received"
+			},
+			"Order.shipment": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "the part of the order a warehouse picked and packed, an order can ship in several shipments"
+			},
+			"Order.fullyShipped": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "set by the shipper once every product of the order has shipped"
+			},
+			"Product.unitPrice": func(s *Schema) {
+				s.Minimum = floatPtr(0)
+				s.Description = "checked against the price catalogue if specified, " + s.Description
+			},
+			"Product.lineTotal": func(s *Schema) {
+				s.ReadOnly = true
+			},
+			"Product.discount": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "the part of the line total taken off by the promotion of the order, " + s.Description
+			},
+			"Product.tax": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "the tax charged on the line total less the discount, " + s.Description
+			},
+			"CatalogueProduct.taxCategory": func(s *Schema) {
+				s.MaxLength = intPtr(validation.MaxTaxCategoryLength)
+				s.Description = "decides which tax rules apply to the product, " + models.DefaultTaxCategory + " if not specified"
+			},
+			"Order.promotionCode": func(s *Schema) {
+				s.MaxLength = intPtr(validation.MaxPromotionCodeLength)
+				s.Description = "the code of a promotion to apply to the order, matched case insensitively"
+			},
+			"Promotion.code": func(s *Schema) {
+				s.MinLength = intPtr(1)
+				s.MaxLength = intPtr(validation.MaxPromotionCodeLength)
+				s.Pattern = validation.PromotionCodePattern
+			},
+			"Promotion.type": func(s *Schema) {
+				s.Enum = []string{string(models.PercentageOff), string(models.FixedAmountOff), string(models.FreeShipping)}
+			},
+			"Promotion.percentOff": func(s *Schema) {
+				s.Minimum = floatPtr(1)
+				s.Maximum = floatPtr(100)
+				s.Description = "percentage promotions only"
+			},
+			"Promotion.amountsOff": func(s *Schema) {
+				s.Description = "fixed promotions only, the amount taken off by ISO 4217 currency code, in the minor units of the currency"
+			},
+			"Promotion.productCodes": func(s *Schema) {
+				s.Description = "the products the promotion applies to, every product if not specified"
+			},
+			"Promotion.validUntil": func(s *Schema) {
+				s.Description = "the promotion can be used until, but not at, this time"
+			},
+			"Promotion.maxRedemptions": func(s *Schema) {
+				s.Minimum = floatPtr(0)
+				s.Description = "how many orders can use the promotion, unlimited if not specified"
+			},
+			"Promotion.maxRedemptionsPerCustomer": func(s *Schema) {
+				s.Minimum = floatPtr(0)
+				s.Description = "how many orders each customer (by email address) can use the promotion for, unlimited if not specified"
+			},
+			"Promotion.active": func(s *Schema) {
+				s.Description = "only active promotions can be used, promotions are withdrawn by making them inactive"
+			},
+			"Product.name": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "the name of the product in the catalogue"
+			},
+			"CatalogueProduct.code": func(s *Schema) {
+				s.MinLength = intPtr(1)
+				s.MaxLength = intPtr(validation.MaxProductCodeLength)
+			},
+			"CatalogueProduct.name": func(s *Schema) {
+				s.MinLength = intPtr(1)
+				s.MaxLength = intPtr(validation.MaxProductNameLength)
+			},
+			"CatalogueProduct.weightGrams": func(s *Schema) {
+				s.Minimum = floatPtr(1)
+			},
+			"CatalogueProduct.active": func(s *Schema) {
+				s.Description = "only active products can be ordered"
+			},
+			"CatalogueProduct.prices": func(s *Schema) {
+				s.Description = "unit price by ISO 4217 currency code, in the minor units of the currency"
+			},
+			"Dimensions.lengthMm": func(s *Schema) {
+				s.Minimum = floatPtr(1)
+			},
+			"Dimensions.widthMm": func(s *Schema) {
+				s.Minimum = floatPtr(1)
+			},
+			"Dimensions.heightMm": func(s *Schema) {
+				s.Minimum = floatPtr(1)
+			},
+			"Order.products": func(s *Schema) {
+				s.MinItems = intPtr(1)
+				s.MaxItems = intPtr(validation.MaxOrderLines)
+			},
+			"Product.productCode": func(s *Schema) {
+				s.MinLength = intPtr(1)
+			},
+			"Product.quantity": func(s *Schema) {
+				s.Minimum = floatPtr(1)
+				s.Maximum = floatPtr(validation.MaxQuantity)
+			},
+			"Customer.emailAddress": func(s *Schema) {
+				s.Format = "email"
+			},
+			"HeldOrder.stage": func(s *Schema) {
+				s.Description = "the stage of the pipeline that held the order, e.g. fraud"
+			},
+			"HeldOrder.reasons": func(s *Schema) {
+				s.Description = "why the order was held"
+			},
+			"HeldOrder.status": func(s *Schema) {
+				s.Enum = holdStatuses
+			},
+			"HeldOrder.review": func(s *Schema) {
+				s.Description = "the decision of the operator who reviewed the order, once it has been reviewed"
+			},
+			"Review.operator": func(s *Schema) {
+				s.MinLength = intPtr(1)
+				s.MaxLength = intPtr(validation.MaxReviewOperatorLength)
+				s.Description = "who reviewed the order"
+			},
+			"Review.note": func(s *Schema) {
+				s.MinLength = intPtr(1)
+				s.MaxLength = intPtr(validation.MaxReviewNoteLength)
+				s.Description = "why the order was approved or rejected"
+			},
+			"Review.reviewedAt": func(s *Schema) {
+				s.ReadOnly = true
+			},
+			"HoldAuditEntry.status": func(s *Schema) {
+				s.Enum = holdStatuses
+				s.Description = "held when a stage held the order, otherwise the decision of the review"
+			},
+			"HoldAuditEntry.operator": func(s *Schema) {
+				s.Description = "who reviewed the order, reviews only"
+			},
+			"HoldAuditEntry.note": func(s *Schema) {
+				s.Description = "why the order was held, or the note of the review"
+			},
+			"Return.id": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "assigned by the service, the return number the customer sends the products back with"
+			},
+			"Return.orderId": func(s *Schema) {
+				s.ReadOnly = true
+			},
+			"Return.status": func(s *Schema) {
+				s.ReadOnly = true
+				s.Enum = []string{string(models.ReturnAuthorized), string(models.ReturnReceived)}
+			},
+			"Return.reason": func(s *Schema) {
+				s.MaxLength = intPtr(validation.MaxReturnReasonLength)
+			},
+			"Return.lines": func(s *Schema) {
+				s.MinItems = intPtr(1)
+				s.MaxItems = intPtr(validation.MaxOrderLines)
+			},
+			"Return.currency": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "the currency of the order"
+			},
+			"Return.refund": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "what the customer gets back once the products are received, shipping isn't refunded, " + s.Description
+			},
+			"Return.customer": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "the customer of the order"
+			},
+			"ReturnLine.productCode": func(s *Schema) {
+				s.MinLength = intPtr(1)
+			},
+			"ReturnLine.quantity": func(s *Schema) {
+				s.Minimum = floatPtr(1)
+			},
+			"ReturnLine.refund": func(s *Schema) {
+				s.ReadOnly = true
+				s.Description = "the share of what was paid for the product given back for the quantity returned, " + s.Description
+			},
+			"Address.country": func(s *Schema) {
+				s.Pattern = "^[A-Z]{2}$"
+				s.Description = "ISO 3166-1 alpha-2 country code, " + validation.DefaultCountry + " if not specified"
+			},
+		},
+		required: map[string][]string{
+			"Order":    {"products", "customer"},
+			"Product":  {"productCode", "quantity"},
+			"Customer": {"emailAddress", "shippingAddress"},
+			"Address":  {"line1", "city", "postalCode"},
+
+			"CatalogueProduct": {"code", "name", "weightGrams", "dimensions"},
+			"Dimensions":       {"lengthMm", "widthMm", "heightMm"},
+
+			"Promotion": {"code", "type"},
+
+			"Return":     {"lines", "customer"},
+			"ReturnLine": {"productCode", "quantity"},
+
+			"Review": {"operator", "note"},
+		},
+	}
+
+	order := g.schema(reflect.TypeOf(models.Order{}))
+	problem := g.schema(reflect.TypeOf(validation.Problem{}))
+	batch := g.schema(reflect.TypeOf(handlers.BatchResponse{}))
+	product := g.schema(reflect.TypeOf(models.CatalogueProduct{}))
+	promotion := g.schema(reflect.TypeOf(models.Promotion{}))
+	ret := g.schema(reflect.TypeOf(models.Return{}))
+	held := g.schema(reflect.TypeOf(models.HeldOrder{}))
+	review := g.schema(reflect.TypeOf(models.Review{}))
+	audit := g.schema(reflect.TypeOf(models.HoldAuditEntry{}))
+
+	problemResponse := func(description string) Response {
+		return Response{
+			Description: description,
+			Content:     map[string]MediaType{validation.ProblemContentType: {Schema: problem}},
+		}
+	}
+	productResponse := func(description string) Response {
+		return Response{
+			Description: description,
+			Content:     map[string]MediaType{JSONContentType: {Schema: product}},
+		}
+	}
+	productCode := Parameter{Name: "code", In: "path", Required: true, Schema: &Schema{Type: "string"}}
+	promotionResponse := func(description string) Response {
+		return Response{
+			Description: description,
+			Content:     map[string]MediaType{JSONContentType: {Schema: promotion}},
+		}
+	}
+	promotionCode := Parameter{Name: "code", In: "path", Required: true, Schema: &Schema{Type: "string"}}
+	returnResponse := func(description string) Response {
+		return Response{
+			Description: description,
+			Content:     map[string]MediaType{JSONContentType: {Schema: ret}},
+		}
+	}
+	heldOrderResponse := func(description string) Response {
+		return Response{
+			Description: description,
+			Content:     map[string]MediaType{JSONContentType: {Schema: held}},
+		}
+	}
+	orderID := Parameter{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string", Format: "uuid"}}
+	returnID := Parameter{Name: "returnId", In: "path", Required: true, Schema: &Schema{Type: "string", Format: "uuid"}}
+	orderResponse := func(description string) Response {
+		return Response{
+			Description: description,
+			Content:     map[string]MediaType{JSONContentType: {Schema: order}},
+		}
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       "Order Service",
+			Description: "Receives orders and publishes them for fulfillment",
+			Version:     "1.0.0",
+		},
+		Paths: map[string]PathItem{
+			"/": {
+				"get": {
+					OperationID: "root",
+					Summary:     "Returns a HTTP 200 status code",
+					Responses:   map[string]Response{"200": {Description: "the service is running"}},
+				},
+			},
+			"/health": {
+				"get": {
+					OperationID: "health",
+					Summary:     "Returns a HTTP 200 status code indicating the service is alive",
+					Responses:   map[string]Response{"200": {Description: "the service is alive"}},
+				},
+			},
+			"/openapi.json": {
+				"get": {
+					OperationID: "openapi",
+					Summary:     "Returns this document",
+					Responses: map[string]Response{"200": {
+						Description: "the OpenAPI document of the service",
+						Content:     map[string]MediaType{JSONContentType: {Schema: &Schema{Type: "object"}}},
+					}},
+				},
+			},
+			"/orders": {
+				"post": {
+					OperationID: "receiveOrder",
+					Summary:     "Receives an order and publishes an OrderReceived event",
+					Parameters: []Parameter{
+						{Name: "Idempotency-Key", In: "header", Description: "retrying with the same key returns the original response", Schema: &Schema{Type: "string", MaxLength: intPtr(255)}},
+						{Name: "X-Correlation-ID", In: "header", Description: "correlates the events published for the request", Schema: &Schema{Type: "string"}},
+						{Name: "traceparent", In: "header", Description: "W3C trace context of the request", Schema: &Schema{Type: "string"}},
+					},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{JSONContentType: {Schema: order}},
+					},
+					Responses: map[string]Response{
+						"201": orderResponse("the order was received"),
+						"400": problemResponse("the order is invalid"),
+						"409": {Description: "an order with the external order ID was already received from the sales channel, or a request with the idempotency key is still being processed"},
+						"422": {Description: "the idempotency key was already used for a different request"},
+						"500": {Description: "the order couldn't be stored or published"},
+					},
+				},
+				"get": {
+					OperationID: "findOrder",
+					Summary:     "Returns the order received from a sales channel with the sales channel's own reference to it",
+					Parameters: []Parameter{
+						{Name: "salesChannel", In: "query", Description: "direct if not specified", Schema: &Schema{Type: "string"}},
+						{Name: "externalOrderId", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+					},
+					Responses: map[string]Response{
+						"200": orderResponse("the order"),
+						"400": problemResponse("the request is invalid"),
+						"404": {Description: "there is no such order"},
+					},
+				},
+			},
+			"/orders:batch": {
+				"post": {
+					OperationID: "receiveOrders",
+					Summary:     "Receives a batch of orders and publishes an OrderReceived event for each valid one",
+					Parameters: []Parameter{
+						{Name: "Idempotency-Key", In: "header", Description: "retrying with the same key returns the original response", Schema: &Schema{Type: "string", MaxLength: intPtr(255)}},
+						{Name: "X-Correlation-ID", In: "header", Description: "correlates the events published for the request", Schema: &Schema{Type: "string"}},
+						{Name: "traceparent", In: "header", Description: "W3C trace context of the request", Schema: &Schema{Type: "string"}},
+					},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content: map[string]MediaType{
+							JSONContentType: {Schema: &Schema{Type: "array", Items: order, MaxItems: intPtr(handlers.MaxBatchSize)}},
+							handlers.NDJSONContentType: {Schema: &Schema{
+								Type:        "string",
+								Description: "one order per line",
+							}},
+						},
+					},
+					Responses: map[string]Response{
+						"207": {
+							Description: "the result of every order, each has the status it would have got on its own",
+							Content:     map[string]MediaType{JSONContentType: {Schema: batch}},
+						},
+						"400": problemResponse("the batch can't be read"),
+						"413": problemResponse("the batch has too many orders"),
+						"500": problemResponse("the orders couldn't be stored"),
+					},
+					PerItemValidation: true,
+				},
+			},
+			"/catalogue/products": {
+				"get": {
+					OperationID: "listProducts",
+					Summary:     "Returns every product in the catalogue, ordered by code",
+					Parameters: []Parameter{
+						{Name: "active", In: "query", Description: "only return active products if true", Schema: &Schema{Type: "string"}},
+					},
+					Responses: map[string]Response{
+						"200": {
+							Description: "the products",
+							Content:     map[string]MediaType{JSONContentType: {Schema: &Schema{Type: "array", Items: product}}},
+						},
+					},
+				},
+				"post": {
+					OperationID: "createProduct",
+					Summary:     "Adds a product to the catalogue",
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{JSONContentType: {Schema: product}},
+					},
+					Responses: map[string]Response{
+						"201": productResponse("the product was added"),
+						"400": problemResponse("the product is invalid"),
+						"409": {Description: "there already is a product with the code"},
+					},
+				},
+			},
+			"/catalogue/products/{code}": {
+				"get": {
+					OperationID: "getProduct",
+					Summary:     "Returns the product with the code",
+					Parameters:  []Parameter{productCode},
+					Responses: map[string]Response{
+						"200": productResponse("the product"),
+						"404": {Description: "there is no such product"},
+					},
+				},
+				"put": {
+					OperationID: "updateProduct",
+					Summary:     "Replaces the product with the code, including its prices",
+					Parameters:  []Parameter{productCode},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{JSONContentType: {Schema: product}},
+					},
+					Responses: map[string]Response{
+						"200": productResponse("the product was replaced"),
+						"400": problemResponse("the product is invalid"),
+						"404": {Description: "there is no such product"},
+					},
+				},
+				"delete": {
+					OperationID: "deleteProduct",
+					Summary:     "Deletes the product with the code, orders that were already received keep their product names and prices",
+					Parameters:  []Parameter{productCode},
+					Responses: map[string]Response{
+						"204": {Description: "the product was deleted"},
+						"404": {Description: "there is no such product"},
+					},
+				},
+			},
+			"/promotions": {
+				"get": {
+					OperationID: "listPromotions",
+					Summary:     "Returns every promotion, ordered by code",
+					Responses: map[string]Response{
+						"200": {
+							Description: "the promotions",
+							Content:     map[string]MediaType{JSONContentType: {Schema: &Schema{Type: "array", Items: promotion}}},
+						},
+					},
+				},
+				"post": {
+					OperationID: "createPromotion",
+					Summary:     "Creates a promotion",
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{JSONContentType: {Schema: promotion}},
+					},
+					Responses: map[string]Response{
+						"201": promotionResponse("the promotion was created"),
+						"400": problemResponse("the promotion is invalid"),
+						"409": {Description: "there already is a promotion with the code"},
+					},
+				},
+			},
+			"/promotions/{code}": {
+				"get": {
+					OperationID: "getPromotion",
+					Summary:     "Returns the promotion with the code",
+					Parameters:  []Parameter{promotionCode},
+					Responses: map[string]Response{
+						"200": promotionResponse("the promotion"),
+						"404": {Description: "there is no such promotion"},
+					},
+				},
+				"put": {
+					OperationID: "updatePromotion",
+					Summary:     "Replaces the promotion with the code, it keeps the redemptions it already has",
+					Parameters:  []Parameter{promotionCode},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{JSONContentType: {Schema: promotion}},
+					},
+					Responses: map[string]Response{
+						"200": promotionResponse("the promotion was replaced"),
+						"400": problemResponse("the promotion is invalid"),
+						"404": {Description: "there is no such promotion"},
+					},
+				},
+			},
+			"/orders/{id}": {
+				"get": {
+					OperationID: "getOrder",
+					Summary:     "Returns the order with the ID",
+					Parameters:  []Parameter{orderID},
+					Responses: map[string]Response{
+						"200": orderResponse("the order"),
+						"400": problemResponse("the ID isn't valid"),
+						"404": {Description: "there is no such order"},
+					},
+				},
+			},
+			"/held-orders": {
+				"get": {
+					OperationID: "listHeldOrders",
+					Summary:     "Returns the queue of held orders with the status, the ones held longest first",
+					Parameters: []Parameter{
+						{Name: "status", In: "query", Description: string(models.HoldPending) + " (waiting to be reviewed) if not specified", Schema: &Schema{Type: "string", Enum: holdStatuses}},
+					},
+					Responses: map[string]Response{
+						"200": {
+							Description: "the held orders",
+							Content:     map[string]MediaType{JSONContentType: {Schema: &Schema{Type: "array", Items: held}}},
+						},
+					},
+				},
+			},
+			"/held-orders/{id}": {
+				"get": {
+					OperationID: "getHeldOrder",
+					Summary:     "Returns the held order with the ID, along with why it was held and its review",
+					Parameters:  []Parameter{orderID},
+					Responses: map[string]Response{
+						"200": heldOrderResponse("the held order"),
+						"400": problemResponse("the ID isn't valid"),
+						"404": {Description: "the order was never held"},
+					},
+				},
+			},
+			"/held-orders/{id}/audit": {
+				"get": {
+					OperationID: "getHeldOrderAudit",
+					Summary:     "Returns every time the order with the ID was held and reviewed, in the order it happened",
+					Parameters:  []Parameter{orderID},
+					Responses: map[string]Response{
+						"200": {
+							Description: "the audit trail of the held order",
+							Content:     map[string]MediaType{JSONContentType: {Schema: &Schema{Type: "array", Items: audit}}},
+						},
+						"400": problemResponse("the ID isn't valid"),
+						"404": {Description: "the order was never held"},
+					},
+				},
+			},
+			"/held-orders/{id}/approve": {
+				"post": {
+					OperationID: "approveHeldOrder",
+					Summary:     "Approves the held order and publishes the event that resumes the pipeline from the stage that held it",
+					Parameters: []Parameter{
+						orderID,
+						{Name: "X-Correlation-ID", In: "header", Description: "correlates the events published for the request", Schema: &Schema{Type: "string"}},
+						{Name: "traceparent", In: "header", Description: "W3C trace context of the request", Schema: &Schema{Type: "string"}},
+					},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{JSONContentType: {Schema: review}},
+					},
+					Responses: map[string]Response{
+						"200": heldOrderResponse("the order was approved"),
+						"400": problemResponse("the ID or the review isn't valid"),
+						"404": {Description: "the order was never held"},
+						"409": {Description: "the order was already reviewed, or the stage that held it can't be resumed"},
+						"500": {Description: "the review couldn't be recorded or published"},
+					},
+				},
+			},
+			"/held-orders/{id}/reject": {
+				"post": {
+					OperationID: "rejectHeldOrder",
+					Summary:     "Rejects the held order and publishes a Rejection event",
+					Parameters: []Parameter{
+						orderID,
+						{Name: "X-Correlation-ID", In: "header", Description: "correlates the events published for the request", Schema: &Schema{Type: "string"}},
+						{Name: "traceparent", In: "header", Description: "W3C trace context of the request", Schema: &Schema{Type: "string"}},
+					},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{JSONContentType: {Schema: review}},
+					},
+					Responses: map[string]Response{
+						"200": heldOrderResponse("the order was rejected"),
+						"400": problemResponse("the ID or the review isn't valid"),
+						"404": {Description: "the order was never held"},
+						"409": {Description: "the order was already reviewed"},
+						"500": {Description: "the review couldn't be recorded or published"},
+					},
+				},
+			},
+			"/orders/{id}/returns": {
+				"get": {
+					OperationID: "listReturns",
+					Summary:     "Returns every return of the order, in the order they were authorised",
+					Parameters:  []Parameter{orderID},
+					Responses: map[string]Response{
+						"200": {
+							Description: "the returns",
+							Content:     map[string]MediaType{JSONContentType: {Schema: &Schema{Type: "array", Items: ret}}},
+						},
+						"400": problemResponse("the ID isn't valid"),
+						"404": {Description: "there is no such order"},
+					},
+				},
+				"post": {
+					OperationID: "createReturn",
+					Summary:     "Authorises the return of products of the order and notifies the customer",
+					Parameters: []Parameter{
+						orderID,
+						{Name: "Idempotency-Key", In: "header", Description: "retrying with the same key returns the original response", Schema: &Schema{Type: "string", MaxLength: intPtr(255)}},
+						{Name: "X-Correlation-ID", In: "header", Description: "correlates the events published for the request", Schema: &Schema{Type: "string"}},
+						{Name: "traceparent", In: "header", Description: "W3C trace context of the request", Schema: &Schema{Type: "string"}},
+					},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{JSONContentType: {Schema: ret}},
+					},
+					Responses: map[string]Response{
+						"201": returnResponse("the return was authorised"),
+						"400": problemResponse("the return is invalid, or the products can't be returned from the order"),
+						"404": {Description: "there is no such order"},
+						"409": {Description: "the order hasn't shipped and had its payment captured, or a request with the idempotency key is still being processed"},
+						"422": {Description: "the idempotency key was already used for a different request"},
+					},
+				},
+			},
+			"/orders/{id}/returns/{returnId}": {
+				"get": {
+					OperationID: "getReturn",
+					Summary:     "Returns the return of the order with the ID",
+					Parameters:  []Parameter{orderID, returnID},
+					Responses: map[string]Response{
+						"200": returnResponse("the return"),
+						"400": problemResponse("an ID isn't valid"),
+						"404": {Description: "there is no such return"},
+					},
+				},
+			},
+			"/orders/{id}/returns/{returnId}/received": {
+				"post": {
+					OperationID: "receiveReturn",
+					Summary:     "Records the products of the return were received back and publishes a ReturnReceived event",
+					Parameters: []Parameter{
+						orderID,
+						returnID,
+						{Name: "X-Correlation-ID", In: "header", Description: "correlates the events published for the request", Schema: &Schema{Type: "string"}},
+						{Name: "traceparent", In: "header", Description: "W3C trace context of the request", Schema: &Schema{Type: "string"}},
+					},
+					Responses: map[string]Response{
+						"200": returnResponse("the return was received"),
+						"400": problemResponse("an ID isn't valid"),
+						"404": {Description: "there is no such return"},
+						"409": {Description: "the products of the return were already received"},
+						"500": {Description: "the return couldn't be recorded or published"},
+					},
+				},
+			},
+		},
+		Components: Components{Schemas: g.components},
+	}
+
+	if err := doc.compile(); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
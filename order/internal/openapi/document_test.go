@@ -0,0 +1,201 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+var pathParameter = regexp.MustCompile(`\{([^}]+)\}`)
+
+// TestNew checks the document the service serves is a valid OpenAPI document, as far as the parts the service uses go
+func TestNew(t *testing.T) {
+	doc, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var document map[string]interface{}
+	if err = json.Unmarshal(b, &document); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if document["openapi"] != Version {
+		t.Errorf("openapi = %v, want %s", document["openapi"], Version)
+	}
+	if info, _ := document["info"].(map[string]interface{}); info["title"] == "" || info["version"] == "" {
+		t.Errorf("info = %v, want a title and version", info)
+	}
+
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, problem := range checkSchemas(document, "#", schemas) {
+		t.Error(problem)
+	}
+
+	operationIDs := make(map[string]string)
+	for template, item := range doc.Paths {
+		declared := make(map[string]bool)
+		for _, m := range pathParameter.FindAllStringSubmatch(template, -1) {
+			declared[m[1]] = true
+		}
+
+		for method, op := range item {
+			operation := strings.ToUpper(method) + " " + template
+
+			if len(op.OperationID) == 0 {
+				t.Errorf("%s has no operationId", operation)
+			} else if other, found := operationIDs[op.OperationID]; found {
+				t.Errorf("%s has the same operationId as %s", operation, other)
+			}
+			operationIDs[op.OperationID] = operation
+
+			inPath := make(map[string]bool)
+			for _, p := range op.Parameters {
+				switch p.In {
+				case "path":
+					inPath[p.Name] = true
+					if !p.Required {
+						t.Errorf("%s path parameter %s isn't required", operation, p.Name)
+					}
+				case "query", "header":
+				default:
+					t.Errorf("%s parameter %s is in %q", operation, p.Name, p.In)
+				}
+				if p.Schema == nil {
+					t.Errorf("%s parameter %s has no schema", operation, p.Name)
+				}
+			}
+			if !reflect.DeepEqual(inPath, declared) {
+				t.Errorf("%s has path parameters %v, want %v", operation, keys(inPath), keys(declared))
+			}
+
+			if len(op.Responses) == 0 {
+				t.Errorf("%s has no responses", operation)
+			}
+			for status, response := range op.Responses {
+				if len(response.Description) == 0 {
+					t.Errorf("%s response %s has no description", operation, status)
+				}
+			}
+		}
+	}
+}
+
+// checkSchemas returns the problems with the schemas in the JSON value: references that don't resolve or have
+// siblings, which OpenAPI 3.0 ignores, and required properties that aren't properties
+func checkSchemas(value interface{}, path string, schemas map[string]interface{}) []string {
+	var problems []string
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if ref, found := v["$ref"].(string); found {
+			if _, resolved := schemas[strings.TrimPrefix(ref, "#/components/schemas/")]; !resolved || !strings.HasPrefix(ref, "#/components/schemas/") {
+				problems = append(problems, fmt.Sprintf("%s refers to %s, which isn't a schema", path, ref))
+			}
+			if len(v) > 1 {
+				problems = append(problems, fmt.Sprintf("%s has siblings of its $ref: %v", path, keys(v)))
+			}
+		}
+
+		if required, found := v["required"].([]interface{}); found {
+			properties, _ := v["properties"].(map[string]interface{})
+			for _, name := range required {
+				if _, found := properties[name.(string)]; !found {
+					problems = append(problems, fmt.Sprintf("%s requires %s, which isn't a property", path, name))
+				}
+			}
+		}
+
+		for _, name := range keys(v) {
+			problems = append(problems, checkSchemas(v[name], path+"/"+name, schemas)...)
+		}
+	case []interface{}:
+		for i, item := range v {
+			problems = append(problems, checkSchemas(item, fmt.Sprintf("%s/%d", path, i), schemas)...)
+		}
+	}
+
+	return problems
+}
+
+// keys returns the keys of the map in order
+func keys[V any](m map[string]V) []string {
+	k := make([]string, 0, len(m))
+	for name := range m {
+		k = append(k, name)
+	}
+	sort.Strings(k)
+
+	return k
+}
+
+// TestValidateAllOf checks the properties whose reference is wrapped in an allOf are still validated, both what was
+// added alongside the reference and the schema it refers to
+func TestValidateAllOf(t *testing.T) {
+	doc, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "valid order",
+			body: `{"products":[{"productCode":"12345","quantity":2}],"customer":{"emailAddress":"tom.hardy@email.com","shippingAddress":{"line1":"123 Anywhere St","city":"Anytown","postalCode":"12345"}}}`,
+		},
+		{
+			name: "read only reference",
+			body: `{"products":[{"productCode":"12345","quantity":2}],"customer":{"emailAddress":"tom.hardy@email.com","shippingAddress":{"line1":"123 Anywhere St","city":"Anytown","postalCode":"12345"}},"payment":{}}`,
+			want: []string{"payment read_only"},
+		},
+		{
+			name: "schema of a described reference",
+			body: `{"products":[{"productCode":"12345","quantity":2}],"customer":{"emailAddress":"tom.hardy@email.com","shippingAddress":{"line1":"123 Anywhere St","city":"Anytown","postalCode":"12345"},"billingAddress":{"city":7}}}`,
+			want: []string{"customer.billingAddress.line1 required", "customer.billingAddress.postalCode required", "customer.billingAddress.city invalid_type"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Validate(doc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			}))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tt.body)))
+
+			var got []string
+			if w.Code != http.StatusCreated {
+				var problem struct {
+					Errors validation.Errors `json:"errors"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+					t.Fatalf("Validate() = %d %s, want problem details", w.Code, w.Body.String())
+				}
+				for _, e := range problem.Errors {
+					got = append(got, e.Field+" "+string(e.Code))
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
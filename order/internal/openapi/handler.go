@@ -0,0 +1,64 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler returns a handler serving the document as JSON
+func Handler(doc *Document) http.HandlerFunc {
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.WithField("error", err.Error()).Fatal("unable to marshal the OpenAPI document")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", JSONContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
+
+// Covers returns an error naming every route of the router that isn't in the document, and every operation in the
+// document that isn't routed, so the two can't drift apart
+func Covers(doc *Document, routes chi.Routes) error {
+	routed := make(map[string]bool)
+	var problems []string
+
+	if err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/*")
+		if len(route) == 0 {
+			route = "/"
+		}
+
+		routed[method+" "+route] = true
+		if _, found := doc.Paths[route][strings.ToLower(method)]; !found {
+			problems = append(problems, fmt.Sprintf("%s %s is not documented", method, route))
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if !routed[strings.ToUpper(method)+" "+path] {
+				problems = append(problems, fmt.Sprintf("%s %s is documented but not routed", strings.ToUpper(method), path))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("the OpenAPI document doesn't match the routes: %s", strings.Join(problems, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+var (
+	uuidType  = reflect.TypeOf(uuid.UUID{})
+	timeType  = reflect.TypeOf(time.Time{})
+	moneyType = reflect.TypeOf(models.Money(0))
+)
+
+// generator builds the schemas of Go types, structs are added to the components and referenced by name so the
+// document stays in sync with the types the service decodes requests into and encodes responses from
+type generator struct {
+	components map[string]*Schema
+
+	// constraints adds the rules that can't be read from a Go type to the schema of a field, by <type>.<field>
+	constraints map[string]func(*Schema)
+
+	// required lists the fields of each type that have to be specified
+	required map[string][]string
+}
+
+func (g generator) schema(t reflect.Type) *Schema {
+	switch t {
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case moneyType:
+		return &Schema{Type: "integer", Format: "int64", Description: "amount in the minor units of the currency of the order, e.g. cents"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Struct:
+		return g.ref(t)
+	}
+
+	return &Schema{}
+}
+
+// ref adds the schema of the struct to the components, unless it's already there, and returns a reference to it
+func (g generator) ref(t reflect.Type) *Schema {
+	ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+	if _, found := g.components[t.Name()]; found {
+		return ref
+	}
+
+	closed := false
+	s := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		Required:             g.required[t.Name()],
+		AdditionalProperties: &closed,
+	}
+	g.components[t.Name()] = s
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if len(tagName) > 0 {
+				name = tagName
+			}
+		}
+
+		property := g.schema(f.Type)
+		if constrain, found := g.constraints[t.Name()+"."+name]; found {
+			constrain(property)
+		}
+		s.Properties[name] = allOf(property)
+	}
+
+	return ref
+}
+
+// allOf returns the schema with its reference wrapped in an allOf if anything was added alongside it, since OpenAPI
+// 3.0 ignores the siblings of a $ref
+func allOf(s *Schema) *Schema {
+	if len(s.Ref) == 0 || reflect.DeepEqual(*s, Schema{Ref: s.Ref}) {
+		return s
+	}
+
+	wrapped := *s
+	wrapped.AllOf = []*Schema{{Ref: s.Ref}}
+	wrapped.Ref = ""
+
+	return &wrapped
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func floatPtr(n float64) *float64 {
+	return &n
+}
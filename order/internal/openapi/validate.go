@@ -0,0 +1,358 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+// Validate returns a middleware validating requests against the operation of the document they're for. Every
+// problem with the parameters and JSON body of a request is returned as RFC 7807 problem details, requests for
+// routes that aren't in the document are passed on as they are.
+func Validate(doc *Document) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, params := doc.operation(r.Method, r.URL.Path)
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var errs validation.Errors
+			for _, p := range op.Parameters {
+				var value string
+				switch p.In {
+				case "path":
+					value = params[p.Name]
+				case "query":
+					value = r.URL.Query().Get(p.Name)
+				case "header":
+					value = r.Header.Get(p.Name)
+				}
+
+				if len(value) == 0 {
+					if p.Required {
+						errs.Add(p.Name, validation.Required, "is required")
+					}
+					continue
+				}
+
+				doc.check(value, p.Schema, p.Name, &errs)
+			}
+
+			if op.RequestBody != nil && !op.PerItemValidation {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					validation.WriteProblem(w, validation.Problem{Status: http.StatusBadRequest, Detail: err.Error()})
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+
+				if len(bytes.TrimSpace(body)) == 0 {
+					if op.RequestBody.Required {
+						validation.WriteProblem(w, validation.Problem{Status: http.StatusBadRequest, Detail: "a request body is required"})
+						return
+					}
+				} else if media, found := op.RequestBody.Content[JSONContentType]; found {
+					d := json.NewDecoder(bytes.NewReader(body))
+					d.UseNumber()
+
+					var document interface{}
+					if err = d.Decode(&document); err != nil {
+						validation.WriteProblem(w, validation.Problem{
+							Status: http.StatusBadRequest,
+							Detail: fmt.Sprintf("the request body isn't valid JSON: %s", err.Error()),
+						})
+						return
+					}
+
+					doc.check(document, media.Schema, "", &errs)
+				}
+			}
+
+			if len(errs) > 0 {
+				log.WithField("operation", op.OperationID).
+					WithField("errors", errs).
+					Info("request doesn't match the OpenAPI document")
+				validation.WriteErrors(w, errs)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// compile compiles the pattern of every schema in the document, returning an error naming the first one that
+// isn't a valid regular expression
+func (d *Document) compile() error {
+	compiled := make(map[*Schema]bool)
+
+	var walk func(s *Schema, path string) error
+	walk = func(s *Schema, path string) error {
+		if s == nil || compiled[s] {
+			return nil
+		}
+		compiled[s] = true
+
+		if len(s.Pattern) > 0 {
+			p, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				return fmt.Errorf("the pattern of %s is invalid: %w", path, err)
+			}
+			s.pattern = p
+		}
+
+		for name, property := range s.Properties {
+			if err := walk(property, path+"."+name); err != nil {
+				return err
+			}
+		}
+
+		for _, sub := range s.AllOf {
+			if err := walk(sub, path); err != nil {
+				return err
+			}
+		}
+
+		return walk(s.Items, path+"[]")
+	}
+
+	for name, s := range d.Components.Schemas {
+		if err := walk(s, name); err != nil {
+			return err
+		}
+	}
+
+	for template, item := range d.Paths {
+		for method, op := range item {
+			operation := strings.ToUpper(method) + " " + template
+			for _, p := range op.Parameters {
+				if err := walk(p.Schema, operation+" "+p.Name); err != nil {
+					return err
+				}
+			}
+
+			if op.RequestBody == nil {
+				continue
+			}
+			for _, media := range op.RequestBody.Content {
+				if err := walk(media.Schema, operation+" body"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// operation returns the operation for the method and path, along with the values of its path parameters
+func (d *Document) operation(method, path string) (*Operation, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for template, item := range d.Paths {
+		op, found := item[strings.ToLower(method)]
+		if !found {
+			continue
+		}
+
+		templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+		if len(templateSegments) != len(segments) {
+			continue
+		}
+
+		params := make(map[string]string)
+		matched := true
+		for i, s := range templateSegments {
+			if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+				params[strings.Trim(s, "{}")] = segments[i]
+			} else if s != segments[i] {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return op, params
+		}
+	}
+
+	return nil, nil
+}
+
+// check validates the value against the schema, recording every problem with it
+func (d *Document) check(value interface{}, s *Schema, path string, errs *validation.Errors) {
+	if s == nil || value == nil {
+		return
+	}
+	if len(s.Ref) > 0 {
+		d.check(value, d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")], path, errs)
+		return
+	}
+	for _, sub := range s.AllOf {
+		d.check(value, sub, path, errs)
+	}
+
+	switch s.Type {
+	case "object":
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			errs.Add(path, validation.InvalidType, "should be an object")
+			return
+		}
+
+		// read only properties are only required in responses
+		for _, name := range s.Required {
+			if property, ok := s.Properties[name]; ok && property.ReadOnly {
+				continue
+			}
+			if _, found := fields[name]; !found {
+				errs.Add(join(path, name), validation.Required, "is required")
+			}
+		}
+
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			property, found := s.Properties[name]
+			switch {
+			case !found && s.AdditionalProperties != nil && !*s.AdditionalProperties:
+				errs.Add(join(path, name), validation.Unknown, "is not a known field")
+			case found && property.ReadOnly:
+				errs.Add(join(path, name), validation.ReadOnly, "is assigned by the service")
+			case found:
+				d.check(fields[name], property, join(path, name), errs)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			errs.Add(path, validation.InvalidType, "should be an array")
+			return
+		}
+
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			if len(items) == 0 {
+				errs.Add(path, validation.Required, "should not be empty")
+			} else {
+				errs.Add(path, validation.TooShort, fmt.Sprintf("should have at least %d items", *s.MinItems))
+			}
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			errs.Add(path, validation.TooLong, fmt.Sprintf("can't have more than %d items", *s.MaxItems))
+		}
+
+		for i, item := range items {
+			d.check(item, s.Items, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			errs.Add(path, validation.InvalidType, "should be a string")
+			return
+		}
+
+		checkString(str, s, path, errs)
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			errs.Add(path, validation.InvalidType, "should be a number")
+			return
+		}
+
+		i, err := n.Int64()
+		if err != nil {
+			errs.Add(path, validation.InvalidType, "should be a whole number")
+			return
+		}
+
+		if s.Minimum != nil && float64(i) < *s.Minimum {
+			errs.Add(path, validation.OutOfRange, fmt.Sprintf("should be at least %v", *s.Minimum))
+		}
+		if s.Maximum != nil && float64(i) > *s.Maximum {
+			errs.Add(path, validation.OutOfRange, fmt.Sprintf("should be at most %v", *s.Maximum))
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			errs.Add(path, validation.InvalidType, "should be a number")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs.Add(path, validation.InvalidType, "should be a boolean")
+		}
+	}
+}
+
+// checkString validates the length, pattern and format of a string
+func checkString(str string, s *Schema, path string, errs *validation.Errors) {
+	if s.MinLength != nil && len(str) < *s.MinLength {
+		if len(str) == 0 {
+			errs.Add(path, validation.Required, "is required")
+		} else {
+			errs.Add(path, validation.TooShort, fmt.Sprintf("should be at least %d characters", *s.MinLength))
+		}
+	}
+	if s.MaxLength != nil && len(str) > *s.MaxLength {
+		errs.Add(path, validation.TooLong, fmt.Sprintf("can't be longer than %d characters", *s.MaxLength))
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		errs.Add(path, validation.InvalidFormat, fmt.Sprintf("should match %s", s.Pattern))
+	}
+	if len(s.Enum) > 0 && !contains(s.Enum, str) {
+		errs.Add(path, validation.InvalidFormat, fmt.Sprintf("should be one of %s", strings.Join(s.Enum, ", ")))
+	}
+
+	var err error
+	switch s.Format {
+	case "uuid":
+		_, err = uuid.Parse(str)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, str)
+	case "email":
+		var a *mail.Address
+		if a, err = mail.ParseAddress(str); err == nil && a.Address != str {
+			err = fmt.Errorf("should be an email address without a name")
+		}
+	}
+	if err != nil {
+		errs.Add(path, validation.InvalidFormat, fmt.Sprintf("is not a valid %s", s.Format))
+	}
+}
+
+// join returns the path of a field of the object at the path
+func join(path, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// contains returns true if the value is one of the values
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
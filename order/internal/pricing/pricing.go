@@ -0,0 +1,201 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/promotions"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/tax"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+// shippingRate is the flat rate charged to ship an order, unless its subtotal reaches the free shipping threshold
+type shippingRate struct {
+	flat         models.Money
+	freeShipping models.Money
+}
+
+// shippingRates are the shipping rates of each supported currency, in its minor units
+var shippingRates = map[string]shippingRate{
+	"USD": {flat: 599, freeShipping: 5000},
+	"CAD": {flat: 799, freeShipping: 6500},
+	"MXN": {flat: 9900, freeShipping: 100000},
+	"EUR": {flat: 599, freeShipping: 5000},
+	"GBP": {flat: 499, freeShipping: 4000},
+	"AUD": {flat: 899, freeShipping: 7500},
+	"BRL": {flat: 2990, freeShipping: 25000},
+	"INR": {flat: 9900, freeShipping: 200000},
+	"JPY": {flat: 800, freeShipping: 6000},
+}
+
+// Price checks every product of the order is an active product of the catalogue (products by code), then fills in
+// its name, unit price and line total, and the totals of the order, in the currency of the order. Unit prices the
+// client specified have to match the catalogue. Every product that isn't in the catalogue, is inactive, has no price
+// in the currency or a different unit price is returned as a problem. The promotion of the order, if it has one,
+// is applied to the priced products before the totals (see promotions.Discount). The tax is added by Tax.
+func Price(o *models.Order, products map[string]models.CatalogueProduct, promotion *models.Promotion) validation.Errors {
+	var errs validation.Errors
+
+	var subtotal models.Money
+	for i := range o.Products {
+		p := &o.Products[i]
+
+		product, found := products[p.ProductCode]
+		if !found {
+			errs.Add(fmt.Sprintf("products[%d].productCode", i), validation.NotFound, "the product is not in the catalogue")
+			continue
+		}
+
+		if !product.Active {
+			errs.Add(fmt.Sprintf("products[%d].productCode", i), validation.Inactive, "the product can't be ordered any more")
+			continue
+		}
+
+		price, found := product.Prices[o.Currency]
+		if !found {
+			errs.Add(fmt.Sprintf("products[%d].productCode", i), validation.NotFound, fmt.Sprintf("there is no %s price for the product", o.Currency))
+			continue
+		}
+
+		if p.UnitPrice != 0 && p.UnitPrice != price {
+			errs.Add(fmt.Sprintf("products[%d].unitPrice", i), validation.Mismatch, fmt.Sprintf("the unit price of the product is %s", price.Format(o.Currency)))
+			continue
+		}
+
+		lineTotal, err := price.Times(p.Quantity)
+		if err != nil {
+			errs.Add(fmt.Sprintf("products[%d].quantity", i), validation.OutOfRange, "the line total of the product is too large")
+			continue
+		}
+
+		p.Name = product.Name
+		p.UnitPrice = price
+		p.LineTotal = lineTotal
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	lineTotals := make([]models.Money, len(o.Products))
+	for i, p := range o.Products {
+		lineTotals[i] = p.LineTotal
+	}
+
+	subtotal, err := models.Sum(lineTotals...)
+	if err != nil {
+		errs.Add("products", validation.OutOfRange, "the subtotal of the order is too large")
+		return errs
+	}
+
+	var freeShipping bool
+	if promotion != nil {
+		if freeShipping, errs = promotions.Discount(o, *promotion); len(errs) > 0 {
+			return errs
+		}
+	}
+
+	// discounts are never more than the line totals, so they can't overflow if the subtotal doesn't
+	var discount models.Money
+	for _, p := range o.Products {
+		discount += p.Discount
+	}
+
+	if o.Totals, err = totals(o.Currency, subtotal, discount, freeShipping); err != nil {
+		errs.Add("products", validation.OutOfRange, "the total of the order is too large")
+		return errs
+	}
+
+	return nil
+}
+
+// totals returns the totals of an order with the subtotal and discount, shipping is worked out from what is left
+// after the discount, and tax is added by Tax
+func totals(currency string, subtotal, discount models.Money, freeShipping bool) (models.Totals, error) {
+	discounted := subtotal - discount
+
+	var shipping models.Money
+	if rate := shippingRates[currency]; !freeShipping && discounted < rate.freeShipping {
+		shipping = rate.flat
+	}
+
+	total, err := models.Sum(discounted, shipping)
+	if err != nil {
+		return models.Totals{}, err
+	}
+
+	return models.Totals{
+		Subtotal: subtotal,
+		Discount: discount,
+		Shipping: shipping,
+		Total:    total,
+	}, nil
+}
+
+// Tax has the calculator work out the tax of each product of a priced order, on its line total less its discount,
+// and adds it to the totals. The tax category of each product comes from the catalogue (products by code). It returns
+// an error wrapping models.ErrOverflow if the tax or total of the order is too large.
+func Tax(ctx context.Context, calculator tax.Calculator, o *models.Order, products map[string]models.CatalogueProduct) error {
+	r := tax.Request{
+		Currency: o.Currency,
+		Address:  o.Customer.ShippingAddress,
+		Lines:    make([]tax.Line, len(o.Products)),
+	}
+	for i, p := range o.Products {
+		category := products[p.ProductCode].TaxCategory
+		if len(category) == 0 {
+			category = models.DefaultTaxCategory
+		}
+
+		r.Lines[i] = tax.Line{
+			ProductCode: p.ProductCode,
+			TaxCategory: category,
+			Amount:      p.LineTotal - p.Discount,
+		}
+	}
+
+	taxes, err := calculator.Calculate(ctx, r)
+	if err != nil {
+		return err
+	}
+	if len(taxes) != len(o.Products) {
+		return fmt.Errorf("the tax calculator returned the tax of %d products for %d", len(taxes), len(o.Products))
+	}
+
+	for i := range o.Products {
+		o.Products[i].Tax = taxes[i]
+	}
+
+	tax, err := models.Sum(taxes...)
+	if err != nil {
+		return fmt.Errorf("the tax of the order is too large: %w", err)
+	}
+
+	total, err := models.Sum(o.Totals.Subtotal-o.Totals.Discount, o.Totals.Shipping, tax)
+	if err != nil {
+		return fmt.Errorf("the total of the order is too large: %w", err)
+	}
+
+	o.Totals.Tax = tax
+	o.Totals.Total = total
+
+	return nil
+}
+
+// ProductCodes returns the code of every product in the orders, so their prices can be looked up at once
+func ProductCodes(orders ...*models.Order) []string {
+	seen := make(map[string]bool)
+
+	var codes []string
+	for _, o := range orders {
+		for _, p := range o.Products {
+			if !seen[p.ProductCode] {
+				seen[p.ProductCode] = true
+				codes = append(codes, p.ProductCode)
+			}
+		}
+	}
+
+	return codes
+}
@@ -0,0 +1,228 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/tax"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+var catalogue = map[string]models.CatalogueProduct{
+	"12345": {Code: "12345", Name: "Nitrile Gloves (100)", Active: true, Prices: map[string]models.Money{"USD": 1299, "JPY": 1500}},
+	"67890": {Code: "67890", Name: "Face Shield", Active: true, Prices: map[string]models.Money{"USD": 2500}},
+	"55555": {Code: "55555", Name: "Paper Gown", Active: false, Prices: map[string]models.Money{"USD": 800}},
+	"99999": {Code: "99999", Name: "Bulk Pallet", Active: true, Prices: map[string]models.Money{"USD": math.MaxInt64 / 2}},
+}
+
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		name      string
+		currency  string
+		products  []models.Product
+		promotion *models.Promotion
+		want      models.Totals
+		wantLines []models.Money
+		wantErrs  []string
+	}{
+		{
+			name:      "under the free shipping threshold",
+			currency:  "USD",
+			products:  []models.Product{{ProductCode: "12345", Quantity: 2}},
+			want:      models.Totals{Subtotal: 2598, Shipping: 599, Total: 3197},
+			wantLines: []models.Money{2598},
+		},
+		{
+			name:      "free shipping threshold reached",
+			currency:  "USD",
+			products:  []models.Product{{ProductCode: "12345", Quantity: 2}, {ProductCode: "67890", Quantity: 1}},
+			want:      models.Totals{Subtotal: 5098, Total: 5098},
+			wantLines: []models.Money{2598, 2500},
+		},
+		{
+			name:      "discount takes the order under the threshold",
+			currency:  "USD",
+			products:  []models.Product{{ProductCode: "12345", Quantity: 2}, {ProductCode: "67890", Quantity: 1}},
+			promotion: &models.Promotion{Code: "SAVE10", Type: models.PercentageOff, PercentOff: 10, Active: true},
+			want:      models.Totals{Subtotal: 5098, Discount: 510, Shipping: 599, Total: 5187},
+			wantLines: []models.Money{2598, 2500},
+		},
+		{
+			name:      "free shipping promotion",
+			currency:  "USD",
+			products:  []models.Product{{ProductCode: "12345", Quantity: 1}},
+			promotion: &models.Promotion{Code: "SHIPFREE", Type: models.FreeShipping, Active: true},
+			want:      models.Totals{Subtotal: 1299, Total: 1299},
+			wantLines: []models.Money{1299},
+		},
+		{
+			name:      "currency without minor units",
+			currency:  "JPY",
+			products:  []models.Product{{ProductCode: "12345", Quantity: 3}},
+			want:      models.Totals{Subtotal: 4500, Shipping: 800, Total: 5300},
+			wantLines: []models.Money{4500},
+		},
+		{
+			name:      "unit price matching the catalogue",
+			currency:  "USD",
+			products:  []models.Product{{ProductCode: "12345", Quantity: 1, UnitPrice: 1299}},
+			want:      models.Totals{Subtotal: 1299, Shipping: 599, Total: 1898},
+			wantLines: []models.Money{1299},
+		},
+		{
+			name:     "unit price not matching the catalogue",
+			currency: "USD",
+			products: []models.Product{{ProductCode: "12345", Quantity: 1, UnitPrice: 999}},
+			wantErrs: []string{"products[0].unitPrice"},
+		},
+		{
+			name:     "not in the catalogue",
+			currency: "USD",
+			products: []models.Product{{ProductCode: "00000", Quantity: 1}},
+			wantErrs: []string{"products[0].productCode"},
+		},
+		{
+			name:     "inactive",
+			currency: "USD",
+			products: []models.Product{{ProductCode: "55555", Quantity: 1}},
+			wantErrs: []string{"products[0].productCode"},
+		},
+		{
+			name:     "no price in the currency",
+			currency: "JPY",
+			products: []models.Product{{ProductCode: "12345", Quantity: 1}, {ProductCode: "67890", Quantity: 1}},
+			wantErrs: []string{"products[1].productCode"},
+		},
+		{
+			name:     "line total overflows",
+			currency: "USD",
+			products: []models.Product{{ProductCode: "99999", Quantity: 3}},
+			wantErrs: []string{"products[0].quantity"},
+		},
+		{
+			name:     "subtotal overflows",
+			currency: "USD",
+			products: []models.Product{{ProductCode: "99999", Quantity: 2}, {ProductCode: "12345", Quantity: 1}},
+			wantErrs: []string{"products"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := models.Order{Currency: tt.currency, Products: tt.products}
+
+			errs := Price(&o, catalogue, tt.promotion)
+			if got := fields(errs); !reflect.DeepEqual(got, tt.wantErrs) {
+				t.Fatalf("Price() problems = %v, want %v", errs, tt.wantErrs)
+			}
+			if len(tt.wantErrs) > 0 {
+				return
+			}
+
+			if o.Totals != tt.want {
+				t.Errorf("Price() totals = %+v, want %+v", o.Totals, tt.want)
+			}
+			for i, p := range o.Products {
+				if p.LineTotal != tt.wantLines[i] {
+					t.Errorf("Price() line total of products[%d] = %d, want %d", i, p.LineTotal, tt.wantLines[i])
+				}
+				if p.Name != catalogue[p.ProductCode].Name {
+					t.Errorf("Price() name of products[%d] = %s, want %s", i, p.Name, catalogue[p.ProductCode].Name)
+				}
+			}
+		})
+	}
+}
+
+// calculator is a tax calculator returning the same taxes for every request
+type calculator []models.Money
+
+func (c calculator) Calculate(ctx context.Context, r tax.Request) ([]models.Money, error) {
+	return c, nil
+}
+
+func TestTax(t *testing.T) {
+	tests := []struct {
+		name       string
+		totals     models.Totals
+		calculator tax.Calculator
+		want       models.Totals
+		wantErr    bool
+		overflows  bool
+	}{
+		{
+			name:       "adds the tax of every product",
+			totals:     models.Totals{Subtotal: 5098, Discount: 510, Shipping: 599, Total: 5187},
+			calculator: calculator{169, 163},
+			want:       models.Totals{Subtotal: 5098, Discount: 510, Shipping: 599, Tax: 332, Total: 5519},
+		},
+		{
+			name:       "taxed by the rules",
+			totals:     models.Totals{Subtotal: 5098, Shipping: 0, Total: 5098},
+			calculator: tax.RuleTable{DefaultRate: 725},
+			want:       models.Totals{Subtotal: 5098, Tax: 369, Total: 5467}, // 188.355 and 181.25
+		},
+		{
+			name:       "tax overflows",
+			totals:     models.Totals{Subtotal: 5098, Total: 5098},
+			calculator: calculator{math.MaxInt64, 1},
+			wantErr:    true,
+			overflows:  true,
+		},
+		{
+			name:       "total overflows",
+			totals:     models.Totals{Subtotal: math.MaxInt64 - 10, Total: math.MaxInt64 - 10},
+			calculator: calculator{5, 6},
+			wantErr:    true,
+			overflows:  true,
+		},
+		{
+			name:       "wrong number of taxes",
+			totals:     models.Totals{Subtotal: 5098, Total: 5098},
+			calculator: calculator{169},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := models.Order{
+				Currency: "USD",
+				Products: []models.Product{
+					{ProductCode: "12345", Quantity: 2, UnitPrice: 1299, LineTotal: 2598, Discount: 260},
+					{ProductCode: "67890", Quantity: 1, UnitPrice: 2500, LineTotal: 2500, Discount: 250},
+				},
+				Totals: tt.totals,
+			}
+			if tt.totals.Discount == 0 {
+				o.Products[0].Discount, o.Products[1].Discount = 0, 0
+			}
+
+			err := Tax(context.Background(), tt.calculator, &o, catalogue)
+			if (err != nil) != tt.wantErr || errors.Is(err, models.ErrOverflow) != tt.overflows {
+				t.Fatalf("Tax() error = %v, want error %v, overflow %v", err, tt.wantErr, tt.overflows)
+			}
+			if err != nil {
+				return
+			}
+
+			if o.Totals != tt.want {
+				t.Errorf("Tax() totals = %+v, want %+v", o.Totals, tt.want)
+			}
+		})
+	}
+}
+
+// fields returns the field of every problem, nil if there are none
+func fields(errs validation.Errors) []string {
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+
+	return fields
+}
@@ -0,0 +1,107 @@
+package promotions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+// Check returns the problems with applying the promotion to the order at the time, a promotion can only be applied
+// while it's active and within its validity window, and fixed promotions only to orders in a currency they have an
+// amount for
+func Check(p models.Promotion, o models.Order, now time.Time) validation.Errors {
+	var errs validation.Errors
+
+	switch {
+	case !p.Active:
+		errs.Add("promotionCode", validation.Inactive, "the promotion has been withdrawn")
+	case p.ValidFrom != nil && now.Before(*p.ValidFrom):
+		errs.Add("promotionCode", validation.Expired, "the promotion hasn't started yet")
+	case p.ValidUntil != nil && !now.Before(*p.ValidUntil):
+		errs.Add("promotionCode", validation.Expired, "the promotion has ended")
+	}
+
+	if _, found := p.AmountsOff[o.Currency]; p.Type == models.FixedAmountOff && !found {
+		errs.Add("promotionCode", validation.NotFound, fmt.Sprintf("the promotion can't be used for orders in %s", o.Currency))
+	}
+
+	return errs
+}
+
+// Limits returns the problems with redeeming the promotion again, given how many times it has been redeemed
+// altogether and by the customer
+func Limits(p models.Promotion, redeemed, redeemedByCustomer int) validation.Errors {
+	var errs validation.Errors
+
+	if p.MaxRedemptions > 0 && redeemed >= p.MaxRedemptions {
+		errs.Add("promotionCode", validation.UsageLimit, "the promotion has been used up")
+	}
+
+	if p.MaxRedemptionsPerCustomer > 0 && redeemedByCustomer >= p.MaxRedemptionsPerCustomer {
+		errs.Add("promotionCode", validation.UsageLimit, "the customer has already used the promotion as many times as they can")
+	}
+
+	return errs
+}
+
+// Discount fills in the discount of each eligible product of a priced order, and returns true if the promotion
+// waives its shipping. Percentage discounts are rounded per line, fixed discounts are spread over the eligible
+// products in proportion to their line totals and never more than them. An order without eligible products is a
+// problem.
+func Discount(o *models.Order, p models.Promotion) (bool, validation.Errors) {
+	var eligible []int
+	var eligibleTotal models.Money
+	for i, product := range o.Products {
+		if p.Eligible(product.ProductCode) {
+			eligible = append(eligible, i)
+			eligibleTotal += product.LineTotal
+		}
+	}
+
+	if len(eligible) == 0 {
+		return false, validation.Errors{{
+			Field:   "promotionCode",
+			Code:    validation.Ineligible,
+			Message: "none of the products of the order are eligible for the promotion",
+		}}
+	}
+
+	switch p.Type {
+	case models.PercentageOff:
+		for _, i := range eligible {
+			o.Products[i].Discount = o.Products[i].LineTotal.BasisPoints(int64(p.PercentOff) * 100)
+		}
+	case models.FixedAmountOff:
+		amount := p.AmountsOff[o.Currency]
+		if amount > eligibleTotal {
+			amount = eligibleTotal
+		}
+		if eligibleTotal == 0 {
+			break
+		}
+
+		var spread models.Money
+		for _, i := range eligible {
+			share := models.Money(int64(amount) * int64(o.Products[i].LineTotal) / int64(eligibleTotal))
+			o.Products[i].Discount = share
+			spread += share
+		}
+
+		// the shares are rounded down, so what's left is a minor unit each for the first lines with room for it
+		for _, i := range eligible {
+			if spread == amount {
+				break
+			}
+			if o.Products[i].Discount < o.Products[i].LineTotal {
+				o.Products[i].Discount++
+				spread++
+			}
+		}
+	case models.FreeShipping:
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,227 @@
+package promotions
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+// priced returns a priced order in USD with a product of each line total, coded A, B, C...
+func priced(lineTotals ...models.Money) *models.Order {
+	o := &models.Order{Currency: "USD"}
+	for i, total := range lineTotals {
+		o.Products = append(o.Products, models.Product{ProductCode: string(rune('A' + i)), Quantity: 1, UnitPrice: total, LineTotal: total})
+	}
+
+	return o
+}
+
+func TestDiscount(t *testing.T) {
+	tests := []struct {
+		name         string
+		order        *models.Order
+		promotion    models.Promotion
+		want         []models.Money
+		freeShipping bool
+		wantErrs     validation.Errors
+	}{
+		{
+			name:      "percentage off every product",
+			order:     priced(2598, 2500),
+			promotion: models.Promotion{Type: models.PercentageOff, PercentOff: 10},
+			want:      []models.Money{260, 250},
+		},
+		{
+			name:      "percentage rounded per line",
+			order:     priced(1299, 1299, 1299),
+			promotion: models.Promotion{Type: models.PercentageOff, PercentOff: 15},
+			want:      []models.Money{195, 195, 195}, // 194.85 each, 585 rather than 584.55 rounded once
+		},
+		{
+			name:      "percentage off eligible products only",
+			order:     priced(2598, 2500),
+			promotion: models.Promotion{Type: models.PercentageOff, PercentOff: 10, ProductCodes: []string{"B"}},
+			want:      []models.Money{0, 250},
+		},
+		{
+			name:      "everything off",
+			order:     priced(2598, 2500),
+			promotion: models.Promotion{Type: models.PercentageOff, PercentOff: 100},
+			want:      []models.Money{2598, 2500},
+		},
+		{
+			name:      "fixed amount spread in proportion",
+			order:     priced(3000, 1000),
+			promotion: models.Promotion{Type: models.FixedAmountOff, AmountsOff: map[string]models.Money{"USD": 1000}},
+			want:      []models.Money{750, 250},
+		},
+		{
+			name:      "fixed amount remainder goes to the first lines",
+			order:     priced(1000, 1000, 1000),
+			promotion: models.Promotion{Type: models.FixedAmountOff, AmountsOff: map[string]models.Money{"USD": 1000}},
+			want:      []models.Money{334, 333, 333},
+		},
+		{
+			name:      "fixed amount over the eligible products",
+			order:     priced(500, 300, 9999),
+			promotion: models.Promotion{Type: models.FixedAmountOff, AmountsOff: map[string]models.Money{"USD": 2000}, ProductCodes: []string{"A", "B"}},
+			want:      []models.Money{500, 300, 0},
+		},
+		{
+			name:      "fixed amount remainder on a small line",
+			order:     priced(1, 1000),
+			promotion: models.Promotion{Type: models.FixedAmountOff, AmountsOff: map[string]models.Money{"USD": 1000}},
+			want:      []models.Money{1, 999}, // shares of 0.999 and 999.000 rounded down, then a unit left over
+		},
+		{
+			name:      "fixed amount off free products",
+			order:     priced(0, 0),
+			promotion: models.Promotion{Type: models.FixedAmountOff, AmountsOff: map[string]models.Money{"USD": 1000}},
+			want:      []models.Money{0, 0},
+		},
+		{
+			name:         "free shipping",
+			order:        priced(2598),
+			promotion:    models.Promotion{Type: models.FreeShipping},
+			want:         []models.Money{0},
+			freeShipping: true,
+		},
+		{
+			name:      "no eligible products",
+			order:     priced(2598),
+			promotion: models.Promotion{Type: models.PercentageOff, PercentOff: 10, ProductCodes: []string{"Z"}},
+			wantErrs:  validation.Errors{{Field: "promotionCode", Code: validation.Ineligible, Message: "none of the products of the order are eligible for the promotion"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freeShipping, errs := Discount(tt.order, tt.promotion)
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Fatalf("Discount() problems = %v, want %v", errs, tt.wantErrs)
+			}
+			if freeShipping != tt.freeShipping {
+				t.Errorf("Discount() free shipping = %v, want %v", freeShipping, tt.freeShipping)
+			}
+			if len(tt.wantErrs) > 0 {
+				return
+			}
+
+			for i, p := range tt.order.Products {
+				if p.Discount != tt.want[i] {
+					t.Errorf("Discount() discount of products[%d] = %d, want %d", i, p.Discount, tt.want[i])
+				}
+				if p.Discount > p.LineTotal {
+					t.Errorf("Discount() discount of products[%d] is more than its line total", i)
+				}
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2022, 3, 14, 15, 9, 26, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		currency  string
+		want      []validation.Code
+	}{
+		{
+			name:      "active",
+			promotion: models.Promotion{Type: models.PercentageOff, PercentOff: 10, Active: true},
+			currency:  "USD",
+		},
+		{
+			name:      "within its window",
+			promotion: models.Promotion{Type: models.PercentageOff, PercentOff: 10, Active: true, ValidFrom: &before, ValidUntil: &after},
+			currency:  "USD",
+		},
+		{
+			name:      "withdrawn",
+			promotion: models.Promotion{Type: models.PercentageOff, PercentOff: 10},
+			currency:  "USD",
+			want:      []validation.Code{validation.Inactive},
+		},
+		{
+			name:      "not started",
+			promotion: models.Promotion{Type: models.PercentageOff, PercentOff: 10, Active: true, ValidFrom: &after},
+			currency:  "USD",
+			want:      []validation.Code{validation.Expired},
+		},
+		{
+			name:      "ended",
+			promotion: models.Promotion{Type: models.PercentageOff, PercentOff: 10, Active: true, ValidUntil: &now},
+			currency:  "USD",
+			want:      []validation.Code{validation.Expired},
+		},
+		{
+			name:      "fixed amount in the currency",
+			promotion: models.Promotion{Type: models.FixedAmountOff, AmountsOff: map[string]models.Money{"USD": 500}, Active: true},
+			currency:  "USD",
+		},
+		{
+			name:      "fixed amount in another currency",
+			promotion: models.Promotion{Type: models.FixedAmountOff, AmountsOff: map[string]models.Money{"USD": 500}, Active: true},
+			currency:  "EUR",
+			want:      []validation.Code{validation.NotFound},
+		},
+		{
+			name:      "withdrawn in another currency",
+			promotion: models.Promotion{Type: models.FixedAmountOff, AmountsOff: map[string]models.Money{"USD": 500}},
+			currency:  "EUR",
+			want:      []validation.Code{validation.Inactive, validation.NotFound},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []validation.Code
+			for _, e := range Check(tt.promotion, models.Order{Currency: tt.currency}, now) {
+				got = append(got, e.Code)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name               string
+		maxRedemptions     int
+		maxPerCustomer     int
+		redeemed           int
+		redeemedByCustomer int
+		want               int
+	}{
+		{name: "no limits", redeemed: 1000, redeemedByCustomer: 10},
+		{name: "under the limits", maxRedemptions: 100, maxPerCustomer: 2, redeemed: 99, redeemedByCustomer: 1},
+		{name: "used up", maxRedemptions: 100, redeemed: 100, want: 1},
+		{name: "used up by the customer", maxPerCustomer: 1, redeemed: 5, redeemedByCustomer: 1, want: 1},
+		{name: "both", maxRedemptions: 100, maxPerCustomer: 1, redeemed: 100, redeemedByCustomer: 1, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := models.Promotion{MaxRedemptions: tt.maxRedemptions, MaxRedemptionsPerCustomer: tt.maxPerCustomer}
+
+			errs := Limits(p, tt.redeemed, tt.redeemedByCustomer)
+			if len(errs) != tt.want {
+				t.Errorf("Limits() = %v, want %d problems", errs, tt.want)
+			}
+			for _, e := range errs {
+				if e.Code != validation.UsageLimit {
+					t.Errorf("Limits() code = %s, want %s", e.Code, validation.UsageLimit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package returns
+
+import (
+	"fmt"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+// Returned adds up the quantity of each product returned by the returns of an order
+func Returned(returns []models.Return) map[string]int {
+	returned := make(map[string]int)
+	for _, r := range returns {
+		for _, l := range r.Lines {
+			returned[l.ProductCode] += l.Quantity
+		}
+	}
+
+	return returned
+}
+
+// Check returns the problems with returning the lines of a return from the order, given the quantity of each product
+// already returned (see Returned). Only products of the order can be returned, and no more of them than were ordered.
+func Check(r models.Return, o models.Order, returned map[string]int) validation.Errors {
+	var errs validation.Errors
+
+	ordered, _ := lines(o)
+	for i, l := range r.Lines {
+		quantity, found := ordered[l.ProductCode]
+		if !found {
+			errs.Add(fmt.Sprintf("lines[%d].productCode", i), validation.NotFound, "the product isn't in the order")
+			continue
+		}
+
+		switch left := quantity - returned[l.ProductCode]; {
+		case left <= 0:
+			errs.Add(fmt.Sprintf("lines[%d].quantity", i), validation.OutOfRange, "every one of the product has already been returned")
+		case l.Quantity > left:
+			errs.Add(fmt.Sprintf("lines[%d].quantity", i), validation.OutOfRange, fmt.Sprintf("only %d of the product can still be returned", left))
+		}
+	}
+
+	return errs
+}
+
+// Refund fills in the refund of each line of a checked return and the refund of the return, given the quantity of
+// each product already returned. A line gets back its share of what was paid for the product (the line total less
+// its discount, plus its tax) for the quantity returned, rounded down, with the last of a product getting back what
+// is left, so returning everything refunds exactly what was paid. Shipping isn't refunded.
+func Refund(r *models.Return, o models.Order, returned map[string]int) {
+	ordered, paid := lines(o)
+
+	r.Refund = 0
+	for i, l := range r.Lines {
+		before := returned[l.ProductCode]
+		after := before + l.Quantity
+
+		// what has been refunded for the product after the return, less what had been before it
+		refund := paid[l.ProductCode]*models.Money(after)/models.Money(ordered[l.ProductCode]) -
+			paid[l.ProductCode]*models.Money(before)/models.Money(ordered[l.ProductCode])
+
+		r.Lines[i].Refund = refund
+		r.Refund += refund
+	}
+}
+
+// lines adds up the quantity ordered and the amount paid for each product of the order, in case a product is on
+// more than one line
+func lines(o models.Order) (map[string]int, map[string]models.Money) {
+	ordered := make(map[string]int, len(o.Products))
+	paid := make(map[string]models.Money, len(o.Products))
+	for _, p := range o.Products {
+		ordered[p.ProductCode] += p.Quantity
+		paid[p.ProductCode] += p.LineTotal - p.Discount + p.Tax
+	}
+
+	return ordered, paid
+}
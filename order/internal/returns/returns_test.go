@@ -0,0 +1,149 @@
+package returns
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/internal/validation"
+)
+
+// product returns a priced line of an order, what was paid for it is the line total less the discount plus the tax
+func product(code string, quantity int, lineTotal, discount, tax models.Money) models.Product {
+	return models.Product{ProductCode: code, Quantity: quantity, LineTotal: lineTotal, Discount: discount, Tax: tax}
+}
+
+// line returns a line of a return
+func line(code string, quantity int) models.ReturnLine {
+	return models.ReturnLine{ProductCode: code, Quantity: quantity}
+}
+
+func TestRefund(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   models.Order
+		returns [][]models.ReturnLine // returned one after the other
+		want    [][]models.Money      // the refund of every line of each return
+		paid    models.Money          // what was paid for the order, if all of it is returned
+	}{
+		{
+			name:    "partial return",
+			order:   models.Order{Products: []models.Product{product("A", 3, 3000, 300, 270), product("B", 1, 500, 0, 40)}},
+			returns: [][]models.ReturnLine{{line("A", 1)}},
+			want:    [][]models.Money{{990}}, // a third of 2970
+		},
+		{
+			name:    "return split over several requests",
+			order:   models.Order{Products: []models.Product{product("A", 3, 3000, 300, 270)}},
+			returns: [][]models.ReturnLine{{line("A", 2)}, {line("A", 1)}},
+			want:    [][]models.Money{{1980}, {990}},
+		},
+		{
+			name:    "rounded down with the last getting what is left",
+			order:   models.Order{Products: []models.Product{product("A", 3, 1000, 0, 0)}},
+			returns: [][]models.ReturnLine{{line("A", 1)}, {line("A", 1)}, {line("A", 1)}},
+			want:    [][]models.Money{{333}, {333}, {334}},
+		},
+		{
+			name:    "product spread across several lines",
+			order:   models.Order{Products: []models.Product{product("A", 1, 1000, 100, 72), product("B", 2, 800, 0, 64), product("A", 2, 2000, 200, 144)}},
+			returns: [][]models.ReturnLine{{line("A", 2)}, {line("A", 1), line("B", 1)}},
+			want:    [][]models.Money{{1944}, {972, 432}}, // A paid 2916 for 3, B 864 for 2
+		},
+		{
+			name:  "returning everything refunds exactly what was paid",
+			order: models.Order{Products: []models.Product{product("A", 7, 1001, 99, 71), product("B", 3, 1000, 333, 53), product("C", 1, 499, 0, 0)}},
+			returns: [][]models.ReturnLine{
+				{line("A", 2), line("B", 1)},
+				{line("A", 4), line("C", 1)},
+				{line("B", 2), line("A", 1)},
+			},
+			want: [][]models.Money{{278, 240}, {556, 499}, {480, 139}}, // A paid 973, B 720 and C 499
+			paid: 2192,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var previous []models.Return
+			var refunded models.Money
+			for k, lines := range tt.returns {
+				r := models.Return{Lines: append([]models.ReturnLine(nil), lines...)}
+				returned := Returned(previous)
+
+				if errs := Check(r, tt.order, returned); len(errs) > 0 {
+					t.Fatalf("Check() of return %d = %v, want no errors", k, errs)
+				}
+
+				Refund(&r, tt.order, returned)
+
+				var got []models.Money
+				var total models.Money
+				for _, l := range r.Lines {
+					got = append(got, l.Refund)
+					total += l.Refund
+				}
+				if !reflect.DeepEqual(got, tt.want[k]) {
+					t.Errorf("Refund() of return %d = %v, want %v", k, got, tt.want[k])
+				}
+				if r.Refund != total {
+					t.Errorf("Refund() of return %d refunds %d, want the %d of its lines", k, r.Refund, total)
+				}
+
+				previous = append(previous, r)
+				refunded += r.Refund
+			}
+
+			if tt.paid != 0 && refunded != tt.paid {
+				t.Errorf("Refund() refunds %d in all, want the %d paid", refunded, tt.paid)
+			}
+		})
+	}
+
+	t.Run("shipping isn't refunded", func(t *testing.T) {
+		o := models.Order{Products: []models.Product{product("A", 2, 2000, 0, 160)}, Totals: models.Totals{Shipping: 599}}
+		r := models.Return{Lines: []models.ReturnLine{line("A", 2)}}
+
+		Refund(&r, o, nil)
+		if r.Refund != 2160 {
+			t.Errorf("Refund() = %d, want 2160", r.Refund)
+		}
+	})
+}
+
+func TestCheck(t *testing.T) {
+	order := models.Order{Products: []models.Product{product("A", 2, 2000, 0, 0), product("B", 1, 500, 0, 0), product("A", 1, 1000, 0, 0)}}
+
+	tests := []struct {
+		name     string
+		lines    []models.ReturnLine
+		returned map[string]int
+		want     []string // the fields with a problem, and the code of the problem
+	}{
+		{name: "partial return", lines: []models.ReturnLine{line("A", 1)}},
+		{name: "product spread across several lines", lines: []models.ReturnLine{line("A", 3), line("B", 1)}},
+		{name: "rest of a product already partly returned", lines: []models.ReturnLine{line("A", 2)}, returned: map[string]int{"A": 1}},
+		{name: "product not in the order", lines: []models.ReturnLine{line("B", 1), line("Z", 1)}, want: []string{"lines[1].productCode", string(validation.NotFound)}},
+		{name: "more than was ordered", lines: []models.ReturnLine{line("A", 4)}, want: []string{"lines[0].quantity", string(validation.OutOfRange)}},
+		{name: "more than is left", lines: []models.ReturnLine{line("A", 2)}, returned: map[string]int{"A": 2}, want: []string{"lines[0].quantity", string(validation.OutOfRange)}},
+		{
+			name:     "everything already returned",
+			lines:    []models.ReturnLine{line("A", 1), line("B", 1)},
+			returned: map[string]int{"A": 1, "B": 1},
+			want:     []string{"lines[1].quantity", string(validation.OutOfRange)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range Check(models.Return{Lines: tt.lines}, order, tt.returned) {
+				got = append(got, e.Field, string(e.Code))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package tax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// providerTimeout is how long the external tax provider has to answer
+const providerTimeout = 5 * time.Second
+
+// providerResponse is what the external tax provider answers with, the tax of each line in the order of the request
+type providerResponse struct {
+	Lines []struct {
+		Tax models.Money `json:"tax"`
+	} `json:"lines"`
+}
+
+// Provider is the adapter for an external tax provider. The request is POSTed to the URL as JSON and the provider
+// answers with the tax of each line, e.g. {"lines":[{"tax":80},{"tax":0}]}. Providers with their own API are
+// adapted by a service translating to and from it.
+type Provider struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewProvider returns the adapter for the external tax provider at the URL
+func NewProvider(url string) Provider {
+	return Provider{
+		URL:    url,
+		Client: &http.Client{Timeout: providerTimeout},
+	}
+}
+
+// Calculate returns the tax of each line of the request, as calculated by the external tax provider
+func (p Provider) Calculate(ctx context.Context, r Request) ([]models.Money, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach the tax provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the tax provider answered with %s", resp.Status)
+	}
+
+	var result providerResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("unable to read the answer of the tax provider: %w", err)
+	}
+
+	if len(result.Lines) != len(r.Lines) {
+		return nil, fmt.Errorf("the tax provider answered with %d lines for %d", len(result.Lines), len(r.Lines))
+	}
+
+	taxes := make([]models.Money, len(result.Lines))
+	for i, line := range result.Lines {
+		taxes[i] = line.Tax
+	}
+
+	return taxes, nil
+}
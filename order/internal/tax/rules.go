@@ -0,0 +1,104 @@
+package tax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// Rule is the rate of tax (in basis points, hundredths of a percent) charged on products shipped to a place. A rule
+// applies to every state, postal code and tax category unless it specifies one, a postal prefix matches every
+// postal code starting with it.
+type Rule struct {
+	Country      string `json:"country"`
+	State        string `json:"state,omitempty"`
+	PostalPrefix string `json:"postalPrefix,omitempty"`
+	TaxCategory  string `json:"taxCategory,omitempty"`
+	RateBps      int64  `json:"rateBps"`
+}
+
+// matches returns true if the rule applies to the product shipped to the address
+func (r Rule) matches(a models.Address, taxCategory string) bool {
+	return r.Country == a.Country &&
+		(len(r.State) == 0 || strings.EqualFold(r.State, a.State)) &&
+		(len(r.PostalPrefix) == 0 || strings.HasPrefix(models.PostalCode(a.PostalCode), models.PostalCode(r.PostalPrefix))) &&
+		(len(r.TaxCategory) == 0 || r.TaxCategory == taxCategory)
+}
+
+// moreSpecific returns true if the rule is more specific than the other, a rule for the tax category beats one for
+// every category, then a longer postal prefix beats a shorter one, and a rule for the state beats one for the country
+func (r Rule) moreSpecific(other Rule) bool {
+	if (len(r.TaxCategory) > 0) != (len(other.TaxCategory) > 0) {
+		return len(r.TaxCategory) > 0
+	}
+	if len(r.PostalPrefix) != len(other.PostalPrefix) {
+		return len(r.PostalPrefix) > len(other.PostalPrefix)
+	}
+
+	return len(r.State) > 0 && len(other.State) == 0
+}
+
+// RuleTable calculates tax from a table of rules, each product is taxed at the rate of the most specific rule that
+// applies to it (see Rule), or the default rate if none does. The tax of each line is rounded to the minor unit.
+type RuleTable struct {
+	Rules       []Rule
+	DefaultRate int64
+}
+
+// Calculate returns the tax of each line of the request
+func (t RuleTable) Calculate(ctx context.Context, r Request) ([]models.Money, error) {
+	taxes := make([]models.Money, len(r.Lines))
+	for i, line := range r.Lines {
+		taxes[i] = line.Amount.BasisPoints(t.rate(r.Address, line.TaxCategory))
+	}
+
+	return taxes, nil
+}
+
+// rate returns the rate of the most specific rule for the product shipped to the address
+func (t RuleTable) rate(a models.Address, taxCategory string) int64 {
+	var match *Rule
+	for i, rule := range t.Rules {
+		if rule.matches(a, taxCategory) && (match == nil || rule.moreSpecific(*match)) {
+			match = &t.Rules[i]
+		}
+	}
+
+	if match == nil {
+		return t.DefaultRate
+	}
+
+	return match.RateBps
+}
+
+// LoadRules reads the rules from a JSON file holding an array of them, there are no rules if the file isn't specified
+func LoadRules(file string) ([]Rule, error) {
+	if len(file) == 0 {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err = json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("unable to read the tax rules in %s: %w", file, err)
+	}
+
+	for i, rule := range rules {
+		if len(rule.Country) == 0 {
+			return nil, fmt.Errorf("tax rule [%d] in %s has no country", i, file)
+		}
+		if rule.RateBps < 0 {
+			return nil, fmt.Errorf("tax rule [%d] in %s has a negative rate", i, file)
+		}
+	}
+
+	return rules, nil
+}
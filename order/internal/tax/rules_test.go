@@ -0,0 +1,134 @@
+package tax
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+var rules = []Rule{
+	{Country: "US", State: "LA", RateBps: 445},
+	{Country: "US", State: "LA", PostalPrefix: "708", RateBps: 945},
+	{Country: "US", State: "LA", PostalPrefix: "70810", RateBps: 995},
+	{Country: "US", State: "LA", TaxCategory: "food", RateBps: 0},
+	{Country: "US", State: "NY", TaxCategory: "clothing", RateBps: 400},
+	{Country: "US", State: "NY", RateBps: 800},
+	{Country: "US", PostalPrefix: "100", RateBps: 887},
+	{Country: "GB", RateBps: 2000},
+	{Country: "GB", PostalPrefix: "SW1A", TaxCategory: "food", RateBps: 500},
+	{Country: "CA", RateBps: 500},
+	{Country: "CA", State: "ON", RateBps: 1300},
+}
+
+func TestRuleTableCalculate(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     models.Address
+		taxCategory string
+		amount      models.Money
+		want        models.Money
+	}{
+		{name: "state", address: models.Address{Country: "US", State: "LA", PostalCode: "70001"}, amount: 10000, want: 445},
+		{name: "state in lower case", address: models.Address{Country: "US", State: "la", PostalCode: "70001"}, amount: 10000, want: 445},
+		{name: "postal prefix beats the state", address: models.Address{Country: "US", State: "LA", PostalCode: "70801"}, amount: 10000, want: 945},
+		{name: "longer postal prefix wins", address: models.Address{Country: "US", State: "LA", PostalCode: "70810-1234"}, amount: 10000, want: 995},
+		{name: "tax category beats the postal prefix", address: models.Address{Country: "US", State: "LA", PostalCode: "70810"}, taxCategory: "food", amount: 10000, want: 0},
+		{name: "other categories get the postal prefix", address: models.Address{Country: "US", State: "LA", PostalCode: "70810"}, taxCategory: "clothing", amount: 10000, want: 995},
+		{name: "postal prefix beats the state it isn't narrowed to", address: models.Address{Country: "US", State: "NY", PostalCode: "10001"}, amount: 10000, want: 887},
+		{name: "tax category beats the postal prefix it isn't narrowed to", address: models.Address{Country: "US", State: "NY", PostalCode: "10001"}, taxCategory: "clothing", amount: 10000, want: 400},
+		{name: "state beats the country", address: models.Address{Country: "CA", State: "ON", PostalCode: "M5V 2T6"}, amount: 10000, want: 1300},
+		{name: "country", address: models.Address{Country: "CA", State: "BC", PostalCode: "V6B 1A1"}, amount: 10000, want: 500},
+		{name: "postal prefix ignores spaces and case", address: models.Address{Country: "GB", PostalCode: "sw1a 1aa"}, taxCategory: "food", amount: 10000, want: 500},
+		{name: "postal prefix of another place", address: models.Address{Country: "GB", PostalCode: "EC1A 1BB"}, taxCategory: "food", amount: 10000, want: 2000},
+		{name: "no rule gets the default rate", address: models.Address{Country: "MX", State: "CMX", PostalCode: "06000"}, amount: 10000, want: 100},
+		{name: "rounds half up", address: models.Address{Country: "US", State: "LA", PostalCode: "70001"}, amount: 1000, want: 45}, // 44.5
+		{name: "rounds down", address: models.Address{Country: "US", State: "LA", PostalCode: "70801"}, amount: 2598, want: 246},   // 245.511
+		{name: "rounds up", address: models.Address{Country: "US", State: "LA", PostalCode: "70810"}, amount: 2598, want: 259},     // 258.501
+		{name: "nothing to tax", address: models.Address{Country: "US", State: "LA", PostalCode: "70810"}, amount: 0, want: 0},
+	}
+
+	table := RuleTable{Rules: rules, DefaultRate: 100}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taxes, err := table.Calculate(context.Background(), Request{
+				Currency: "USD",
+				Address:  tt.address,
+				Lines:    []Line{{ProductCode: "12345", TaxCategory: tt.taxCategory, Amount: tt.amount}},
+			})
+			if err != nil {
+				t.Fatalf("Calculate() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(taxes, []models.Money{tt.want}) {
+				t.Errorf("Calculate() = %v, want [%d]", taxes, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleTableCalculateEveryLine(t *testing.T) {
+	table := RuleTable{Rules: rules}
+
+	taxes, err := table.Calculate(context.Background(), Request{
+		Currency: "USD",
+		Address:  models.Address{Country: "US", State: "LA", PostalCode: "70810"},
+		Lines: []Line{
+			{ProductCode: "12345", TaxCategory: "standard", Amount: 2598},
+			{ProductCode: "67890", TaxCategory: "food", Amount: 2500},
+			{ProductCode: "11111", TaxCategory: "standard", Amount: 1000},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Calculate() error = %v", err)
+	}
+
+	if want := []models.Money{259, 0, 100}; !reflect.DeepEqual(taxes, want) {
+		t.Errorf("Calculate() = %v, want %v", taxes, want)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []Rule
+		wantErr bool
+	}{
+		{
+			name: "rules",
+			file: `[{"country":"US","state":"LA","rateBps":445},{"country":"GB","postalPrefix":"SW1A","taxCategory":"food","rateBps":500}]`,
+			want: []Rule{{Country: "US", State: "LA", RateBps: 445}, {Country: "GB", PostalPrefix: "SW1A", TaxCategory: "food", RateBps: 500}},
+		},
+		{name: "no rules", file: `[]`, want: []Rule{}},
+		{name: "not JSON", file: `country,rateBps`, wantErr: true},
+		{name: "no country", file: `[{"state":"LA","rateBps":445}]`, wantErr: true},
+		{name: "negative rate", file: `[{"country":"US","rateBps":-1}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(file, []byte(tt.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadRules(file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadRules() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("no file", func(t *testing.T) {
+		if got, err := LoadRules(""); err != nil || got != nil {
+			t.Errorf("LoadRules() = %v, %v, want no rules", got, err)
+		}
+	})
+}
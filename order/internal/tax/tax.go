@@ -0,0 +1,51 @@
+package tax
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// Line is a product of an order to be taxed, the amount is its line total less its discount
+type Line struct {
+	ProductCode string       `json:"productCode"`
+	TaxCategory string       `json:"taxCategory"`
+	Amount      models.Money `json:"amount"`
+}
+
+// Request is an order to be taxed, amounts are in the minor units of its currency
+type Request struct {
+	Currency string         `json:"currency"`
+	Address  models.Address `json:"address"`
+	Lines    []Line         `json:"lines"`
+}
+
+// Calculator works out the tax of the products of an order
+type Calculator interface {
+	// Calculate returns the tax of each line of the request, in the same order
+	Calculate(ctx context.Context, r Request) ([]models.Money, error)
+}
+
+// New returns the calculator configured by TAX_PROVIDER, either the tax rules loaded from TAX_RULES_FILE or the
+// external tax provider at TAX_PROVIDER_URL
+func New() (Calculator, error) {
+	switch provider := config.TaxProvider(); provider {
+	case "rules":
+		rules, err := LoadRules(config.TaxRulesFile())
+		if err != nil {
+			return nil, err
+		}
+
+		return RuleTable{Rules: rules, DefaultRate: config.TaxRate()}, nil
+	case "http":
+		if len(config.TaxProviderURL()) == 0 {
+			return nil, fmt.Errorf("%s has to be set to use the http tax provider", config.TaxProviderURLEnvVar)
+		}
+
+		return NewProvider(config.TaxProviderURL()), nil
+	default:
+		return nil, fmt.Errorf("unknown tax provider %q, should be rules or http", provider)
+	}
+}
@@ -0,0 +1,74 @@
+package validation
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+const (
+	// MaxProductCodeLength is how long the code of a product in the catalogue can be
+	MaxProductCodeLength = 256
+
+	// MaxProductNameLength is how long the name of a product in the catalogue can be
+	MaxProductNameLength = 256
+
+	// MaxTaxCategoryLength is how long the tax category of a product in the catalogue can be
+	MaxTaxCategoryLength = 64
+)
+
+// CatalogueProduct validates a product of the catalogue has the necessary information, and returns every problem
+// with it
+func CatalogueProduct(p models.CatalogueProduct) Errors {
+	var errs Errors
+
+	switch {
+	case len(p.Code) == 0:
+		errs.Add("code", Required, "product code is required")
+	case len(p.Code) > MaxProductCodeLength:
+		errs.Add("code", TooLong, fmt.Sprintf("can't be longer than %d characters", MaxProductCodeLength))
+	}
+
+	switch {
+	case len(p.Name) == 0:
+		errs.Add("name", Required, "product name is required")
+	case len(p.Name) > MaxProductNameLength:
+		errs.Add("name", TooLong, fmt.Sprintf("can't be longer than %d characters", MaxProductNameLength))
+	}
+
+	if p.WeightGrams <= 0 {
+		errs.Add("weightGrams", OutOfRange, "weight should be greater than zero")
+	}
+
+	if len(p.TaxCategory) > MaxTaxCategoryLength {
+		errs.Add("taxCategory", TooLong, fmt.Sprintf("can't be longer than %d characters", MaxTaxCategoryLength))
+	}
+
+	if p.Dimensions.LengthMm <= 0 {
+		errs.Add("dimensions.lengthMm", OutOfRange, "length should be greater than zero")
+	}
+	if p.Dimensions.WidthMm <= 0 {
+		errs.Add("dimensions.widthMm", OutOfRange, "width should be greater than zero")
+	}
+	if p.Dimensions.HeightMm <= 0 {
+		errs.Add("dimensions.heightMm", OutOfRange, "height should be greater than zero")
+	}
+
+	currencies := make([]string, 0, len(p.Prices))
+	for currency := range p.Prices {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	for _, currency := range currencies {
+		if !models.SupportedCurrency(currency) {
+			errs.Add("prices."+currency, InvalidFormat, "is not a supported ISO 4217 currency code")
+		}
+		if p.Prices[currency] < 0 {
+			errs.Add("prices."+currency, OutOfRange, "price can't be negative")
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,185 @@
+package validation
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Decode will decode the JSON document into v (a pointer to a struct). Unlike json.Decoder it reports every
+// field that isn't part of the struct or has a value of the wrong type as Errors, rather than the first one.
+// Other errors are returned if the document isn't JSON at all.
+func Decode(r io.Reader, v interface{}) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var document interface{}
+	if err = d.Decode(&document); err != nil {
+		return fmt.Errorf("the request body isn't valid JSON: %w", err)
+	}
+	if d.More() {
+		return fmt.Errorf("the request body should hold a single JSON document")
+	}
+
+	var errs Errors
+	check(document, reflect.TypeOf(v).Elem(), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// check walks the JSON value alongside the Go type it will be decoded into, recording every unknown field and
+// every value of the wrong type
+func check(value interface{}, t reflect.Type, path string, errs *Errors) {
+	if value == nil {
+		return
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// types like uuid.UUID and time.Time are strings in a specific format
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		s, ok := value.(string)
+		if !ok {
+			errs.Add(path, InvalidType, "should be a string")
+			return
+		}
+
+		if err := reflect.New(t).Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			errs.Add(path, InvalidFormat, err.Error())
+		}
+
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			errs.Add(path, InvalidType, "should be an object")
+			return
+		}
+
+		for _, name := range sortedKeys(fields) {
+			f, found := field(t, name)
+			if !found {
+				errs.Add(join(path, name), Unknown, "is not a known field")
+				continue
+			}
+
+			check(fields[name], f.Type, join(path, name), errs)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			errs.Add(path, InvalidType, "should be an array")
+			return
+		}
+
+		for i, item := range items {
+			check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			errs.Add(path, InvalidType, "should be an object")
+			return
+		}
+
+		for _, key := range sortedKeys(entries) {
+			check(entries[key], t.Elem(), join(path, key), errs)
+		}
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			errs.Add(path, InvalidType, "should be a string")
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			errs.Add(path, InvalidType, "should be a boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := value.(json.Number)
+		if !ok {
+			errs.Add(path, InvalidType, "should be a number")
+			return
+		}
+
+		if _, err := n.Int64(); err != nil {
+			errs.Add(path, InvalidType, "should be a whole number")
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(json.Number); !ok {
+			errs.Add(path, InvalidType, "should be a number")
+		}
+	}
+}
+
+// field returns the field of the struct the JSON name decodes into, matching names the way encoding/json does
+func field(t reflect.Type, name string) (reflect.StructField, bool) {
+	var folded reflect.StructField
+	found := false
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		fieldName := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if len(tagName) > 0 {
+				fieldName = tagName
+			}
+		}
+
+		if fieldName == name {
+			return f, true
+		}
+		if !found && strings.EqualFold(fieldName, name) {
+			folded, found = f, true
+		}
+	}
+
+	return folded, found
+}
+
+// join returns the path of a field of the object at the path
+func join(path, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// sortedKeys returns the keys of the object in order, so problems are always reported in the same order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,160 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+const (
+	// MaxOrderLines is how many products an order can have
+	MaxOrderLines = 100
+
+	// MaxQuantity is how many of a product can be ordered at once
+	MaxQuantity = 10000
+
+	// MaxExternalOrderIDLength is how long the reference a sales channel gives an order can be
+	MaxExternalOrderIDLength = 255
+
+	// MaxSalesChannelLength is how long the name of a sales channel can be
+	MaxSalesChannelLength = 64
+)
+
+// Order validates an order received from a client has the necessary information, and returns every problem
+// with it
+func Order(o models.Order) Errors {
+	var errs Errors
+
+	if o.ID != uuid.Nil {
+		errs.Add("id", ReadOnly, "is assigned by the service, use externalOrderId for your own reference to the order")
+	}
+
+	if len(o.ExternalOrderID) > MaxExternalOrderIDLength {
+		errs.Add("externalOrderId", TooLong, fmt.Sprintf("can't be longer than %d characters", MaxExternalOrderIDLength))
+	}
+
+	if len(o.SalesChannel) > MaxSalesChannelLength {
+		errs.Add("salesChannel", TooLong, fmt.Sprintf("can't be longer than %d characters", MaxSalesChannelLength))
+	}
+
+	if len(o.Currency) > 0 && !models.SupportedCurrency(o.Currency) {
+		errs.Add("currency", InvalidFormat, "is not a supported ISO 4217 currency code")
+	}
+
+	if len(o.PromotionCode) > MaxPromotionCodeLength {
+		errs.Add("promotionCode", TooLong, fmt.Sprintf("can't be longer than %d characters", MaxPromotionCodeLength))
+	}
+
+	if o.ReceivedAt != nil {
+		errs.Add("receivedAt", ReadOnly, "is set by the service")
+	}
+
+	if o.Totals != (models.Totals{}) {
+		errs.Add("totals", ReadOnly, "are computed by the service")
+	}
+
+	if o.Fraud != nil {
+		errs.Add("fraud", ReadOnly, "is assessed by the fraud service")
+	}
+
+	if o.Payment != nil {
+		errs.Add("payment", ReadOnly, "is authorised by the payment service")
+	}
+
+	if o.Shipment != nil {
+		errs.Add("shipment", ReadOnly, "is packed by the warehouse")
+	}
+
+	if o.FullyShipped {
+		errs.Add("fullyShipped", ReadOnly, "is set by the shipper")
+	}
+
+	switch {
+	case len(o.Products) == 0:
+		errs.Add("products", Required, "there are no products in the order")
+	case len(o.Products) > MaxOrderLines:
+		errs.Add("products", TooLong, fmt.Sprintf("an order can't have more than %d products", MaxOrderLines))
+	}
+
+	for i, p := range o.Products {
+		if len(p.ProductCode) == 0 {
+			errs.Add(fmt.Sprintf("products[%d].productCode", i), Required, "product code is required")
+		}
+
+		switch {
+		case p.Quantity <= 0:
+			errs.Add(fmt.Sprintf("products[%d].quantity", i), OutOfRange, "quantity should be greater than zero")
+		case p.Quantity > MaxQuantity:
+			errs.Add(fmt.Sprintf("products[%d].quantity", i), OutOfRange, fmt.Sprintf("quantity can't be more than %d", MaxQuantity))
+		}
+
+		if p.UnitPrice < 0 {
+			errs.Add(fmt.Sprintf("products[%d].unitPrice", i), OutOfRange, "unit price can't be negative")
+		}
+
+		if len(p.Name) > 0 {
+			errs.Add(fmt.Sprintf("products[%d].name", i), ReadOnly, "comes from the catalogue")
+		}
+
+		if p.LineTotal != 0 {
+			errs.Add(fmt.Sprintf("products[%d].lineTotal", i), ReadOnly, "is computed by the service")
+		}
+
+		if p.Discount != 0 {
+			errs.Add(fmt.Sprintf("products[%d].discount", i), ReadOnly, "is computed by the service from the promotionCode")
+		}
+
+		if p.Tax != 0 {
+			errs.Add(fmt.Sprintf("products[%d].tax", i), ReadOnly, "is computed by the service")
+		}
+	}
+
+	email := o.Customer.EmailAddress
+	if len(email) == 0 {
+		errs.Add("customer.emailAddress", Required, "email address is required")
+	} else if a, err := mail.ParseAddress(email); err != nil || a.Address != email {
+		errs.Add("customer.emailAddress", InvalidFormat, "is not a valid email address")
+	}
+
+	address(o.Customer.ShippingAddress, "customer.shippingAddress", &errs)
+	if len(o.ServiceLevel) > 0 {
+		serviceLevel(o.ServiceLevel, o.Customer.ShippingAddress, &errs)
+	}
+	if o.Customer.BillingAddress != nil {
+		address(*o.Customer.BillingAddress, "customer.billingAddress", &errs)
+	}
+
+	return errs
+}
+
+// address validates an address has the necessary information, and its postal code is in the format of its country
+func address(a models.Address, path string, errs *Errors) {
+	if len(strings.TrimSpace(a.Line1)) == 0 {
+		errs.Add(path+".line1", Required, "address line 1 is required")
+	}
+
+	if len(strings.TrimSpace(a.City)) == 0 {
+		errs.Add(path+".city", Required, "city is required")
+	}
+
+	country := a.Country
+	if len(country) == 0 {
+		country = DefaultCountry
+	} else if !countryCode.MatchString(country) {
+		errs.Add(path+".country", InvalidFormat, "should be an ISO 3166-1 alpha-2 country code, e.g. US")
+	}
+
+	postal := strings.TrimSpace(a.PostalCode)
+	if len(postal) == 0 {
+		errs.Add(path+".postalCode", Required, "postal code is required")
+		return
+	}
+
+	if format, found := postalCodes[country]; found && !format.pattern.MatchString(postal) {
+		errs.Add(path+".postalCode", InvalidFormat, fmt.Sprintf("is not a valid %s postal code, e.g. %s", country, format.example))
+	}
+}
@@ -0,0 +1,38 @@
+package validation
+
+import (
+	"regexp"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// DefaultCountry is the country of addresses that don't specify one
+const DefaultCountry = models.DefaultCountry
+
+// postalCode is the format of the postal codes of a country, along with an example to show in messages
+type postalCode struct {
+	pattern *regexp.Regexp
+	example string
+}
+
+// postalCodes are the formats of the postal codes of the countries we ship to most, postal codes of other
+// countries only have to be present
+var postalCodes = map[string]postalCode{
+	"US": {regexp.MustCompile(`^\d{5}(-\d{4})?$`), "12345 or 12345-6789"},
+	"CA": {regexp.MustCompile(`^[A-Za-z]\d[A-Za-z] ?\d[A-Za-z]\d$`), "K1A 0B1"},
+	"MX": {regexp.MustCompile(`^\d{5}$`), "01000"},
+	"GB": {regexp.MustCompile(`^[A-Za-z]{1,2}\d[A-Za-z\d]? ?\d[A-Za-z]{2}$`), "SW1A 1AA"},
+	"IE": {regexp.MustCompile(`^[A-Za-z]\d[\dWw] ?[A-Za-z\d]{4}$`), "D02 X285"},
+	"DE": {regexp.MustCompile(`^\d{5}$`), "10115"},
+	"FR": {regexp.MustCompile(`^\d{5}$`), "75008"},
+	"ES": {regexp.MustCompile(`^\d{5}$`), "28001"},
+	"IT": {regexp.MustCompile(`^\d{5}$`), "00118"},
+	"NL": {regexp.MustCompile(`^\d{4} ?[A-Za-z]{2}$`), "1012 AB"},
+	"BE": {regexp.MustCompile(`^\d{4}$`), "1000"},
+	"AU": {regexp.MustCompile(`^\d{4}$`), "2000"},
+	"JP": {regexp.MustCompile(`^\d{3}-?\d{4}$`), "100-0001"},
+	"IN": {regexp.MustCompile(`^\d{6}$`), "110001"},
+	"BR": {regexp.MustCompile(`^\d{5}-?\d{3}$`), "01310-100"},
+}
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
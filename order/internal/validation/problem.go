@@ -0,0 +1,127 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Code identifies the kind of problem with a field
+type Code string
+
+const (
+	// Required fields are missing or empty
+	Required Code = "required"
+
+	// Unknown fields aren't part of the request
+	Unknown Code = "unknown"
+
+	// InvalidType fields have a value of the wrong JSON type
+	InvalidType Code = "invalid_type"
+
+	// InvalidFormat fields have a value in the wrong format, e.g. an email address or postal code
+	InvalidFormat Code = "invalid_format"
+
+	// OutOfRange fields have a number that is too small or too large
+	OutOfRange Code = "out_of_range"
+
+	// TooShort fields have a value that is shorter than allowed, or a list with too few items
+	TooShort Code = "too_short"
+
+	// TooLong fields have a value that is longer than allowed, or a list with too many items
+	TooLong Code = "too_long"
+
+	// NotFound fields refer to something that doesn't exist, e.g. a product that isn't in the catalogue
+	NotFound Code = "not_found"
+
+	// Inactive fields refer to something that can't be used any more, e.g. a product that is no longer sold
+	Inactive Code = "inactive"
+
+	// Expired fields refer to something that can only be used for a period of time, outside of it, e.g. a promotion
+	Expired Code = "expired"
+
+	// Ineligible fields refer to something that doesn't apply to the request, e.g. a promotion for other products
+	Ineligible Code = "ineligible"
+
+	// UsageLimit fields refer to something that has been used as many times as it can be, e.g. a promotion
+	UsageLimit Code = "usage_limit"
+
+	// Duplicate fields have a value that is already used by another item of a list, e.g. a product returned twice
+	Duplicate Code = "duplicate"
+
+	// Mismatch fields have a value that doesn't match the one the service has, e.g. a unit price
+	Mismatch Code = "mismatch"
+
+	// ReadOnly fields are set by the service and can't be specified
+	ReadOnly Code = "read_only"
+)
+
+// FieldError is a problem with a single field of a request, the field is the path to it in the JSON document,
+// e.g. products[1].quantity
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors is every problem found with a request
+type Errors []FieldError
+
+// Error returns the message of the first problem, along with how many others there are
+func (e Errors) Error() string {
+	switch len(e) {
+	case 0:
+		return "the request is valid"
+	case 1:
+		return e[0].Field + ": " + e[0].Message
+	}
+
+	return e[0].Field + ": " + e[0].Message + " (and " + strconv.Itoa(len(e)-1) + " more)"
+}
+
+// Add records a problem with the field
+func (e *Errors) Add(field string, code Code, message string) {
+	*e = append(*e, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Problem is the RFC 7807 representation of an error returned by the service
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Errors Errors `json:"errors,omitempty"`
+}
+
+// WriteProblem writes the problem as the response
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	if len(p.Type) == 0 {
+		p.Type = "about:blank"
+	}
+	if len(p.Title) == 0 {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.WithField("error", err.Error()).Error("unable to write the problem")
+	}
+}
+
+// WriteErrors writes a HTTP 400 problem listing every problem with the request
+func WriteErrors(w http.ResponseWriter, errs Errors) {
+	WriteProblem(w, Problem{
+		Type:   "/problems/validation",
+		Title:  "The request is invalid",
+		Status: http.StatusBadRequest,
+		Detail: "see errors for every problem with the request",
+		Errors: errs,
+	})
+}
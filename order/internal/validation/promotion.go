@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// MaxPromotionCodeLength is how long the code of a promotion can be
+const MaxPromotionCodeLength = 64
+
+// PromotionCodePattern is the format of the code of a promotion, codes are matched case insensitively so they're
+// kept in upper case
+const PromotionCodePattern = "^[A-Z0-9][A-Z0-9_-]*$"
+
+var promotionCode = regexp.MustCompile(PromotionCodePattern)
+
+// Promotion validates a promotion has the necessary information for its type, and returns every problem with it
+func Promotion(p models.Promotion) Errors {
+	var errs Errors
+
+	switch {
+	case len(p.Code) == 0:
+		errs.Add("code", Required, "promotion code is required")
+	case len(p.Code) > MaxPromotionCodeLength:
+		errs.Add("code", TooLong, fmt.Sprintf("can't be longer than %d characters", MaxPromotionCodeLength))
+	case !promotionCode.MatchString(p.Code):
+		errs.Add("code", InvalidFormat, "should only have upper case letters, digits, underscores and dashes")
+	}
+
+	switch p.Type {
+	case models.PercentageOff:
+		if p.PercentOff < 1 || p.PercentOff > 100 {
+			errs.Add("percentOff", OutOfRange, "should be between 1 and 100")
+		}
+	case models.FixedAmountOff:
+		if len(p.AmountsOff) == 0 {
+			errs.Add("amountsOff", Required, "a fixed promotion needs an amount in at least one currency")
+		}
+	case models.FreeShipping:
+	case "":
+		errs.Add("type", Required, "promotion type is required")
+	default:
+		errs.Add("type", InvalidFormat, fmt.Sprintf("should be %s, %s or %s", models.PercentageOff, models.FixedAmountOff, models.FreeShipping))
+	}
+
+	if p.Type != models.PercentageOff && p.PercentOff != 0 {
+		errs.Add("percentOff", Mismatch, "only applies to percentage promotions")
+	}
+
+	if p.Type != models.FixedAmountOff && len(p.AmountsOff) > 0 {
+		errs.Add("amountsOff", Mismatch, "only applies to fixed promotions")
+	}
+
+	currencies := make([]string, 0, len(p.AmountsOff))
+	for currency := range p.AmountsOff {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	for _, currency := range currencies {
+		if !models.SupportedCurrency(currency) {
+			errs.Add("amountsOff."+currency, InvalidFormat, "is not a supported ISO 4217 currency code")
+		}
+		if p.AmountsOff[currency] <= 0 {
+			errs.Add("amountsOff."+currency, OutOfRange, "amount should be greater than zero")
+		}
+	}
+
+	for i, code := range p.ProductCodes {
+		if len(code) == 0 {
+			errs.Add(fmt.Sprintf("productCodes[%d]", i), Required, "product code is required")
+		}
+	}
+
+	if p.ValidFrom != nil && p.ValidUntil != nil && !p.ValidUntil.After(*p.ValidFrom) {
+		errs.Add("validUntil", OutOfRange, "should be after validFrom")
+	}
+
+	if p.MaxRedemptions < 0 {
+		errs.Add("maxRedemptions", OutOfRange, "can't be negative")
+	}
+
+	if p.MaxRedemptionsPerCustomer < 0 {
+		errs.Add("maxRedemptionsPerCustomer", OutOfRange, "can't be negative")
+	}
+
+	return errs
+}
@@ -0,0 +1,74 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// MaxReturnReasonLength is how long the reason a customer gives for a return can be
+const MaxReturnReasonLength = 500
+
+// Return validates a return requested by a client has the necessary information, and returns every problem with it.
+// Whether the products can be returned from the order is checked against the order (see returns.Check).
+func Return(r models.Return) Errors {
+	var errs Errors
+
+	if r.ID != uuid.Nil {
+		errs.Add("id", ReadOnly, "is assigned by the service")
+	}
+
+	if r.OrderID != uuid.Nil {
+		errs.Add("orderId", ReadOnly, "is the order in the path")
+	}
+
+	if len(r.Status) > 0 {
+		errs.Add("status", ReadOnly, "is set by the service")
+	}
+
+	if len(r.Reason) > MaxReturnReasonLength {
+		errs.Add("reason", TooLong, fmt.Sprintf("can't be longer than %d characters", MaxReturnReasonLength))
+	}
+
+	if len(r.Currency) > 0 {
+		errs.Add("currency", ReadOnly, "is the currency of the order")
+	}
+
+	if r.Refund != 0 {
+		errs.Add("refund", ReadOnly, "is computed by the service")
+	}
+
+	if r.Customer != nil {
+		errs.Add("customer", ReadOnly, "is the customer of the order")
+	}
+
+	switch {
+	case len(r.Lines) == 0:
+		errs.Add("lines", Required, "there are no products in the return")
+	case len(r.Lines) > MaxOrderLines:
+		errs.Add("lines", TooLong, fmt.Sprintf("a return can't have more than %d products", MaxOrderLines))
+	}
+
+	returned := make(map[string]bool, len(r.Lines))
+	for i, l := range r.Lines {
+		switch {
+		case len(l.ProductCode) == 0:
+			errs.Add(fmt.Sprintf("lines[%d].productCode", i), Required, "product code is required")
+		case returned[l.ProductCode]:
+			errs.Add(fmt.Sprintf("lines[%d].productCode", i), Duplicate, "the product is already on another line of the return")
+		}
+		returned[l.ProductCode] = true
+
+		if l.Quantity <= 0 {
+			errs.Add(fmt.Sprintf("lines[%d].quantity", i), OutOfRange, "quantity should be greater than zero")
+		}
+
+		if l.Refund != 0 {
+			errs.Add(fmt.Sprintf("lines[%d].refund", i), ReadOnly, "is computed by the service")
+		}
+	}
+
+	return errs
+}
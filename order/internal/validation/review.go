@@ -0,0 +1,40 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+const (
+	// MaxReviewOperatorLength is how long the name of the operator reviewing a held order can be
+	MaxReviewOperatorLength = 256
+
+	// MaxReviewNoteLength is how long the note an operator leaves on a held order they reviewed can be
+	MaxReviewNoteLength = 1000
+)
+
+// Review validates the review of a held order has the necessary information, and returns every problem with it
+func Review(r models.Review) Errors {
+	var errs Errors
+
+	switch {
+	case len(r.Operator) == 0:
+		errs.Add("operator", Required, "the operator reviewing the order is required")
+	case len(r.Operator) > MaxReviewOperatorLength:
+		errs.Add("operator", TooLong, fmt.Sprintf("can't be longer than %d characters", MaxReviewOperatorLength))
+	}
+
+	switch {
+	case len(r.Note) == 0:
+		errs.Add("note", Required, "a note of why the order was approved or rejected is required")
+	case len(r.Note) > MaxReviewNoteLength:
+		errs.Add("note", TooLong, fmt.Sprintf("can't be longer than %d characters", MaxReviewNoteLength))
+	}
+
+	if !r.ReviewedAt.IsZero() {
+		errs.Add("reviewedAt", ReadOnly, "is set by the service")
+	}
+
+	return errs
+}
@@ -0,0 +1,50 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// serviceLevelCountries are the countries the service levels faster than standard can ship to, standard ships
+// everywhere we ship to
+var serviceLevelCountries = map[models.ServiceLevel]map[string]bool{
+	models.ExpeditedShipping: {
+		"US": true, "CA": true, "MX": true, "GB": true, "IE": true, "DE": true, "FR": true, "ES": true, "IT": true, "NL": true, "BE": true,
+	},
+	models.OvernightShipping: {
+		"US": true,
+	},
+}
+
+// overnightExcludedStates are the US states and territories too far from the warehouse for overnight shipping
+var overnightExcludedStates = map[string]bool{"AK": true, "HI": true, "PR": true, "GU": true, "VI": true, "AS": true, "MP": true}
+
+// serviceLevel validates the service level is one we offer and can ship to the address with
+func serviceLevel(level models.ServiceLevel, a models.Address, errs *Errors) {
+	switch level {
+	case models.StandardShipping, models.ExpeditedShipping, models.OvernightShipping:
+	default:
+		errs.Add("serviceLevel", InvalidFormat, "should be standard, expedited or overnight")
+		return
+	}
+
+	countries, limited := serviceLevelCountries[level]
+	if !limited {
+		return
+	}
+
+	country := strings.ToUpper(strings.TrimSpace(a.Country))
+	if len(country) == 0 {
+		country = DefaultCountry
+	}
+
+	state := strings.ToUpper(strings.TrimSpace(a.State))
+	switch {
+	case !countries[country]:
+		errs.Add("serviceLevel", Ineligible, fmt.Sprintf("%s shipping isn't available to %s", level, country))
+	case level == models.OvernightShipping && overnightExcludedStates[state]:
+		errs.Add("serviceLevel", Ineligible, fmt.Sprintf("%s shipping isn't available to %s", level, state))
+	}
+}
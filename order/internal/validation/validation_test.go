@@ -0,0 +1,233 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// problems returns the field and code of every problem, in the order they were found
+func problems(errs Errors) []string {
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Field+" "+string(e.Code))
+	}
+
+	return got
+}
+
+// validOrder returns an order with everything a client has to give, shipped to the address
+func validOrder(shippingAddress models.Address) models.Order {
+	return models.Order{
+		Products: []models.Product{{ProductCode: "12345", Quantity: 2}},
+		Customer: models.Customer{
+			FirstName:       "Tom",
+			LastName:        "Hardy",
+			EmailAddress:    "tom.hardy@email.com",
+			ShippingAddress: shippingAddress,
+		},
+	}
+}
+
+// addressIn returns an address in the country with the postal code
+func addressIn(country, postalCode string) models.Address {
+	return models.Address{Line1: "123 Anywhere St", City: "Anytown", State: "AL", PostalCode: postalCode, Country: country}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+	}{
+		{name: "valid order", document: `{"products":[{"productCode":"12345","quantity":2}],"customer":{"emailAddress":"tom.hardy@email.com"}}`},
+		{name: "field names are matched case insensitively", document: `{"Products":[{"ProductCode":"12345","QUANTITY":2}]}`},
+		{name: "unknown field", document: `{"products":[],"priority":"high"}`, want: []string{"priority unknown"}},
+		{name: "unknown nested field", document: `{"customer":{"shippingAddress":{"zip":"12345"}}}`, want: []string{"customer.shippingAddress.zip unknown"}},
+		{name: "wrong type in a list", document: `{"products":[{"productCode":"12345","quantity":2},{"productCode":"67890","quantity":"2"}]}`, want: []string{"products[1].quantity invalid_type"}},
+		{name: "fraction of a whole number", document: `{"products":[{"productCode":"12345","quantity":1.5}]}`, want: []string{"products[0].quantity invalid_type"}},
+		{name: "object instead of a list", document: `{"products":{"productCode":"12345"}}`, want: []string{"products invalid_type"}},
+		{name: "malformed ID", document: `{"id":"order-1"}`, want: []string{"id invalid_format"}},
+		{name: "malformed timestamp", document: `{"receivedAt":"yesterday"}`, want: []string{"receivedAt invalid_format"}},
+		{
+			name:     "every problem in field order",
+			document: `{"salesChannel":7,"customer":{"firstName":false,"shippingAddress":{"country":["US"]}},"notes":"leave at the door"}`,
+			want:     []string{"customer.firstName invalid_type", "customer.shippingAddress.country invalid_type", "notes unknown", "salesChannel invalid_type"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o models.Order
+			err := Decode(strings.NewReader(tt.document), &o)
+
+			var errs Errors
+			if err != nil && !errors.As(err, &errs) {
+				t.Fatalf("Decode() error = %v, want Errors", err)
+			}
+			if got := problems(errs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, document := range []string{`{"products":`, `{} {}`, `not json`} {
+		t.Run(document, func(t *testing.T) {
+			var o models.Order
+			err := Decode(strings.NewReader(document), &o)
+
+			var errs Errors
+			if err == nil || errors.As(err, &errs) {
+				t.Errorf("Decode() error = %v, want the document to be unreadable", err)
+			}
+		})
+	}
+}
+
+func TestOrderReadOnly(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		modify func(o *models.Order)
+		want   string
+	}{
+		{name: "id", modify: func(o *models.Order) { o.ID = uuid.New() }, want: "id read_only"},
+		{name: "received at", modify: func(o *models.Order) { o.ReceivedAt = &now }, want: "receivedAt read_only"},
+		{name: "totals", modify: func(o *models.Order) { o.Totals.Total = 2598 }, want: "totals read_only"},
+		{name: "fraud", modify: func(o *models.Order) { o.Fraud = &models.FraudAssessment{} }, want: "fraud read_only"},
+		{name: "payment", modify: func(o *models.Order) { o.Payment = &models.Payment{} }, want: "payment read_only"},
+		{name: "shipment", modify: func(o *models.Order) { o.Shipment = &models.Shipment{} }, want: "shipment read_only"},
+		{name: "fully shipped", modify: func(o *models.Order) { o.FullyShipped = true }, want: "fullyShipped read_only"},
+		{name: "product name", modify: func(o *models.Order) { o.Products[0].Name = "Gloves" }, want: "products[0].name read_only"},
+		{name: "line total", modify: func(o *models.Order) { o.Products[0].LineTotal = 2598 }, want: "products[0].lineTotal read_only"},
+		{name: "discount", modify: func(o *models.Order) { o.Products[0].Discount = 260 }, want: "products[0].discount read_only"},
+		{name: "tax", modify: func(o *models.Order) { o.Products[0].Tax = 208 }, want: "products[0].tax read_only"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := validOrder(addressIn("", "12345"))
+			tt.modify(&o)
+
+			if got, want := problems(Order(o)), []string{tt.want}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Order() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("unit price can be given", func(t *testing.T) {
+		o := validOrder(addressIn("", "12345"))
+		o.Products[0].UnitPrice = 1299
+
+		if errs := Order(o); len(errs) > 0 {
+			t.Errorf("Order() = %v, want no errors", problems(errs))
+		}
+	})
+}
+
+func TestPostalCodes(t *testing.T) {
+	tests := []struct {
+		country string
+		valid   []string
+		invalid []string
+	}{
+		{country: "", valid: []string{"12345", "12345-6789"}, invalid: []string{"1234", "123456", "12345 6789", "ABCDE"}},
+		{country: "US", valid: []string{"12345", "12345-6789"}, invalid: []string{"12345-678", "K1A 0B1"}},
+		{country: "CA", valid: []string{"K1A 0B1", "k1a0b1"}, invalid: []string{"K1A 0B", "12345", "KK1 0B1"}},
+		{country: "MX", valid: []string{"01000"}, invalid: []string{"1000", "01000-1"}},
+		{country: "GB", valid: []string{"SW1A 1AA", "M1 1AE", "B33 8TH", "CR2 6XH", "DN55 1PT", "ec1a1bb"}, invalid: []string{"SW1A", "1AA SW1", "SW1A 1A1"}},
+		{country: "IE", valid: []string{"D02 X285", "A65F4E2", "D6W 1234"}, invalid: []string{"D02", "D02 X28", "002 X285"}},
+		{country: "DE", valid: []string{"10115"}, invalid: []string{"1011", "D-10115"}},
+		{country: "FR", valid: []string{"75008"}, invalid: []string{"7500", "75 008"}},
+		{country: "ES", valid: []string{"28001"}, invalid: []string{"2800"}},
+		{country: "IT", valid: []string{"00118"}, invalid: []string{"118"}},
+		{country: "NL", valid: []string{"1012 AB", "1012ab"}, invalid: []string{"1012", "AB 1012", "1012 A"}},
+		{country: "BE", valid: []string{"1000"}, invalid: []string{"10000", "B-1000"}},
+		{country: "AU", valid: []string{"2000"}, invalid: []string{"200", "20000"}},
+		{country: "JP", valid: []string{"100-0001", "1000001"}, invalid: []string{"100-001", "10-00001"}},
+		{country: "IN", valid: []string{"110001"}, invalid: []string{"11001", "110 001"}},
+		{country: "BR", valid: []string{"01310-100", "01310100"}, invalid: []string{"01310-10", "0131-0100"}},
+		{country: "NZ", valid: []string{"6011", "anything goes"}},
+	}
+
+	for _, tt := range tests {
+		name := tt.country
+		if len(name) == 0 {
+			name = "default country"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			for _, postal := range tt.valid {
+				if errs := Order(validOrder(addressIn(tt.country, postal))); len(errs) > 0 {
+					t.Errorf("Order() with postal code %q = %v, want no errors", postal, problems(errs))
+				}
+			}
+
+			for _, postal := range tt.invalid {
+				got := problems(Order(validOrder(addressIn(tt.country, postal))))
+				if want := []string{"customer.shippingAddress.postalCode invalid_format"}; !reflect.DeepEqual(got, want) {
+					t.Errorf("Order() with postal code %q = %v, want %v", postal, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		got := problems(Order(validOrder(addressIn("GB", "  "))))
+		if want := []string{"customer.shippingAddress.postalCode required"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Order() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("billing address", func(t *testing.T) {
+		o := validOrder(addressIn("", "12345"))
+		billing := addressIn("CA", "12345")
+		o.Customer.BillingAddress = &billing
+
+		got := problems(Order(o))
+		if want := []string{"customer.billingAddress.postalCode invalid_format"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Order() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestServiceLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   models.ServiceLevel
+		country string
+		state   string
+		want    []string
+	}{
+		{name: "standard ships everywhere", level: models.StandardShipping, country: "NZ"},
+		{name: "expedited in the default country", level: models.ExpeditedShipping},
+		{name: "expedited to Europe", level: models.ExpeditedShipping, country: "DE"},
+		{name: "expedited to a lower case country", level: models.ExpeditedShipping, country: " gb "},
+		{name: "expedited too far", level: models.ExpeditedShipping, country: "AU", want: []string{"serviceLevel ineligible"}},
+		{name: "overnight in the US", level: models.OvernightShipping, country: "US", state: "NY"},
+		{name: "overnight to a lower case country", level: models.OvernightShipping, country: "us", state: "NY"},
+		{name: "overnight outside the US", level: models.OvernightShipping, country: "CA", want: []string{"serviceLevel ineligible"}},
+		{name: "overnight to a lower case country outside the US", level: models.OvernightShipping, country: "ca", want: []string{"serviceLevel ineligible"}},
+		{name: "overnight to a state too far", level: models.OvernightShipping, state: "HI", want: []string{"serviceLevel ineligible"}},
+		{name: "overnight to a lower case state too far", level: models.OvernightShipping, state: " ak", want: []string{"serviceLevel ineligible"}},
+		{name: "unknown service level", level: "same-day", want: []string{"serviceLevel invalid_format"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs Errors
+			serviceLevel(tt.level, models.Address{Country: tt.country, State: tt.state}, &errs)
+
+			if got := problems(errs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("serviceLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/cmd/consumer"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/order/cmd/server"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -21,23 +24,53 @@ func init() {
 
 	// Only log the warning severity or above.
 	log.SetLevel(config.LogLevel())
+
+	// Identify this service as the producer of the events it publishes
+	publisher.ProducerName = "order"
 }
 
 func main() {
 	startTime := time.Now()
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		sig := <-sigs
 		log.WithField("uptime", time.Since(startTime).String()).
 			WithField("signal", sig.String()).
-			Error("interrupt signal detected")
-		os.Exit(0)
+			Warn("interrupt signal detected, shutting down")
+		cancel()
+	}()
+
+	// rejected orders and orders whose payment is declined give back the promotions they redeemed, and held orders
+	// wait in the review queue, if the consumer stops the whole service shuts down
+	c := consumer.Consumer{
+		Broker: config.BrokerAddress(),
+		Group:  config.ConsumerGroup(),
+		Topics: []string{config.RejectionsTopicName, config.PaymentDeclinedTopicName, config.OrderHeldTopicName},
+	}
+
+	consumed := make(chan error, 1)
+	go func() {
+		err := c.SubscribeAndListen(ctx)
+		cancel()
+		consumed <- err
 	}()
 
 	s := server.Server{
 		Port: config.Port(),
 	}
 
-	log.Fatal(s.ListenAndServe())
+	if err := s.ListenAndServe(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := <-consumed; err != nil {
+		log.Fatal(err)
+	}
+
+	// flush anything still waiting to be delivered before exiting
+	publisher.Close()
+
+	log.WithField("uptime", time.Since(startTime).String()).Info("shutdown complete")
 }
@@ -0,0 +1,590 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/codec"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/dispatcher"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/payment/internal/gateway"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/payment/internal/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Consumer represents the subscription to the specified Kafka topics
+type Consumer struct {
+	Broker  string
+	Group   string
+	Topics  []string
+	Gateway gateway.PaymentGateway
+}
+
+// pollTimeout is how long to wait for a message before checking if the consumer should shut down
+const pollTimeout = 500 * time.Millisecond
+
+// consumerName is the name the events this consumer processed are recorded under, so another service consuming the
+// same events still processes them
+const consumerName = "payment"
+
+// SubscribeAndListen will subscribe to the Kafka topics and start polling and listening for events until the
+// context is cancelled. The message being processed when the context is cancelled is finished and committed
+// before the consumer leaves the group.
+// Adpated from https://github.com/confluentinc/confluent-kafka-go#examples
+func (c *Consumer) SubscribeAndListen(ctx context.Context) error {
+
+	kc, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":     c.Broker,
+		"broker.address.family": "v4",
+		"group.id":              c.Group + "-payment",
+		"session.timeout.ms":    6000,
+		"enable.auto.commit":    false,
+		"auto.offset.reset":     "earliest"})
+
+	if err != nil {
+		log.WithField("error", err).Error("Failed to create consumer")
+
+		return err
+	}
+
+	log.WithField("consumer", kc).Info("Created Consumer")
+
+	pool, err := db.NewDB().ConnectPool(ctx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
+		kc.Close()
+
+		return err
+	}
+
+	defer func() {
+		log.Info("closing connection pool to database")
+		pool.Close()
+	}()
+
+	// each message is committed by the worker that processed it, once it has been handled. Retried messages that
+	// aren't due yet pause their retry topic partition until they are, rather than holding up the poll.
+	d := dispatcher.New(kc, config.PartitionWorkers(), hdlr.RetryWait, func(msg *kafka.Message) {
+		c.handleMessage(pool, msg)
+		commitMessage(kc, msg)
+	})
+
+	var topics []string
+	for _, topic := range c.Topics {
+		topics = append(topics, topic, config.RetryTopicName(topic))
+	}
+
+	if err = kc.SubscribeTopics(topics, d.Rebalance); err != nil {
+		log.WithField("error", err).
+			WithField("topics", c.Topics).
+			Error("Failed to subscribe to topics")
+		kc.Close()
+
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Warn("Closing consumer...")
+			d.Close()
+
+			return kc.Close()
+		default:
+		}
+
+		d.Resume()
+
+		msg, err := kc.ReadMessage(pollTimeout)
+		if err != nil {
+			if kerr, ok := err.(kafka.Error); ok && kerr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+
+			// The client will automatically try to recover from all errors.
+			log.WithField("error", err).Error(msg)
+
+			log.Warn("Closing consumer...")
+			d.Close()
+			kc.Close()
+
+			return err
+		}
+
+		log.WithField("topic", msg.TopicPartition).Info(string(msg.Value))
+
+		d.Dispatch(msg)
+	}
+}
+
+// commitMessage will commit the offset of a message once it has been handled one way or another
+func commitMessage(kc *kafka.Consumer, msg *kafka.Message) {
+	if _, err := kc.CommitMessage(msg); err != nil {
+		log.WithField("error", err).
+			WithField("topic", msg.TopicPartition).
+			Error("an issue occurred trying to commit the offset of the message")
+	}
+}
+
+// handleMessage will route a message to the handler for the event it holds, based on its headers. Orders have their
+// payment authorised once they pass the fraud check.
+func (c *Consumer) handleMessage(pool *pgxpool.Pool, msg *kafka.Message) {
+	ctx := headers.NewContext(context.Background(), headers.FromMessage(msg))
+
+	switch name := headers.Get(msg.Headers, headers.EventName); name {
+	case events.FraudCheckPassed{}.Name():
+		c.handleFraudCheckPassed(ctx, pool, msg)
+	case events.OrderShipped{}.Name():
+		c.handleOrderShipped(ctx, pool, msg)
+	case events.ReturnReceived{}.Name():
+		c.handleReturnReceived(ctx, pool, msg)
+	default:
+		log.WithField("event.name", name).
+			WithField("topic", msg.TopicPartition).
+			Debug("skipping an event this consumer doesn't handle")
+	}
+}
+
+// handleFraudCheckPassed will authorise the payment of a single FraudCheckPassed message and publish whether it was
+// authorised or declined, every failure is handed off to be retried or dead lettered
+func (c *Consumer) handleFraudCheckPassed(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
+
+	var event events.FraudCheckPassed
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
+
+	var order models.Order
+	if order, err = extractOrder(event); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to extract order information from the fraud check passed event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	var payment models.Payment
+	if err = hdlr.Retry(func() (err error) {
+		payment, err = c.authorizePayment(ctx, pool, event, order)
+		return err
+	}); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	if err = hdlr.Retry(func() error { return publishPaymentEvent(ctx, order, payment) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to publish a payment event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+}
+
+// handleOrderShipped will capture the payment of a single OrderShipped message, every failure is handed off to be
+// retried or dead lettered
+func (c *Consumer) handleOrderShipped(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
+
+	var event events.OrderShipped
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
+
+	order, ok := event.Body().(models.Order)
+	if !ok {
+		hdlr.HandleFailure(event, msg, hdlr.NewPermanentError(errors.New("event body can't be cast as an order")))
+		return
+	}
+
+	// the payment is captured once the last shipment of the order ships
+	if !order.Shipped() {
+		log.WithField("order.id", order.ID).
+			WithField("shipment.id", order.Shipment.ID).
+			Info("order isn't fully shipped yet, its payment is captured once it is")
+
+		return
+	}
+
+	if err = hdlr.Retry(func() error { return c.capturePayment(ctx, pool, event, order) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+}
+
+// handleReturnReceived will refund the products of a single ReturnReceived message and notify the customer, every
+// failure is handed off to be retried or dead lettered
+func (c *Consumer) handleReturnReceived(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
+
+	var event events.ReturnReceived
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
+
+	var refund *models.Refund
+	if err = hdlr.Retry(func() (err error) {
+		refund, err = c.refundPayment(ctx, pool, event, event.EventBody)
+		return err
+	}); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	// returns that weren't refunded have nothing to notify
+	if refund == nil {
+		return
+	}
+
+	if err = hdlr.Retry(func() error { return publishRefundNotification(ctx, event.EventBody, *refund) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to publish the notification of the refund")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+}
+
+func extractOrder(event events.FraudCheckPassed) (models.Order, error) {
+	log.Info("attempting to extract order from event")
+
+	body := event.Body()
+	order, ok := body.(models.Order)
+	if !ok {
+		return models.Order{}, hdlr.NewPermanentError(errors.New("event body can't be cast as an order"))
+	}
+
+	return order, nil
+}
+
+// authorizePayment authorises the payment of the order, unless the event was already processed or the order already
+// has a payment, in which case that payment is returned so the outcome can be published again
+func (c *Consumer) authorizePayment(ctx context.Context, pool *pgxpool.Pool, event events.Event, order models.Order) (payment models.Payment, err error) {
+	payments := db.NewDB()
+
+	// begin a transaction
+	tx, err := pool.Begin(context.Background())
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to start a database transaction")
+		return payment, err
+	}
+
+	defer func() {
+		if err != nil {
+			log.Info("rolling back DB transaction")
+			if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+				log.WithField("error", rbErr).Error("an issue occurred trying to roll back the transaction")
+			}
+
+			return
+		}
+
+		log.Info("committing DB transaction")
+		if err = tx.Commit(context.Background()); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to commit the transaction")
+		}
+	}()
+
+	// check to see if event has already been processed
+	eventAlreadyProcessed, err := payments.EventExists(consumerName, event, tx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to check if an event was already processed")
+		return payment, err
+	}
+
+	// if event has already been processed, the payment is already stored
+	if eventAlreadyProcessed {
+		log.WithField("event.id", event.ID()).
+			WithField("event.name", event.Name()).
+			Info("event was processed previously")
+
+		return payments.GetPayment(order.ID, tx)
+	}
+
+	// another event for the order, e.g. its fraud check passing again, mustn't authorise the payment twice
+	payment, err = payments.GetPayment(order.ID, tx)
+	switch {
+	case err == nil:
+		log.WithField("order.id", order.ID).
+			WithField("payment.status", payment.Status).
+			Info("order already has a payment")
+
+		if err = payments.InsertEvent(consumerName, event, tx); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to insert the event")
+		}
+
+		return payment, err
+	case !errors.Is(err, db.ErrPaymentNotFound):
+		log.WithField("error", err).Error("an issue occurred trying to get the payment of the order")
+		return payment, err
+	}
+
+	// event hasn't been processed yet, authorise the payment
+	if payment, err = handlers.AuthorizePayment(ctx, c.Gateway, order); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to authorise the payment")
+
+		return payment, err
+	}
+
+	if err = payments.InsertPayment(payment, tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to insert the payment")
+		return payment, err
+	}
+
+	// mark the event as processed
+	if err = payments.InsertEvent(consumerName, event, tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to insert the event")
+		return payment, err
+	}
+
+	return payment, nil
+}
+
+// capturePayment captures the authorised payment of an order that was fully shipped. Orders received before payments
+// were authorised have no payment to capture.
+func (c *Consumer) capturePayment(ctx context.Context, pool *pgxpool.Pool, event events.Event, order models.Order) (err error) {
+	payments := db.NewDB()
+
+	// begin a transaction
+	tx, err := pool.Begin(context.Background())
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to start a database transaction")
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			log.Info("rolling back DB transaction")
+			if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+				log.WithField("error", rbErr).Error("an issue occurred trying to roll back the transaction")
+			}
+
+			return
+		}
+
+		log.Info("committing DB transaction")
+		if err = tx.Commit(context.Background()); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to commit the transaction")
+		}
+	}()
+
+	// check to see if event has already been processed
+	eventAlreadyProcessed, err := payments.EventExists(consumerName, event, tx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to check if an event was already processed")
+		return err
+	}
+
+	// if event has already been processed, nothing more to do
+	if eventAlreadyProcessed {
+		log.WithField("event.id", event.ID()).
+			WithField("event.name", event.Name()).
+			Info("event was processed previously")
+
+		return nil
+	}
+
+	// event hasn't been processed yet, capture the payment
+	payment, err := payments.GetPayment(order.ID, tx)
+	switch {
+	case errors.Is(err, db.ErrPaymentNotFound):
+		log.WithField("order.id", order.ID).Warn("order has no payment to capture")
+		err = nil
+	case err != nil:
+		log.WithField("error", err).Error("an issue occurred trying to get the payment")
+		return err
+	case payment.Status == models.PaymentCaptured:
+		log.WithField("order.id", order.ID).Info("payment was captured previously")
+	default:
+		if err = handlers.CapturePayment(ctx, c.Gateway, payment); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to capture the payment")
+			return err
+		}
+
+		if err = payments.CapturePayment(order.ID, tx); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to record the capture of the payment")
+			return err
+		}
+	}
+
+	// mark the event as processed
+	if err = payments.InsertEvent(consumerName, event, tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to insert the event")
+		return err
+	}
+
+	return nil
+}
+
+// refundPayment refunds the return from the payment of its order, unless the event was already processed, in which
+// case the refund it got is returned so the customer can be notified again. Returns from orders without a payment, or
+// with nothing to refund, get no refund.
+func (c *Consumer) refundPayment(ctx context.Context, pool *pgxpool.Pool, event events.Event, ret models.Return) (refund *models.Refund, err error) {
+	payments := db.NewDB()
+
+	// begin a transaction
+	tx, err := pool.Begin(context.Background())
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to start a database transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			log.Info("rolling back DB transaction")
+			if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+				log.WithField("error", rbErr).Error("an issue occurred trying to roll back the transaction")
+			}
+
+			return
+		}
+
+		log.Info("committing DB transaction")
+		if err = tx.Commit(context.Background()); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to commit the transaction")
+		}
+	}()
+
+	// check to see if event has already been processed
+	eventAlreadyProcessed, err := payments.EventExists(consumerName, event, tx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to check if an event was already processed")
+		return nil, err
+	}
+
+	// if event has already been processed, the refund is already stored if there was one
+	if eventAlreadyProcessed {
+		log.WithField("event.id", event.ID()).
+			WithField("event.name", event.Name()).
+			Info("event was processed previously")
+
+		r, err := payments.GetRefund(ret.ID, tx)
+		if errors.Is(err, db.ErrRefundNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &r, nil
+	}
+
+	// event hasn't been processed yet, refund the return
+	payment, err := payments.GetPayment(ret.OrderID, tx)
+	switch {
+	case errors.Is(err, db.ErrPaymentNotFound):
+		log.WithField("order.id", ret.OrderID).Warn("order has no payment to refund")
+		err = nil
+	case err != nil:
+		log.WithField("error", err).Error("an issue occurred trying to get the payment")
+		return nil, err
+	case ret.Refund == 0:
+		log.WithField("return.id", ret.ID).Info("return has nothing to refund")
+	default:
+		var refunded models.Money
+		if refunded, err = payments.RefundedAmount(ret.OrderID, tx); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to add up the refunds of the payment")
+			return nil, err
+		}
+
+		var r models.Refund
+		if r, err = handlers.RefundPayment(ctx, c.Gateway, payment, ret, refunded); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to refund the return")
+			return nil, err
+		}
+
+		if err = payments.InsertRefund(r, tx); err != nil {
+			log.WithField("error", err).Error("an issue occurred trying to insert the refund")
+			return nil, err
+		}
+		refund = &r
+	}
+
+	// mark the event as processed
+	if err = payments.InsertEvent(consumerName, event, tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to insert the event")
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// publishRefundNotification publishes an email to the customer of the return, telling them it has been refunded
+func publishRefundNotification(ctx context.Context, ret models.Return, refund models.Refund) error {
+	e := events.Notification{
+		EventBase: events.BaseEvent{
+			EventID:        uuid.New(),
+			EventTimestamp: time.Now(),
+		},
+		EventBody: models.Notification{
+			Type:      models.Email,
+			Recipient: ret.Customer.EmailAddress,
+			From:      "returns@ppe4all.com",
+			Subject:   fmt.Sprintf("Hello %s, your refund is on its way.", ret.Customer.FirstName),
+			Body:      fmt.Sprintf("<div>We have refunded %s to your card for your return %s, it can take a few days to show up on your statement.</div>", refund.Amount.Format(refund.Currency), ret.ID),
+		},
+	}
+
+	if err := publisher.PublishEvent(e, config.NotificationTopicName, publisher.WithContext(ctx)); err != nil {
+		return err
+	}
+
+	log.WithField("event", e).Info("published event")
+
+	return nil
+}
+
+// publishPaymentEvent publishes the order with its payment, as a PaymentAuthorized event if the payment was
+// authorised or a PaymentDeclined event if it wasn't
+func publishPaymentEvent(ctx context.Context, o models.Order, payment models.Payment) error {
+	o.Payment = &payment
+
+	base := events.BaseEvent{
+		EventID:        uuid.New(),
+		EventTimestamp: time.Now(),
+	}
+
+	var e events.Event
+	topic := config.PaymentAuthorizedTopicName
+	if payment.Status == models.PaymentDeclined {
+		e = events.PaymentDeclined{EventBase: base, EventBody: o}
+		topic = config.PaymentDeclinedTopicName
+	} else {
+		e = events.PaymentAuthorized{EventBase: base, EventBody: o}
+	}
+
+	log.WithField("event", e).Info("transformed order to event")
+
+	if err := publisher.PublishEvent(e, topic, publisher.WithContext(ctx)); err != nil {
+		return err
+	}
+
+	log.WithField("event", e).Info("published event")
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// Fake is a payment gateway for local runs that never charges anyone. It is deterministic: the authorization ID
+// of a payment is derived from the order, and a payment is declined if the gateway always declines or the total
+// of the order is over the decline threshold. Like a real gateway, it declines amounts that aren't positive.
+type Fake struct {
+	DeclineAll  bool
+	DeclineOver models.Money // zero if payments aren't declined for their amount
+}
+
+// NewFake returns a fake gateway declining the payments described by the setting, either always or over an amount
+// in minor units, e.g. 10000. It declines nothing if the setting is empty.
+func NewFake(decline string) (Fake, error) {
+	switch decline {
+	case "":
+		return Fake{}, nil
+	case "always":
+		return Fake{DeclineAll: true}, nil
+	}
+
+	amount, err := strconv.ParseInt(decline, 10, 64)
+	if err != nil || amount <= 0 {
+		return Fake{}, fmt.Errorf("the fake payment gateway can decline always or over an amount, not %q", decline)
+	}
+
+	return Fake{DeclineOver: models.Money(amount)}, nil
+}
+
+// Authorize approves the payment of the order, unless it should be declined
+func (f Fake) Authorize(ctx context.Context, order models.Order) (models.Payment, error) {
+	payment := models.Payment{
+		OrderID:  order.ID,
+		Amount:   order.Totals.Total,
+		Currency: order.Currency,
+	}
+
+	switch {
+	case order.Totals.Total <= 0:
+		payment.Status = models.PaymentDeclined
+		payment.DeclineReason = "the amount should be greater than zero"
+	case f.DeclineAll:
+		payment.Status = models.PaymentDeclined
+		payment.DeclineReason = "the card was declined"
+	case f.DeclineOver > 0 && order.Totals.Total > f.DeclineOver:
+		payment.Status = models.PaymentDeclined
+		payment.DeclineReason = "the amount is over the limit of the card"
+	default:
+		payment.Status = models.PaymentAuthorized
+		payment.AuthorizationID = "fake_auth_" + order.ID.String()
+	}
+
+	return payment, nil
+}
+
+// Capture pretends to charge the customer
+func (f Fake) Capture(ctx context.Context, payment models.Payment) error {
+	log.WithField("order.id", payment.OrderID).
+		WithField("authorizationID", payment.AuthorizationID).
+		WithField("amount", payment.Amount.Format(payment.Currency)).
+		Info("capturing payment with the fake gateway")
+
+	return nil
+}
+
+// Refund pretends to give the customer back the amount, the refund ID is derived from the reference
+func (f Fake) Refund(ctx context.Context, payment models.Payment, amount models.Money, reference string) (string, error) {
+	log.WithField("order.id", payment.OrderID).
+		WithField("authorizationID", payment.AuthorizationID).
+		WithField("amount", amount.Format(payment.Currency)).
+		WithField("reference", reference).
+		Info("refunding payment with the fake gateway")
+
+	return "fake_refund_" + reference, nil
+}
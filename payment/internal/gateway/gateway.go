@@ -0,0 +1,34 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// PaymentGateway authorises, captures and refunds the payment of orders. Calls are keyed by the order, so making one again
+// for the same order doesn't charge the customer twice.
+type PaymentGateway interface {
+	// Authorize asks the gateway to approve the payment of the total of the order. A payment the gateway refuses is
+	// returned as a declined payment, an error means the gateway couldn't be asked.
+	Authorize(ctx context.Context, order models.Order) (models.Payment, error)
+
+	// Capture charges the customer the amount of an authorised payment
+	Capture(ctx context.Context, payment models.Payment) error
+
+	// Refund gives the customer back an amount of a captured payment. The reference identifies the refund, so
+	// asking for it again doesn't refund the customer twice, and the gateway's ID for the refund is returned.
+	Refund(ctx context.Context, payment models.Payment, amount models.Money, reference string) (string, error)
+}
+
+// New returns the gateway configured by PAYMENT_GATEWAY, the fake gateway is the only one so far
+func New() (PaymentGateway, error) {
+	switch gateway := config.PaymentGateway(); gateway {
+	case "fake":
+		return NewFake(config.FakePaymentDecline())
+	default:
+		return nil, fmt.Errorf("unknown payment gateway %q, should be fake", gateway)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/payment/internal/gateway"
+)
+
+// AuthorizePayment will ask the gateway to authorise the payment of the total of the order, the payment is either
+// authorised or declined. An order without an ID is a permanent error, and a gateway that can't be reached is retryable.
+func AuthorizePayment(ctx context.Context, gw gateway.PaymentGateway, order models.Order) (models.Payment, error) {
+	if order.ID == uuid.Nil {
+		return models.Payment{}, hdlr.NewPermanentError(fmt.Errorf("order has no ID, its payment can't be authorised"))
+	}
+
+	log.WithField("order.id", order.ID).
+		WithField("amount", order.Totals.Total.Format(order.Currency)).
+		Info("attempting to authorise the payment of the order")
+
+	payment, err := gw.Authorize(ctx, order)
+	if err != nil {
+		return models.Payment{}, hdlr.NewRetryableError(err)
+	}
+
+	log.WithField("order.id", order.ID).
+		WithField("payment.status", payment.Status).
+		WithField("payment.declineReason", payment.DeclineReason).
+		Info("payment of the order was processed by the gateway")
+
+	return payment, nil
+}
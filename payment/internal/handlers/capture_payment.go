@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/payment/internal/gateway"
+)
+
+// CapturePayment will charge the customer the authorised payment of an order that was shipped. Capturing a payment
+// that isn't authorised is rejected, and a gateway that can't be reached is retryable.
+func CapturePayment(ctx context.Context, gw gateway.PaymentGateway, payment models.Payment) error {
+	if payment.Status != models.PaymentAuthorized {
+		return hdlr.NewRejectedError(fmt.Errorf("the payment of order [%s] is %s, only authorised payments can be captured", payment.OrderID, payment.Status))
+	}
+
+	log.WithField("order.id", payment.OrderID).
+		WithField("amount", payment.Amount.Format(payment.Currency)).
+		Info("attempting to capture the payment of the order")
+
+	if err := gw.Capture(ctx, payment); err != nil {
+		return hdlr.NewRetryableError(err)
+	}
+
+	return nil
+}
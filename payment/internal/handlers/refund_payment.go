@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/payment/internal/gateway"
+)
+
+// RefundPayment will give the customer back the refund of a return that was received, from the captured payment of
+// the order, given how much of the payment has already been refunded. Refunding a payment that isn't captured, or
+// more than is left of it, is rejected, and a gateway that can't be reached is retryable.
+func RefundPayment(ctx context.Context, gw gateway.PaymentGateway, payment models.Payment, ret models.Return, refunded models.Money) (models.Refund, error) {
+	if payment.Status != models.PaymentCaptured {
+		return models.Refund{}, hdlr.NewRejectedError(fmt.Errorf("the payment of order [%s] is %s, only captured payments can be refunded", payment.OrderID, payment.Status))
+	}
+
+	if refunded+ret.Refund > payment.Amount {
+		return models.Refund{}, hdlr.NewRejectedError(fmt.Errorf("return [%s] can't be refunded %s, only %s of the payment of order [%s] is left",
+			ret.ID, ret.Refund.Format(payment.Currency), (payment.Amount - refunded).Format(payment.Currency), payment.OrderID))
+	}
+
+	log.WithField("order.id", payment.OrderID).
+		WithField("return.id", ret.ID).
+		WithField("amount", ret.Refund.Format(payment.Currency)).
+		Info("attempting to refund the return")
+
+	refundID, err := gw.Refund(ctx, payment, ret.Refund, ret.ID.String())
+	if err != nil {
+		return models.Refund{}, hdlr.NewRetryableError(err)
+	}
+
+	return models.Refund{
+		ReturnID: ret.ID,
+		OrderID:  payment.OrderID,
+		RefundID: refundID,
+		Amount:   ret.Refund,
+		Currency: payment.Currency,
+	}, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/payment/cmd/consumer"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/payment/internal/gateway"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	// Log as JSON instead of the default ASCII formatter.
+	log.SetFormatter(&log.JSONFormatter{})
+
+	// Output to stdout instead of the default stderr
+	// Can be any io.Writer, see below for File example
+	log.SetOutput(os.Stdout)
+
+	// Only log the warning severity or above.
+	log.SetLevel(config.LogLevel())
+
+	// Identify this service as the producer of the events it publishes
+	publisher.ProducerName = "payment"
+}
+
+func main() {
+	startTime := time.Now()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sig := <-sigs
+		log.WithField("uptime", time.Since(startTime).String()).
+			WithField("signal", sig.String()).
+			Warn("interrupt signal detected, shutting down")
+		cancel()
+	}()
+
+	gw, err := gateway.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c := consumer.Consumer{
+		Broker:  config.BrokerAddress(),
+		Group:   config.ConsumerGroup(),
+		Topics:  []string{config.FraudCheckPassedTopicName, config.OrderShippedTopicName, config.ReturnReceivedTopicName},
+		Gateway: gw,
+	}
+
+	if err = c.SubscribeAndListen(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	// flush anything still waiting to be delivered before exiting
+	publisher.Close()
+
+	log.WithField("uptime", time.Since(startTime).String()).Info("shutdown complete")
+}
@@ -0,0 +1,50 @@
+package publisher
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
+)
+
+// Option customises the message an event is published in
+type Option func(*kafka.Message)
+
+// WithKey sets the key of the message, messages with the same key are always published to the same partition.
+// It overrides the key an event provides itself, an empty key leaves the message as it is.
+func WithKey(key string) Option {
+	return func(m *kafka.Message) {
+		if len(key) > 0 {
+			m.Key = []byte(key)
+		}
+	}
+}
+
+// WithHeaders sets headers on the message, replacing any headers with the same keys
+func WithHeaders(hs ...kafka.Header) Option {
+	return func(m *kafka.Message) {
+		for _, h := range hs {
+			m.Headers = headers.Set(m.Headers, h.Key, string(h.Value))
+		}
+	}
+}
+
+// WithContext carries the correlation ID and trace context of the message or request being handled, found in
+// the context, over to the message being published
+func WithContext(ctx context.Context) Option {
+	return func(m *kafka.Message) {
+		md, ok := headers.FromContext(ctx)
+		if !ok {
+			return
+		}
+
+		if len(md.CorrelationID) > 0 {
+			m.Headers = headers.Set(m.Headers, headers.CorrelationID, md.CorrelationID)
+		}
+
+		if len(md.TraceParent) > 0 {
+			m.Headers = headers.Set(m.Headers, headers.TraceParent, headers.ChildTraceParent(md.TraceParent))
+		}
+	}
+}
@@ -1,54 +1,204 @@
 package publisher
 
 import (
-	"encoding/json"
+	"strconv"
+	"sync"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/codec"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
 )
 
-// PublishEvent will publish the specified event to the messaging system (currently running on localhost)
-func PublishEvent(event events.Event, topic string) error {
+const (
+	// flushTimeoutMs is how long Close will wait for outstanding messages to be delivered
+	flushTimeoutMs = 15000
+)
 
-	log.WithField("event", event).Info("attempting to publish event")
+// ProducerName is the name of the service publishing events, it is set as the producer header of every event
+var ProducerName = "order-fulfillment"
 
-	p, err := kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers":   config.BrokerAddress(),
-		"socket.timeout.ms":   30000,
-		"delivery.timeout.ms": 30000})
+var (
+	producer *kafka.Producer
+	mu       sync.Mutex
+)
+
+// PublishEvent will publish the specified event to the messaging system (currently running on localhost),
+// encoded in the format configured for the topic. Events that are keyed are published with their key unless
+// another key is specified. Every event is published with the standard headers, the correlation ID defaults
+// to the key of the event and the trace context to a new trace. The schema version header is the current
+// version of the event, consumers use it to upcast events published with older versions.
+func PublishEvent(event events.Event, topic string, opts ...Option) error {
+	msg, err := eventMessage(event, topic, opts...)
 	if err != nil {
 		return err
 	}
 
-	// Optional delivery channel, if not specified the Producer object's
-	// .Events channel is used.
-	deliveryChan := make(chan kafka.Event)
+	return produce(msg)[0]
+}
+
+// PublishEvents will publish the specified events to the messaging system like PublishEvent, but as one batch:
+// every event is handed to the producer before waiting for any of them to be delivered. It returns an error for
+// each event, in the same order, which is nil if the event was delivered.
+func PublishEvents(evs []events.Event, topic string, opts ...Option) []error {
+	errs := make([]error, len(evs))
+
+	var msgs []*kafka.Message
+	var indexes []int
+	for i, event := range evs {
+		msg, err := eventMessage(event, topic, opts...)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		msgs = append(msgs, msg)
+		indexes = append(indexes, i)
+	}
+
+	for i, err := range produce(msgs...) {
+		errs[indexes[i]] = err
+	}
+
+	return errs
+}
+
+// PublishMessage will publish an already encoded message value to the messaging system
+func PublishMessage(value []byte, topic string, opts ...Option) error {
+	return produce(newMessage(value, topic, opts...))[0]
+}
+
+// eventMessage encodes the event in a message with the standard headers
+func eventMessage(event events.Event, topic string, opts ...Option) (*kafka.Message, error) {
+
+	log.WithField("event", event).Info("attempting to publish event")
 
 	var value []byte
-	if value, err = json.Marshal(event); err != nil {
-		return err
+	var formatHeaders []kafka.Header
+	var err error
+	if value, formatHeaders, err = codec.Encode(event, codec.FormatFor(topic), "/order-fulfillment/"+ProducerName); err != nil {
+		return nil, err
 	}
-	err = p.Produce(&kafka.Message{
+
+	correlationID := event.ID().String()
+	if keyed, ok := event.(events.Keyed); ok {
+		if len(keyed.Key()) > 0 {
+			correlationID = keyed.Key()
+		}
+
+		opts = append([]Option{WithKey(keyed.Key())}, opts...)
+	}
+
+	defaults := WithHeaders(
+		kafka.Header{Key: headers.EventName, Value: []byte(event.Name())},
+		kafka.Header{Key: headers.SchemaVersion, Value: []byte(strconv.Itoa(events.SchemaVersion(event.Name())))},
+		kafka.Header{Key: headers.CorrelationID, Value: []byte(correlationID)},
+		kafka.Header{Key: headers.Producer, Value: []byte(ProducerName)},
+		kafka.Header{Key: headers.TraceParent, Value: []byte(headers.ChildTraceParent(""))},
+	)
+
+	return newMessage(value, topic, append([]Option{defaults, WithHeaders(formatHeaders...)}, opts...)...), nil
+}
+
+func newMessage(value []byte, topic string, opts ...Option) *kafka.Message {
+	msg := &kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
 		Value:          value,
-	}, deliveryChan)
+	}
+	for _, opt := range opts {
+		opt(msg)
+	}
+
+	return msg
+}
+
+// produce hands every message to the producer, then waits for all of them to be delivered. It returns an error
+// for each message, in the same order, which is nil if the message was delivered.
+func produce(msgs ...*kafka.Message) []error {
+	errs := make([]error, len(msgs))
 
-	e := <-deliveryChan
-	m := e.(*kafka.Message)
+	p, err := sharedProducer()
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	// Optional delivery channel, if not specified the Producer object's
+	// .Events channel is used.
+	deliveryChan := make(chan kafka.Event, len(msgs))
 
-	if m.TopicPartition.Error != nil {
-		return m.TopicPartition.Error
+	pending := 0
+	for i, msg := range msgs {
+		// the opaque is handed back in the delivery report, so it can be matched to the message
+		msg.Opaque = i
+		if errs[i] = p.Produce(msg, deliveryChan); errs[i] == nil {
+			pending++
+		}
 	}
 
-	log.WithField("Name", *m.TopicPartition.Topic).
-		WithField("Partition", m.TopicPartition.Partition).
-		WithField("PartitionOffset", m.TopicPartition.Offset).
-		Infof("Delivered message to topic")
+	for ; pending > 0; pending-- {
+		e := <-deliveryChan
+		m := e.(*kafka.Message)
+		i := m.Opaque.(int)
+
+		if m.TopicPartition.Error != nil {
+			errs[i] = m.TopicPartition.Error
+			continue
+		}
+
+		log.WithField("Name", *m.TopicPartition.Topic).
+			WithField("Partition", m.TopicPartition.Partition).
+			WithField("PartitionOffset", m.TopicPartition.Offset).
+			Infof("Delivered message to topic")
+	}
 
 	close(deliveryChan)
 
-	return nil
+	return errs
+}
+
+// Close will wait for any outstanding messages to be delivered and close the producer shared by the process.
+// It should be called once the process has stopped publishing, typically when shutting down.
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if producer == nil {
+		return
+	}
+
+	log.Info("flushing outstanding messages")
+	if remaining := producer.Flush(flushTimeoutMs); remaining > 0 {
+		log.WithField("remaining", remaining).Warn("some messages were not delivered before closing the producer")
+	}
+
+	producer.Close()
+	producer = nil
+}
+
+// sharedProducer returns the producer shared by the process, creating it the first time it is needed
+func sharedProducer() (*kafka.Producer, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if producer != nil {
+		return producer, nil
+	}
+
+	p, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":   config.BrokerAddress(),
+		"socket.timeout.ms":   30000,
+		"delivery.timeout.ms": 30000})
+	if err != nil {
+		return nil, err
+	}
+
+	producer = p
+
+	return producer, nil
 }
@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConfluentClient is a client for the REST API of the Confluent schema registry, which checks compatibility
+// itself based on the compatibility level configured for the subject. Schemas are cached once fetched.
+type ConfluentClient struct {
+	URL    string
+	client *http.Client
+
+	mu      sync.Mutex
+	schemas map[int]string
+}
+
+// NewConfluentClient returns a client for the schema registry at the URL
+func NewConfluentClient(url string) *ConfluentClient {
+	return &ConfluentClient{
+		URL:     strings.TrimSuffix(url, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		schemas: make(map[int]string),
+	}
+}
+
+// Register returns the ID of the schema under the subject, registering it if it hasn't been registered before
+func (cc *ConfluentClient) Register(subject, schema string) (int, error) {
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		ID int `json:"id"`
+	}
+	if err = cc.do(http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", body, &response); err != nil {
+		return 0, err
+	}
+
+	cc.mu.Lock()
+	cc.schemas[response.ID] = schema
+	cc.mu.Unlock()
+
+	return response.ID, nil
+}
+
+// SchemaByID returns the schema with the ID
+func (cc *ConfluentClient) SchemaByID(id int) (string, error) {
+	cc.mu.Lock()
+	schema, found := cc.schemas[id]
+	cc.mu.Unlock()
+	if found {
+		return schema, nil
+	}
+
+	var response struct {
+		Schema string `json:"schema"`
+	}
+	if err := cc.do(http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &response); err != nil {
+		return "", err
+	}
+
+	cc.mu.Lock()
+	cc.schemas[id] = response.Schema
+	cc.mu.Unlock()
+
+	return response.Schema, nil
+}
+
+func (cc *ConfluentClient) do(method, path string, body []byte, v interface{}) error {
+	req, err := http.NewRequest(method, cc.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+
+	resp, err := cc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var registryErr struct {
+			ErrorCode int    `json:"error_code"`
+			Message   string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&registryErr)
+
+		return fmt.Errorf("schema registry returned %d: %s", resp.StatusCode, registryErr.Message)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/avro"
+)
+
+// FileRegistry is a schema registry kept in a directory, with a file per registered schema named after its ID.
+// It is meant for development and tests, services running on the same machine can share the directory.
+type FileRegistry struct {
+	Dir string
+}
+
+// registered is the content of the file of a registered schema
+type registered struct {
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+	Schema  string `json:"schema"`
+}
+
+// NewFileRegistry returns a registry kept in the directory
+func NewFileRegistry(dir string) *FileRegistry {
+	return &FileRegistry{Dir: dir}
+}
+
+// Register returns the ID of the schema under the subject, registering it as the latest version if it
+// hasn't been registered before. A schema that can't read data written with the latest version is refused.
+func (fr *FileRegistry) Register(subject, schema string) (int, error) {
+	if err := os.MkdirAll(fr.Dir, 0755); err != nil {
+		return 0, err
+	}
+
+	all, err := fr.all()
+	if err != nil {
+		return 0, err
+	}
+
+	var latest registered
+	next := 1
+	for id, r := range all {
+		if id >= next {
+			next = id + 1
+		}
+
+		if r.Subject != subject {
+			continue
+		}
+
+		if r.Schema == schema {
+			return id, nil
+		}
+
+		if r.Version > latest.Version {
+			latest = r
+		}
+	}
+
+	if latest.Version > 0 {
+		if err = checkCompatibility(schema, latest.Schema); err != nil {
+			return 0, fmt.Errorf("schema is not compatible with version %d of %s: %w", latest.Version, subject, err)
+		}
+	}
+
+	b, err := json.Marshal(registered{Subject: subject, Version: latest.Version + 1, Schema: schema})
+	if err != nil {
+		return 0, err
+	}
+
+	// another process may register a schema at the same time, so only create the file if the ID is still free
+	for id := next; ; id++ {
+		f, err := os.OpenFile(fr.path(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = f.Write(b)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+
+		return id, err
+	}
+}
+
+// SchemaByID returns the schema with the ID
+func (fr *FileRegistry) SchemaByID(id int) (string, error) {
+	b, err := os.ReadFile(fr.path(id))
+	if err != nil {
+		return "", fmt.Errorf("schema %d is not registered: %w", id, err)
+	}
+
+	var r registered
+	if err = json.Unmarshal(b, &r); err != nil {
+		return "", err
+	}
+
+	return r.Schema, nil
+}
+
+func (fr *FileRegistry) all() (map[int]registered, error) {
+	entries, err := os.ReadDir(fr.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	all := make(map[int]registered)
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(fr.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var r registered
+		if err = json.Unmarshal(b, &r); err != nil {
+			return nil, fmt.Errorf("schema %d is corrupt: %w", id, err)
+		}
+
+		all[id] = r
+	}
+
+	return all, nil
+}
+
+func (fr *FileRegistry) path(id int) string {
+	return filepath.Join(fr.Dir, fmt.Sprintf("%d.json", id))
+}
+
+// checkCompatibility returns an error if data written with the previous schema can't be read with the new one
+func checkCompatibility(schema, previous string) error {
+	reader, err := avro.Parse(schema)
+	if err != nil {
+		return err
+	}
+
+	writer, err := avro.Parse(previous)
+	if err != nil {
+		return err
+	}
+
+	return avro.CanRead(reader, writer)
+}
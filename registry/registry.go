@@ -0,0 +1,26 @@
+package registry
+
+import (
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+)
+
+// Client is a schema registry that hands out IDs for the schemas messages are encoded with, so a consumer
+// can find the schema a message was written with from the ID in the message
+type Client interface {
+	// Register returns the ID of the schema under the subject, registering it as the latest version if it
+	// hasn't been registered before. A schema that isn't compatible with the latest version is refused.
+	Register(subject, schema string) (int, error)
+
+	// SchemaByID returns the schema with the ID
+	SchemaByID(id int) (string, error)
+}
+
+// NewClient returns a client for the Confluent schema registry when one is configured, or the file backed
+// registry used for development otherwise
+func NewClient() Client {
+	if url := config.SchemaRegistryURL(); len(url) > 0 {
+		return NewConfluentClient(url)
+	}
+
+	return NewFileRegistry(config.SchemaRegistryDir())
+}
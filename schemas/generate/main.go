@@ -0,0 +1,345 @@
+// Command generate writes a new version of the Avro schema of every event whose Go type has changed since
+// its latest version, after checking the new version can read data written with the previous one.
+//
+// It also checks the fixture of every version of every event in events/testdata still decodes into the Go
+// type once it has been upcast, and writes the fixture of the current version by upcasting the latest one.
+//
+//	$ go run ./schemas/generate          # write new versions
+//	$ go run ./schemas/generate -check   # fail if a schema is out of date or incompatible
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/avro"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/schemas"
+)
+
+// encoded lists every event that can be encoded with Avro. Error isn't one of them, since its body can be any event.
+var encoded = []events.Event{
+	events.OrderReceived{},
+	events.FraudCheckPassed{},
+	events.OrderHeld{},
+	events.OrderConfirmed{},
+	events.OrderPickedAndPacked{},
+	events.PaymentAuthorized{},
+	events.PaymentDeclined{},
+	events.OrderShipped{},
+	events.ReturnReceived{},
+	events.Notification{},
+	events.OrderCountMetric{},
+	events.OrderTimeMetric{},
+	events.Rejection{},
+}
+
+func main() {
+	check := flag.Bool("check", false, "fail instead of writing new versions")
+	dir := flag.String("dir", "schemas/avro", "directory the schemas are written to")
+	fixturesDir := flag.String("fixtures", "events/testdata", "directory the event fixtures are in")
+	flag.Parse()
+
+	failed := false
+	for _, event := range encoded {
+		if err := generate(event, *dir, *check); err != nil {
+			log.WithField("event", event.Name()).WithField("error", err).Error("schema is not up to date")
+			failed = true
+		}
+
+		if err := fixtures(event, *fixturesDir, *check); err != nil {
+			log.WithField("event", event.Name()).WithField("error", err).Error("fixtures don't decode")
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func generate(event events.Event, dir string, check bool) error {
+	g := generator{defined: make(map[string]bool)}
+	raw, err := g.schema(reflect.TypeOf(event))
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	generated := string(b) + "\n"
+
+	next := 1
+	if latest, version, err := schemas.Latest(event.Name()); err == nil {
+		if latest == generated {
+			log.WithField("event", event.Name()).WithField("version", version).Info("schema is up to date")
+			return nil
+		}
+
+		if err = compatible(generated, latest); err != nil {
+			return fmt.Errorf("version %d is not compatible with version %d: %w", version+1, version, err)
+		}
+
+		next = version + 1
+	}
+
+	if check {
+		return fmt.Errorf("the Go type has changed, version %d needs to be generated", next)
+	}
+
+	file := filepath.Join(dir, fmt.Sprintf("%s.v%d.avsc", event.Name(), next))
+	log.WithField("file", file).Info("writing new schema version")
+
+	return os.WriteFile(file, []byte(generated), 0644)
+}
+
+// fixtures checks the fixture of every version of the event, named <event name>.v<version>.json, decodes
+// into the Go type without any unknown fields once it has been upcast to the current version
+func fixtures(event events.Event, dir string, check bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var versions []int
+	for _, entry := range entries {
+		name, rest, found := strings.Cut(entry.Name(), ".v")
+		if !found || name != event.Name() {
+			continue
+		}
+
+		if v, err := strconv.Atoi(strings.TrimSuffix(rest, ".json")); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.Ints(versions)
+
+	if len(versions) == 0 {
+		return fmt.Errorf("there is no fixture, add one of version 1 to %s", dir)
+	}
+
+	var latest []byte
+	for _, v := range versions {
+		b, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%s.v%d.json", event.Name(), v)))
+		if err != nil {
+			return err
+		}
+
+		if err = decodeStrict(event, v, b); err != nil {
+			return fmt.Errorf("version %d: %w", v, err)
+		}
+
+		latest = b
+	}
+
+	current := events.SchemaVersion(event.Name())
+	if versions[len(versions)-1] >= current {
+		return nil
+	}
+
+	if check {
+		return fmt.Errorf("there is no fixture of version %d", current)
+	}
+
+	upcast, err := events.Upcast(event.Name(), versions[len(versions)-1], latest)
+	if err != nil {
+		return err
+	}
+
+	var indented bytes.Buffer
+	if err = json.Indent(&indented, upcast, "", "  "); err != nil {
+		return err
+	}
+	indented.WriteString("\n")
+
+	file := filepath.Join(dir, fmt.Sprintf("%s.v%d.json", event.Name(), current))
+	log.WithField("file", file).Info("writing new fixture version")
+
+	return os.WriteFile(file, indented.Bytes(), 0644)
+}
+
+// decodeStrict upcasts a fixture of the specified version and decodes it into a new value of the type of the event
+func decodeStrict(event events.Event, version int, value []byte) error {
+	upcast, err := events.Upcast(event.Name(), version, value)
+	if err != nil {
+		return err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(upcast))
+	d.DisallowUnknownFields()
+
+	return d.Decode(reflect.New(reflect.TypeOf(event)).Interface())
+}
+
+func compatible(reader, writer string) error {
+	r, err := avro.Parse(reader)
+	if err != nil {
+		return err
+	}
+
+	w, err := avro.Parse(writer)
+	if err != nil {
+		return err
+	}
+
+	return avro.CanRead(r, w)
+}
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// record and field keep the attributes of the schema in the order they're usually written in
+type record struct {
+	Type   string        `json:"type"`
+	Name   string        `json:"name"`
+	Fields []interface{} `json:"fields"`
+}
+
+type field struct {
+	Name    string      `json:"name"`
+	Type    interface{} `json:"type"`
+	Default interface{} `json:"default"`
+}
+
+// generator builds the JSON representation of the Avro schema of a Go type, every field gets a default so
+// adding a field to a type is always backward compatible
+type generator struct {
+	defined map[string]bool
+}
+
+func (g generator) schema(t reflect.Type) (interface{}, error) {
+	switch t {
+	case uuidType:
+		return map[string]interface{}{"type": "string", "logicalType": "uuid"}, nil
+	case timeType:
+		return map[string]interface{}{"type": "long", "logicalType": "timestamp-micros"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string", nil
+	case reflect.Bool:
+		return "boolean", nil
+	case reflect.Int32, reflect.Int16, reflect.Int8, reflect.Uint16, reflect.Uint8:
+		return "int", nil
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return "long", nil
+	case reflect.Float32:
+		return "float", nil
+	case reflect.Float64:
+		return "double", nil
+	case reflect.Slice:
+		items, err := g.schema(t.Elem())
+		return map[string]interface{}{"type": "array", "items": items}, err
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map keys of %s must be strings", t)
+		}
+		values, err := g.schema(t.Elem())
+		return map[string]interface{}{"type": "map", "values": values}, err
+	case reflect.Ptr:
+		elem, err := g.schema(t.Elem())
+		return []interface{}{"null", elem}, err
+	case reflect.Struct:
+		return g.record(t)
+	}
+
+	return nil, fmt.Errorf("%s can't be represented in Avro", t)
+}
+
+func (g generator) record(t reflect.Type) (interface{}, error) {
+	name := schemas.Namespace + "." + filepath.Base(t.PkgPath()) + "." + t.Name()
+	if g.defined[name] {
+		return name, nil
+	}
+	g.defined[name] = true
+
+	fields := []interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		fieldName := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if len(tagName) > 0 {
+				fieldName = tagName
+			}
+		}
+
+		fieldType, err := g.schema(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+
+		fields = append(fields, field{Name: fieldName, Type: fieldType, Default: defaultValue(f.Type)})
+	}
+
+	return record{Type: "record", Name: name, Fields: fields}, nil
+}
+
+// defaultValue returns the default of a field of the type, which is what its zero value marshals to in JSON
+func defaultValue(t reflect.Type) interface{} {
+	switch t {
+	case uuidType:
+		return uuid.Nil.String()
+	case timeType:
+		return 0
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return ""
+	case reflect.Bool:
+		return false
+	case reflect.Slice:
+		return []interface{}{}
+	case reflect.Map:
+		return map[string]interface{}{}
+	case reflect.Ptr:
+		return nil
+	case reflect.Struct:
+		fields := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := f.Name
+			if tag, ok := f.Tag.Lookup("json"); ok {
+				tagName, _, _ := strings.Cut(tag, ",")
+				if tagName == "-" {
+					continue
+				}
+				if len(tagName) > 0 {
+					name = tagName
+				}
+			}
+			fields[name] = defaultValue(f.Type)
+		}
+		return fields
+	}
+
+	return 0
+}
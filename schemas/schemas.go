@@ -0,0 +1,63 @@
+package schemas
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Namespace is the namespace of every schema, followed by the Go package of the type
+const Namespace = "com.ppe4all"
+
+// files holds every version of every schema, named <event name>.v<version>.avsc. They are generated from the
+// Go types with `go run ./schemas/generate` and should never be edited once they have been committed.
+//
+//go:embed avro/*.avsc
+var files embed.FS
+
+// Versions returns every version of the schema of the event, oldest first
+func Versions(name string) []int {
+	entries, _ := files.ReadDir("avro")
+
+	var versions []int
+	for _, entry := range entries {
+		event, rest, found := strings.Cut(entry.Name(), ".v")
+		if !found || event != name {
+			continue
+		}
+
+		if v, err := strconv.Atoi(strings.TrimSuffix(rest, ".avsc")); err == nil {
+			versions = append(versions, v)
+		}
+	}
+
+	sort.Ints(versions)
+
+	return versions
+}
+
+// Version returns the specified version of the schema of the event
+func Version(name string, version int) (string, error) {
+	b, err := files.ReadFile(path.Join("avro", fmt.Sprintf("%s.v%d.avsc", name, version)))
+	if err != nil {
+		return "", fmt.Errorf("there is no version %d of the %s schema", version, name)
+	}
+
+	return string(b), nil
+}
+
+// Latest returns the latest version of the schema of the event, along with its version number
+func Latest(name string) (string, int, error) {
+	versions := Versions(name)
+	if len(versions) == 0 {
+		return "", 0, fmt.Errorf("there is no schema for %s", name)
+	}
+
+	latest := versions[len(versions)-1]
+	schema, err := Version(name, latest)
+
+	return schema, latest, err
+}
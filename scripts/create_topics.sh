@@ -3,17 +3,47 @@
 # Set this to the location where Kafka has been installed
 KAFKA_HOME=~/devops/kafka-3.5.1-src
 
+# Events are keyed by order ID (or recipient for notifications), so every event for an order lands on the same
+# partition and is consumed in order no matter how many partitions a topic has
+PARTITIONS=3
+
 # Create the OrderReceived topic
-$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions 1 --config retention.ms=10800000 --topic OrderReceived
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic OrderReceived
+
+# Create the FraudCheckPassed topic
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic FraudCheckPassed
+
+# Create the OrderHeld topic
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic OrderHeld
+
+# Create the PaymentAuthorized topic
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic PaymentAuthorized
+
+# Create the PaymentDeclined topic
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic PaymentDeclined
 
 # Create the OrderConfirmed topic
-$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions 1 --config retention.ms=10800000 --topic OrderConfirmed
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic OrderConfirmed
 
 # Create the OrderPickedAndPacked topic
-$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions 1 --config retention.ms=10800000 --topic OrderPickedAndPacked
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic OrderPickedAndPacked
+
+# Create the OrderShipped topic
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic OrderShipped
+
+# Create the ReturnReceived topic
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic ReturnReceived
 
 # Create the Notification topic
-$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions 1 --config retention.ms=10800000 --topic Notification
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic Notification
 
 # Create the DeadLetterQueue topic
-$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions 1 --config retention.ms=10800000 --topic DeadLetterQueue
\ No newline at end of file
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic DeadLetterQueue
+
+# Create the Rejections topic
+$KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic Rejections
+
+# Create the retry topics, one for each topic a consumer subscribes to
+for topic in OrderReceived FraudCheckPassed OrderHeld PaymentAuthorized PaymentDeclined OrderConfirmed OrderPickedAndPacked OrderShipped ReturnReceived Notification Rejections; do
+    $KAFKA_HOME/bin/kafka-topics.sh --create --bootstrap-server localhost:9092 --replication-factor 1 --partitions $PARTITIONS --config retention.ms=10800000 --topic ${topic}Retry
+done
@@ -2,39 +2,60 @@ package consumer
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"strconv"
+	"time"
 
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/codec"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/dispatcher"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
 	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/metrics"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/carrier"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/label"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/sla"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/jackc/pgx/v4/pgxpool"
 )
 
-// Consumer represents the subscription to a specified Kafka topic
+// Consumer represents the subscription to a specified Kafka topic, shipments are shopped between the carriers and
+// their labels are kept in the label store
 type Consumer struct {
-	Broker string
-	Group  string
-	Topic  string
+	Broker   string
+	Group    string
+	Topic    string
+	Carriers []carrier.Carrier
+	Labels   *label.Store
 }
 
-// SubscribeAndListen will subscribe to a Kafka topic and start polling and listening for events
+// pollTimeout is how long to wait for a message before checking if the consumer should shut down
+const pollTimeout = 500 * time.Millisecond
+
+// consumerName is the name the events this consumer processed are recorded under, so another service consuming the
+// same events still processes them
+const consumerName = "shipper"
+
+// SubscribeAndListen will subscribe to a Kafka topic and start polling and listening for events until the
+// context is cancelled. The message being processed when the context is cancelled is finished and committed
+// before the consumer leaves the group.
 // Adpated from https://github.com/confluentinc/confluent-kafka-go#examples
-func (c *Consumer) SubscribeAndListen() error {
+func (c *Consumer) SubscribeAndListen(ctx context.Context) error {
 
 	kc, err := kafka.NewConsumer(&kafka.ConfigMap{
 		"bootstrap.servers":     c.Broker,
 		"broker.address.family": "v4",
 		"group.id":              c.Group + "-shipper",
 		"session.timeout.ms":    6000,
+		"enable.auto.commit":    false,
 		"auto.offset.reset":     "earliest"})
 
 	if err != nil {
@@ -45,21 +66,58 @@ func (c *Consumer) SubscribeAndListen() error {
 
 	log.WithField("consumer", kc).Info("Created Consumer")
 
-	if err = kc.SubscribeTopics([]string{c.Topic}, nil); err != nil {
+	pool, err := db.NewDB().ConnectPool(ctx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
+		kc.Close()
+
+		return err
+	}
+
+	defer func() {
+		log.Info("closing connection pool to database")
+		pool.Close()
+	}()
+
+	// each message is committed by the worker that processed it, once it has been handled. Retried messages that
+	// aren't due yet pause their retry topic partition until they are, rather than holding up the poll.
+	d := dispatcher.New(kc, config.PartitionWorkers(), hdlr.RetryWait, func(msg *kafka.Message) {
+		c.handleMessage(pool, msg)
+		commitMessage(kc, msg)
+	})
+
+	if err = kc.SubscribeTopics([]string{c.Topic, config.RetryTopicName(c.Topic)}, d.Rebalance); err != nil {
 		log.WithField("error", err).
 			WithField("topic", c.Topic).
 			Error("Failed to subscribe to topic")
+		kc.Close()
 
 		return err
 	}
 
 	for {
-		msg, err := kc.ReadMessage(-1)
+		select {
+		case <-ctx.Done():
+			log.Warn("Closing consumer...")
+			d.Close()
+
+			return kc.Close()
+		default:
+		}
+
+		d.Resume()
+
+		msg, err := kc.ReadMessage(pollTimeout)
 		if err != nil {
+			if kerr, ok := err.(kafka.Error); ok && kerr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+
 			// The client will automatically try to recover from all errors.
 			log.WithField("error", err).Error(msg)
 
 			log.Warn("Closing consumer...")
+			d.Close()
 			kc.Close()
 
 			return err
@@ -67,49 +125,100 @@ func (c *Consumer) SubscribeAndListen() error {
 
 		log.WithField("topic", msg.TopicPartition).Info(string(msg.Value))
 
-		var event events.OrderPickedAndPacked
-		if err = json.Unmarshal([]byte(string(msg.Value)), &event); err != nil {
-			log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+		d.Dispatch(msg)
+	}
+}
 
-			continue
-		}
+// commitMessage will commit the offset of a message once it has been handled one way or another
+func commitMessage(kc *kafka.Consumer, msg *kafka.Message) {
+	if _, err := kc.CommitMessage(msg); err != nil {
+		log.WithField("error", err).
+			WithField("topic", msg.TopicPartition).
+			Error("an issue occurred trying to commit the offset of the message")
+	}
+}
 
-		var order models.Order
-		if order, err = extractOrder(event); err != nil {
-			log.WithField("error", err).Error("an issue occurred trying to extract order information from the order recieved event")
+// handleMessage will route a message to the handler for the event it holds, based on its headers. Messages
+// without an event name header were published before headers were added, so they are assumed to hold an OrderPickedAndPacked.
+func (c *Consumer) handleMessage(pool *pgxpool.Pool, msg *kafka.Message) {
+	ctx := headers.NewContext(context.Background(), headers.FromMessage(msg))
+
+	switch name := headers.Get(msg.Headers, headers.EventName); name {
+	case "", events.OrderPickedAndPacked{}.Name():
+		c.handleOrderPickedAndPacked(ctx, pool, msg)
+	default:
+		log.WithField("event.name", name).
+			WithField("topic", msg.TopicPartition).
+			Debug("skipping an event this consumer doesn't handle")
+	}
+}
 
-			hdlr.HandleError(event)
-			continue
-		}
+// handleOrderPickedAndPacked will process a single OrderPickedAndPacked message, every failure is handed off to be retried or dead lettered
+func (c *Consumer) handleOrderPickedAndPacked(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
 
-		if err = processEvent(event, order); err != nil {
-			log.WithField("error", err).Error("an issue occurred trying to process the event")
+	var event events.OrderPickedAndPacked
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
 
-			hdlr.HandleError(event)
-			continue
-		}
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
 
-		// No issues processing the order picked and packed event, lets publish the order time metric
-		tag1 := metrics.Tag{
-			Name:  "products_ordered",
-			Value: strconv.Itoa(len(order.Products)),
-		}
-		tag2 := metrics.Tag{
-			Name:  "order_id",
-			Value: order.ID.String(),
-		}
-		tag3 := metrics.Tag{
-			Name:  "process_step",
-			Value: "shipper",
-		}
-		tags := []metrics.Tag{tag1, tag2, tag3}
-		m := metrics.NewOrderTime(tags)
-		me := events.TranslateToOrderTimeMetricEvent(m)
-		if err = publisher.PublishEvent(me, config.OrderTimeTopicName); err != nil {
-			log.WithField("orderID", order.ID).
-				WithField("error", err.Error()).
-				Error("unable to publish order time metric")
-		}
+	var order models.Order
+	if order, err = extractOrder(event); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to extract order information from the order recieved event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	if err = hdlr.Retry(func() (err error) {
+		order, err = c.processEvent(ctx, pool, event, order)
+		return err
+	}); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	// the shipment is recorded by now, so the event is processed again to publish its outcome if publishing fails,
+	// the customer is only notified once the order shipped event is published
+	if err = hdlr.Retry(func() error { return publishOrderShippedEvent(ctx, order) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to publish an order shipped event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	if err = hdlr.Retry(func() error { return handlers.NotifyShipped(ctx, order) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to notify the customer the order is being shipped")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	// No issues processing the order picked and packed event, lets publish the order time metric
+	tag1 := metrics.Tag{
+		Name:  "products_ordered",
+		Value: strconv.Itoa(len(order.Products)),
+	}
+	tag2 := metrics.Tag{
+		Name:  "order_id",
+		Value: order.ID.String(),
+	}
+	tag3 := metrics.Tag{
+		Name:  "process_step",
+		Value: "shipper",
+	}
+	tags := append([]metrics.Tag{tag1, tag2, tag3}, sla.Tags(order, sla.Shipper, time.Now())...)
+	m := metrics.NewOrderTime(tags)
+	me := events.TranslateToOrderTimeMetricEvent(m)
+	if err = publisher.PublishEvent(me, config.OrderTimeTopicName, publisher.WithContext(ctx)); err != nil {
+		log.WithField("orderID", order.ID).
+			WithField("error", err.Error()).
+			Error("unable to publish order time metric")
 	}
 }
 
@@ -119,67 +228,103 @@ func extractOrder(event events.OrderPickedAndPacked) (models.Order, error) {
 	body := event.Body()
 	order, ok := body.(models.Order)
 	if !ok {
-		return models.Order{}, errors.New("event body can't be cast as an order")
+		return models.Order{}, hdlr.NewPermanentError(errors.New("event body can't be cast as an order"))
 	}
 
 	return order, nil
 }
 
-func processEvent(event events.Event, order models.Order) error {
-	var err error
-
+// processEvent ships the order, unless the event was already processed, in which case the order is returned with
+// the shipment recorded then so the outcome can be published again
+func (c *Consumer) processEvent(ctx context.Context, pool *pgxpool.Pool, event events.Event, order models.Order) (_ models.Order, err error) {
 	db := db.NewDB()
-	conn, err := db.Connect()
-	if err != nil {
-		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
-		return err
-	}
 
 	// begin a transaction
-	tx, err := conn.Begin(context.Background())
+	tx, err := pool.Begin(context.Background())
 	if err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to start a database transaction")
-		return err
+		return order, err
 	}
 
+	// a shipment handed to the carrier that isn't recorded is voided, so the carrier doesn't charge for it, nothing
+	// is published about it until it's recorded
+	var shipped *models.Shipment
 	defer func() {
-		log.Info("committing DB transaction")
-		if err = tx.Commit(context.Background()); err != nil {
-			log.WithField("error", err).Error("an issue occurred trying to commit the transaction")
+		if err == nil {
+			log.Info("committing DB transaction")
+			if err = tx.Commit(context.Background()); err != nil {
+				log.WithField("error", err).Error("an issue occurred trying to commit the transaction")
+			}
+		} else {
+			log.Info("rolling back DB transaction")
+			if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+				log.WithField("error", rbErr).Error("an issue occurred trying to roll back the transaction")
+			}
 		}
 
-		log.Info("closing connection to database")
-		conn.Close(context.Background())
+		if err != nil && shipped != nil {
+			handlers.VoidShipment(ctx, c.Carriers, *shipped)
+		}
 	}()
 
 	// check to see if event has already been processed
-	eventAlreadyProcessed, err := db.EventExists(event, tx)
+	eventAlreadyProcessed, err := db.EventExists(consumerName, event, tx)
 	if err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to check if an event was already processed")
-		return err
+		return order, err
 	}
 
-	// if event has already been processed, nothing more to do
+	// if event has already been processed, the shipment is already recorded
 	if eventAlreadyProcessed {
 		log.WithField("event.id", event.ID()).
 			WithField("event.name", event.Name()).
 			Info("event was processed previously")
 
-		return nil
+		return handlers.ShippedOrder(order, tx)
 	}
 
 	// event hasn't been processed yet, ship the order
-	if err = handlers.ShipOrder(order); err != nil {
+	if order, err = handlers.ShipOrder(ctx, c.Carriers, c.Labels, pool, order, tx); err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to ship the order")
 
-		return err
+		return order, err
 	}
+	shipped = order.Shipment
 
 	// mark the event as processed
-	if err = db.InsertEvent(event, tx); err != nil {
+	if err = db.InsertEvent(consumerName, event, tx); err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to insert the event")
+		return order, err
+	}
+
+	return order, nil
+}
+
+func publishOrderShippedEvent(ctx context.Context, o models.Order) error {
+	// publish an order shipped event per shipment, so the payment of the order can be captured once it's fully shipped,
+	// its ID is derived from the shipment so the payment only sees it once when it's published again
+	e := translateOrderToEvent(o)
+
+	log.WithField("event", e).Info("transformed order to event")
+
+	var err error
+	if err = publisher.PublishEvent(e, config.OrderShippedTopicName, publisher.WithContext(ctx)); err != nil {
 		return err
 	}
 
+	log.WithField("event", e).Info("published event")
+
 	return nil
 }
+
+func translateOrderToEvent(o models.Order) events.Event {
+	var event = events.OrderShipped{
+		EventBase: events.BaseEvent{
+			EventID:        uuid.NewSHA1(o.Shipment.ID, []byte("order-shipped")),
+			EventTimestamp: time.Now(),
+		},
+		EventBody: o,
+	}
+
+	return event
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/logger"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/carrier"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/label"
+)
+
+// Server represents the web server the warehouse gets the shipping labels of shipments from, and shipments are
+// tracked on with their carriers
+type Server struct {
+	Port     int
+	Labels   *label.Store
+	Carriers []carrier.Carrier
+}
+
+// ListenAndServe will start the web server and listen for requests until the context is cancelled, at which
+// point it stops accepting connections and waits for in-flight requests to finish
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	pool, err := db.NewDB().ConnectPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	// setup CHI router
+	r := chi.NewRouter()
+
+	// setup middlewares
+	r.Use(middleware.Heartbeat("/ping")) // allows LB to verify service up
+	r.Use(middleware.RequestID)          // ensures a request ID is logged
+	r.Use(logger.NewStructuredLogger())  // uses structured logging like our app (logs only at debug level)
+	r.Use(middleware.Recoverer)          // handles any unhandles errors and returns a 500
+
+	// setup supported routes
+	r.Get("/shipments/{id}/label", handlers.GetLabel(s.Labels))
+	r.Get("/shipments/{id}/carrier-label", handlers.GetCarrierLabel(s.Carriers, pool))
+	r.Get("/shipments/{id}/tracking", handlers.TrackShipment(s.Carriers, pool))
+
+	address := fmt.Sprintf(":%d", s.Port)
+	log.WithField("address", address).Info("server starting")
+
+	srv := &http.Server{
+		Addr:    address,
+		Handler: r,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.WithField("timeout", config.ShutdownTimeout().String()).Info("server shutting down, draining requests")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout())
+	defer cancel()
+
+	return srv.Shutdown(shutdownCtx)
+}
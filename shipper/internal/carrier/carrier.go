@@ -0,0 +1,110 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// ErrShipmentNotFound is returned when a carrier doesn't know a shipment with the tracking number
+var ErrShipmentNotFound = errors.New("the carrier has no shipment with the tracking number")
+
+// Parcel is a package of a shipment as the carrier sees it, weights are in grams and dimensions in millimetres
+type Parcel struct {
+	WeightGrams int               `json:"weightGrams"`
+	Dimensions  models.Dimensions `json:"dimensions"`
+}
+
+// Request is a shipment to be quoted or handed to a carrier. Costs are quoted in the currency of the order.
+type Request struct {
+	ShipmentID   uuid.UUID           `json:"shipmentId"`
+	ServiceLevel models.ServiceLevel `json:"serviceLevel"`
+	Currency     string              `json:"currency"`
+	To           models.Address      `json:"to"`
+	Parcels      []Parcel            `json:"parcels"`
+}
+
+// Rate is what a carrier charges to ship a request with one of its services. The service level is the fastest one
+// the service is good enough for.
+type Rate struct {
+	Carrier      string              `json:"carrier"`
+	Service      string              `json:"service"`
+	ServiceLevel models.ServiceLevel `json:"serviceLevel"`
+	Cost         models.Money        `json:"cost"`
+	Currency     string              `json:"currency"`
+	TransitDays  int                 `json:"transitDays"`
+}
+
+// Label is the shipping label a carrier created for a shipment, the format is its media type, e.g. application/pdf
+type Label struct {
+	Format string
+	Data   []byte
+}
+
+// TrackingEvent is something that happened to a shipment on its way
+type TrackingEvent struct {
+	Status     string    `json:"status"`
+	Location   string    `json:"location,omitempty"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// Tracking is where a shipment is on its way, the events are in the order they happened
+type Tracking struct {
+	TrackingNumber string          `json:"trackingNumber"`
+	Status         string          `json:"status"`
+	Events         []TrackingEvent `json:"events"`
+}
+
+// Carrier quotes and ships shipments. Shipments are keyed by their ID, so creating one again doesn't ship it twice
+// unless it was voided, and are known by their tracking number once created.
+type Carrier interface {
+	// Name returns the name of the carrier, e.g. UPS
+	Name() string
+
+	// Quote returns the rate of every service of the carrier that can ship the request, an error means the carrier
+	// couldn't be asked
+	Quote(ctx context.Context, r Request) ([]Rate, error)
+
+	// CreateShipment hands the request to the carrier to ship with the service of the rate, and returns its
+	// tracking number
+	CreateShipment(ctx context.Context, r Request, rate Rate) (string, error)
+
+	// Label returns the shipping label the carrier created for the shipment with the tracking number
+	Label(ctx context.Context, trackingNumber string) (Label, error)
+
+	// Track returns where the shipment with the tracking number is on its way
+	Track(ctx context.Context, trackingNumber string) (Tracking, error)
+
+	// Void cancels the shipment with the tracking number before it's collected, so it isn't charged for
+	Void(ctx context.Context, trackingNumber string) error
+}
+
+// New returns the carriers configured by CARRIERS, either the fake carrier or carriers with an HTTP adapter at a
+// URL, e.g. fake,acme=http://localhost:9090
+func New() ([]Carrier, error) {
+	var carriers []Carrier
+	for _, entry := range config.Carriers() {
+		name, url, found := strings.Cut(entry, "=")
+		switch {
+		case found && len(name) > 0 && len(url) > 0:
+			carriers = append(carriers, NewRemote(name, url))
+		case !found && name == "fake":
+			carriers = append(carriers, NewFake())
+		default:
+			return nil, fmt.Errorf("unknown carrier %q, should be fake or <name>=<url>", entry)
+		}
+	}
+
+	if len(carriers) == 0 {
+		return nil, fmt.Errorf("%s has to list at least one carrier", config.CarriersEnvVar)
+	}
+
+	return carriers, nil
+}
@@ -0,0 +1,176 @@
+package carrier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// fakeService is a service of the fake carrier, it charges a base cost per parcel plus a cost for every kilogram
+// started. Costs are the same amount in every currency.
+type fakeService struct {
+	Name         string
+	ServiceLevel models.ServiceLevel
+	TransitDays  int
+	Base         models.Money
+	PerKilogram  models.Money
+}
+
+// fakeServices are the services of the fake carrier, slowest first
+var fakeServices = []fakeService{
+	{Name: "Ground", ServiceLevel: models.StandardShipping, TransitDays: 5, Base: 500, PerKilogram: 100},
+	{Name: "2Day", ServiceLevel: models.ExpeditedShipping, TransitDays: 2, Base: 1200, PerKilogram: 250},
+	{Name: "Overnight", ServiceLevel: models.OvernightShipping, TransitDays: 1, Base: 2500, PerKilogram: 400},
+}
+
+// dimensionalDivisor turns the volume of a parcel in cubic millimetres into the grams it's charged as, the way
+// carriers charge light but bulky parcels by their size
+const dimensionalDivisor = 5000
+
+// fakeShipment is a shipment handed to the fake carrier
+type fakeShipment struct {
+	Request   Request
+	Rate      Rate
+	CreatedAt time.Time
+	VoidedAt  *time.Time
+}
+
+// Fake is a carrier for local runs that never collects anything. It is deterministic: the rates of a request only
+// depend on its parcels, and the tracking number of a shipment is derived from its ID. Shipments are only kept in
+// memory, so the fake carrier forgets them when the service stops.
+type Fake struct {
+	mu        sync.Mutex
+	shipments map[string]*fakeShipment
+}
+
+// NewFake returns a fake carrier that hasn't shipped anything yet
+func NewFake() *Fake {
+	return &Fake{shipments: make(map[string]*fakeShipment)}
+}
+
+// Name returns the name of the fake carrier
+func (f *Fake) Name() string {
+	return "Fake"
+}
+
+// Quote returns the rate of every service of the fake carrier, it ships anything anywhere
+func (f *Fake) Quote(ctx context.Context, r Request) ([]Rate, error) {
+	var kilograms int
+	for _, p := range r.Parcels {
+		grams := p.WeightGrams
+		if dimensional := p.Dimensions.LengthMm * p.Dimensions.WidthMm * p.Dimensions.HeightMm / dimensionalDivisor; dimensional > grams {
+			grams = dimensional
+		}
+
+		// every parcel is charged at least a kilogram
+		started := (grams + 999) / 1000
+		if started == 0 {
+			started = 1
+		}
+		kilograms += started
+	}
+
+	rates := make([]Rate, 0, len(fakeServices))
+	for _, s := range fakeServices {
+		rates = append(rates, Rate{
+			Carrier:      f.Name(),
+			Service:      s.Name,
+			ServiceLevel: s.ServiceLevel,
+			Cost:         s.Base*models.Money(len(r.Parcels)) + s.PerKilogram*models.Money(kilograms),
+			Currency:     r.Currency,
+			TransitDays:  s.TransitDays,
+		})
+	}
+
+	return rates, nil
+}
+
+// CreateShipment pretends to hand the request to the fake carrier
+func (f *Fake) CreateShipment(ctx context.Context, r Request, rate Rate) (string, error) {
+	trackingNumber := "FAKE" + strings.ToUpper(strings.ReplaceAll(r.ShipmentID.String(), "-", ""))[:16]
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// a voided shipment handed over again is booked afresh
+	if s, found := f.shipments[trackingNumber]; !found || s.VoidedAt != nil {
+		f.shipments[trackingNumber] = &fakeShipment{Request: r, Rate: rate, CreatedAt: time.Now()}
+	}
+
+	log.WithField("shipment.id", r.ShipmentID).
+		WithField("carrier.service", rate.Service).
+		WithField("tracking_number", trackingNumber).
+		Info("creating shipment with the fake carrier")
+
+	return trackingNumber, nil
+}
+
+// Label returns a plain text label of the shipment with the tracking number
+func (f *Fake) Label(ctx context.Context, trackingNumber string) (Label, error) {
+	s, err := f.shipment(trackingNumber)
+	if err != nil {
+		return Label{}, err
+	}
+
+	to := s.Request.To
+	data := fmt.Sprintf("%s %s\n%s\n%s\n%s %s %s\n%s\n", f.Name(), s.Rate.Service, trackingNumber, to.Line1, to.City, to.State, to.PostalCode, to.Country)
+
+	return Label{Format: "text/plain", Data: []byte(data)}, nil
+}
+
+// Track returns the shipment with the tracking number as it was created, the fake carrier never collects it
+func (f *Fake) Track(ctx context.Context, trackingNumber string) (Tracking, error) {
+	s, err := f.shipment(trackingNumber)
+	if err != nil {
+		return Tracking{}, err
+	}
+
+	t := Tracking{
+		TrackingNumber: trackingNumber,
+		Status:         "label_created",
+		Events:         []TrackingEvent{{Status: "label_created", OccurredAt: s.CreatedAt}},
+	}
+	if s.VoidedAt != nil {
+		t.Status = "voided"
+		t.Events = append(t.Events, TrackingEvent{Status: "voided", OccurredAt: *s.VoidedAt})
+	}
+
+	return t, nil
+}
+
+// Void pretends to cancel the shipment with the tracking number
+func (f *Fake) Void(ctx context.Context, trackingNumber string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, found := f.shipments[trackingNumber]
+	if !found {
+		return ErrShipmentNotFound
+	}
+	if s.VoidedAt == nil {
+		now := time.Now()
+		s.VoidedAt = &now
+	}
+
+	log.WithField("tracking_number", trackingNumber).Info("voiding shipment with the fake carrier")
+
+	return nil
+}
+
+func (f *Fake) shipment(trackingNumber string) (fakeShipment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, found := f.shipments[trackingNumber]
+	if !found {
+		return fakeShipment{}, ErrShipmentNotFound
+	}
+
+	return *s, nil
+}
@@ -0,0 +1,166 @@
+package carrier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// remoteTimeout is how long a carrier with an HTTP adapter has to answer
+const remoteTimeout = 10 * time.Second
+
+// Remote is the adapter for a carrier behind an HTTP API. Requests are POSTed to the URL as JSON:
+//
+//	POST {url}/rates                              answers {"rates":[{"service":"Ground","serviceLevel":"standard","cost":850,"currency":"USD","transitDays":5}]}
+//	POST {url}/shipments                          {"request":{...},"service":"Ground"}, answers {"trackingNumber":"..."}
+//	GET  {url}/shipments/{trackingNumber}/label   answers the label, in the format of its Content-Type
+//	GET  {url}/shipments/{trackingNumber}         answers the tracking of the shipment
+//	POST {url}/shipments/{trackingNumber}/void
+//
+// Carriers with their own API are adapted by a service translating to and from it.
+type Remote struct {
+	CarrierName string
+	URL         string
+	Client      *http.Client
+}
+
+// NewRemote returns the adapter for the carrier with the name at the URL
+func NewRemote(name, url string) Remote {
+	return Remote{
+		CarrierName: name,
+		URL:         strings.TrimSuffix(url, "/"),
+		Client:      &http.Client{Timeout: remoteTimeout},
+	}
+}
+
+// Name returns the name of the carrier
+func (c Remote) Name() string {
+	return c.CarrierName
+}
+
+// Quote returns the rate of every service of the carrier that can ship the request
+func (c Remote) Quote(ctx context.Context, r Request) ([]Rate, error) {
+	var result struct {
+		Rates []Rate `json:"rates"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/rates", r, &result); err != nil {
+		return nil, err
+	}
+
+	for i := range result.Rates {
+		result.Rates[i].Carrier = c.CarrierName
+	}
+
+	return result.Rates, nil
+}
+
+// CreateShipment hands the request to the carrier to ship with the service of the rate
+func (c Remote) CreateShipment(ctx context.Context, r Request, rate Rate) (string, error) {
+	body := struct {
+		Request Request `json:"request"`
+		Service string  `json:"service"`
+	}{Request: r, Service: rate.Service}
+
+	var result struct {
+		TrackingNumber string `json:"trackingNumber"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/shipments", body, &result); err != nil {
+		return "", err
+	}
+
+	if len(result.TrackingNumber) == 0 {
+		return "", fmt.Errorf("carrier %s answered without a tracking number", c.CarrierName)
+	}
+
+	return result.TrackingNumber, nil
+}
+
+// Label returns the shipping label the carrier created for the shipment with the tracking number
+func (c Remote) Label(ctx context.Context, trackingNumber string) (Label, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/shipments/"+url.PathEscape(trackingNumber)+"/label", nil)
+	if err != nil {
+		return Label{}, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Label{}, fmt.Errorf("unable to read the label from carrier %s: %w", c.CarrierName, err)
+	}
+
+	return Label{Format: resp.Header.Get("Content-Type"), Data: data}, nil
+}
+
+// Track returns where the shipment with the tracking number is on its way
+func (c Remote) Track(ctx context.Context, trackingNumber string) (Tracking, error) {
+	var t Tracking
+	err := c.do(ctx, http.MethodGet, "/shipments/"+url.PathEscape(trackingNumber), nil, &t)
+
+	return t, err
+}
+
+// Void cancels the shipment with the tracking number
+func (c Remote) Void(ctx context.Context, trackingNumber string) error {
+	return c.do(ctx, http.MethodPost, "/shipments/"+url.PathEscape(trackingNumber)+"/void", nil, nil)
+}
+
+// do sends the request body to the path as JSON, and decodes the answer into the result if there is one
+func (c Remote) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(b)
+	}
+
+	resp, err := c.send(ctx, method, path, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("unable to read the answer of carrier %s: %w", c.CarrierName, err)
+	}
+
+	return nil
+}
+
+// send sends the request to the path, a shipment the carrier doesn't know is ErrShipmentNotFound
+func (c Remote) send(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach carrier %s: %w", c.CarrierName, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/shipments/"):
+		resp.Body.Close()
+		return nil, ErrShipmentNotFound
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		resp.Body.Close()
+		return nil, fmt.Errorf("carrier %s answered with %s", c.CarrierName, resp.Status)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,85 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// ErrNoRate is returned when none of the carriers has a service meeting the service level of a request, in the
+// currency of the request
+var ErrNoRate = errors.New("no carrier has a service meeting the service level")
+
+// Shop asks every carrier to quote the request and returns the cheapest rate meeting its service level, along with
+// the carrier it's from. A rate meets a service level if its service is good enough for it or a faster one, and
+// rates that cost the same go to the fastest. Rates in another currency than the request's can't be compared, and
+// are skipped. A carrier that can't be asked is skipped, the request only fails to be quoted if none of them could be.
+func Shop(ctx context.Context, carriers []Carrier, r Request) (Carrier, Rate, error) {
+	level := r.ServiceLevel
+	if len(level) == 0 {
+		level = models.StandardShipping
+	}
+
+	var (
+		best     Rate
+		chosen   Carrier
+		quoted   bool
+		quoteErr error
+	)
+	for _, c := range carriers {
+		rates, err := c.Quote(ctx, r)
+		if err != nil {
+			log.WithField("carrier", c.Name()).
+				WithField("shipment.id", r.ShipmentID).
+				WithField("error", err).
+				Warn("unable to get a quote from the carrier")
+
+			quoteErr = err
+			continue
+		}
+		quoted = true
+
+		for _, rate := range rates {
+			if rate.Currency != r.Currency {
+				log.WithField("carrier", c.Name()).
+					WithField("shipment.id", r.ShipmentID).
+					WithField("carrier.service", rate.Service).
+					WithField("currency", rate.Currency).
+					Warn("skipping a rate of the carrier in another currency than the shipment")
+
+				continue
+			}
+			if speed(rate.ServiceLevel) < speed(level) {
+				continue
+			}
+
+			if chosen == nil || rate.Cost < best.Cost || (rate.Cost == best.Cost && rate.TransitDays < best.TransitDays) {
+				best, chosen = rate, c
+			}
+		}
+	}
+
+	switch {
+	case chosen != nil:
+		return chosen, best, nil
+	case !quoted && quoteErr != nil:
+		return nil, Rate{}, fmt.Errorf("none of the carriers could quote the shipment: %w", quoteErr)
+	default:
+		return nil, Rate{}, fmt.Errorf("%w: %s", ErrNoRate, level)
+	}
+}
+
+// speed returns how fast the service level is compared to the others, unknown service levels are the slowest
+func speed(level models.ServiceLevel) int {
+	for i, l := range models.ServiceLevels {
+		if l == level {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,120 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// quoter is a carrier quoting the same rates for every request, or failing to be asked
+type quoter struct {
+	name  string
+	rates []Rate
+	err   error
+}
+
+func (q quoter) Name() string {
+	return q.name
+}
+
+func (q quoter) Quote(ctx context.Context, r Request) ([]Rate, error) {
+	return q.rates, q.err
+}
+
+func (q quoter) CreateShipment(ctx context.Context, r Request, rate Rate) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (q quoter) Label(ctx context.Context, trackingNumber string) (Label, error) {
+	return Label{}, ErrShipmentNotFound
+}
+
+func (q quoter) Track(ctx context.Context, trackingNumber string) (Tracking, error) {
+	return Tracking{}, ErrShipmentNotFound
+}
+
+func (q quoter) Void(ctx context.Context, trackingNumber string) error {
+	return ErrShipmentNotFound
+}
+
+// rate returns a rate of the carrier in USD
+func rate(carrier, service string, level models.ServiceLevel, cost models.Money, transitDays int) Rate {
+	return Rate{Carrier: carrier, Service: service, ServiceLevel: level, Cost: cost, Currency: "USD", TransitDays: transitDays}
+}
+
+func TestShop(t *testing.T) {
+	acme := quoter{name: "Acme", rates: []Rate{
+		rate("Acme", "Ground", models.StandardShipping, 850, 5),
+		rate("Acme", "2Day", models.ExpeditedShipping, 1500, 2),
+		rate("Acme", "Overnight", models.OvernightShipping, 3000, 1),
+	}}
+	swift := quoter{name: "Swift", rates: []Rate{
+		rate("Swift", "Economy", models.StandardShipping, 700, 7),
+		rate("Swift", "Express", models.OvernightShipping, 1400, 1),
+	}}
+	unreachable := quoter{name: "Down", err: errors.New("connection refused")}
+
+	euros := rate("Euro", "Standard", models.StandardShipping, 100, 3)
+	euros.Currency = "EUR"
+
+	tests := []struct {
+		name        string
+		carriers    []Carrier
+		level       models.ServiceLevel
+		wantCarrier string
+		wantService string
+		wantErr     error
+	}{
+		{name: "cheapest of every carrier", carriers: []Carrier{acme, swift}, level: models.StandardShipping, wantCarrier: "Swift", wantService: "Economy"},
+		{name: "no service level is standard", carriers: []Carrier{acme, swift}, wantCarrier: "Swift", wantService: "Economy"},
+		{name: "faster service is cheaper", carriers: []Carrier{acme, swift}, level: models.ExpeditedShipping, wantCarrier: "Swift", wantService: "Express"},
+		{name: "slower services don't meet the service level", carriers: []Carrier{acme}, level: models.ExpeditedShipping, wantCarrier: "Acme", wantService: "2Day"},
+		{name: "fastest service level", carriers: []Carrier{acme, swift}, level: models.OvernightShipping, wantCarrier: "Swift", wantService: "Express"},
+		{
+			name: "same cost goes to the fastest",
+			carriers: []Carrier{
+				quoter{name: "Slow", rates: []Rate{rate("Slow", "Ground", models.StandardShipping, 900, 6)}},
+				quoter{name: "Quick", rates: []Rate{rate("Quick", "Ground", models.StandardShipping, 900, 3)}},
+			},
+			level:       models.StandardShipping,
+			wantCarrier: "Quick",
+			wantService: "Ground",
+		},
+		{
+			name:        "rates in another currency are skipped",
+			carriers:    []Carrier{quoter{name: "Euro", rates: []Rate{euros}}, acme},
+			level:       models.StandardShipping,
+			wantCarrier: "Acme",
+			wantService: "Ground",
+		},
+		{name: "unreachable carriers are skipped", carriers: []Carrier{unreachable, acme}, level: models.StandardShipping, wantCarrier: "Acme", wantService: "Ground"},
+		{name: "no carrier meets the service level", carriers: []Carrier{quoter{name: "Slow", rates: []Rate{rate("Slow", "Ground", models.StandardShipping, 900, 6)}}}, level: models.OvernightShipping, wantErr: ErrNoRate},
+		{name: "only rates in another currency", carriers: []Carrier{quoter{name: "Euro", rates: []Rate{euros}}}, level: models.StandardShipping, wantErr: ErrNoRate},
+		{name: "no carrier quotes", carriers: []Carrier{quoter{name: "Empty"}}, level: models.StandardShipping, wantErr: ErrNoRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, got, err := Shop(context.Background(), tt.carriers, Request{ServiceLevel: tt.level, Currency: "USD"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Shop() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if c.Name() != tt.wantCarrier || got.Carrier != tt.wantCarrier || got.Service != tt.wantService {
+				t.Errorf("Shop() = %s %s %s, want %s %s", c.Name(), got.Carrier, got.Service, tt.wantCarrier, tt.wantService)
+			}
+		})
+	}
+
+	t.Run("no carrier can be asked", func(t *testing.T) {
+		_, _, err := Shop(context.Background(), []Carrier{unreachable}, Request{ServiceLevel: models.StandardShipping, Currency: "USD"})
+		if err == nil || errors.Is(err, ErrNoRate) {
+			t.Errorf("Shop() error = %v, want the carriers to be retried", err)
+		}
+	})
+}
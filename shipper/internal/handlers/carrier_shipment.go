@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/carrier"
+)
+
+// TrackShipment handler will return where the shipment with the ID in the path is on its way, as its carrier tracks
+// it, or a HTTP 404 status code if there is no such shipment
+//
+// Example cURL (localhost)
+// $ curl -v http://localhost:8081/shipments/6e042f29-350b-4d51-8849-5e36456dfa48/tracking
+func TrackShipment(carriers []carrier.Carrier, pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, c, ok := shipmentCarrier(w, r, carriers, pool)
+		if !ok {
+			return
+		}
+
+		tracking, err := c.Track(r.Context(), s.TrackingNumber)
+		if writeCarrierError(w, err) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(tracking); err != nil {
+			log.WithField("error", err).Error("unable to write the tracking of the shipment")
+		}
+	}
+}
+
+// GetCarrierLabel handler will return the shipping label the carrier of the shipment with the ID in the path created
+// for it, in the format of the carrier, for carriers that want their own label on the box. returns a HTTP 404 status
+// code if there is no such shipment.
+//
+// Example cURL (localhost)
+// $ curl -o label http://localhost:8081/shipments/6e042f29-350b-4d51-8849-5e36456dfa48/carrier-label
+func GetCarrierLabel(carriers []carrier.Carrier, pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, c, ok := shipmentCarrier(w, r, carriers, pool)
+		if !ok {
+			return
+		}
+
+		l, err := c.Label(r.Context(), s.TrackingNumber)
+		if writeCarrierError(w, err) {
+			return
+		}
+
+		w.Header().Set("Content-Type", l.Format)
+		w.WriteHeader(http.StatusOK)
+		if _, err = w.Write(l.Data); err != nil {
+			log.WithField("error", err).Error("unable to write the label of the carrier")
+		}
+	}
+}
+
+// shipmentCarrier returns the recorded shipment with the ID in the path and the carrier it shipped with, writing a
+// HTTP 400 status code if the ID isn't valid, a HTTP 404 if there is no such shipment, or a HTTP 500 if its carrier
+// isn't one of the carriers any more
+func shipmentCarrier(w http.ResponseWriter, r *http.Request, carriers []carrier.Carrier, pool *pgxpool.Pool) (models.Shipment, carrier.Carrier, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid shipment ID", http.StatusBadRequest)
+		return models.Shipment{}, nil, false
+	}
+
+	var s models.Shipment
+	err = pool.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+		var err error
+		s, err = db.NewDB().GetShipment(id, tx)
+		return err
+	})
+	switch {
+	case errors.Is(err, db.ErrShipmentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return models.Shipment{}, nil, false
+	case err != nil:
+		log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return models.Shipment{}, nil, false
+	}
+
+	for _, c := range carriers {
+		if c.Name() == s.Carrier {
+			return s, c, true
+		}
+	}
+
+	err = fmt.Errorf("shipment [%s] shipped with carrier %s, which isn't one of the carriers any more", s.ID, s.Carrier)
+	log.Error(err.Error())
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+
+	return models.Shipment{}, nil, false
+}
+
+// writeCarrierError writes the error of asking a carrier about a shipment, and returns true if there was one. A
+// shipment the carrier doesn't know, e.g. one the fake carrier forgot when the shipper stopped, is a HTTP 404.
+func writeCarrierError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, carrier.ErrShipmentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+
+	return true
+}
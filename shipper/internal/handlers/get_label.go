@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/label"
+)
+
+// GetLabel handler will return the shipping label of the shipment with the ID in the path, as a PDF for office
+// printers or in ZPL for thermal printers depending on the format in the query (pdf if not specified), or a HTTP 404
+// status code if the shipment has no label
+//
+// Example cURL (localhost)
+// $ curl -o label.zpl 'http://localhost:8081/shipments/6e042f29-350b-4d51-8849-5e36456dfa48/label?format=zpl'
+func GetLabel(store *label.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "invalid shipment ID", http.StatusBadRequest)
+			return
+		}
+
+		format := label.Format(r.URL.Query().Get("format"))
+		switch format {
+		case "":
+			format = label.PDF
+		case label.PDF, label.ZPL:
+		default:
+			http.Error(w, fmt.Sprintf("format should be %s or %s", label.PDF, label.ZPL), http.StatusBadRequest)
+			return
+		}
+
+		data, err := store.Load(id, format)
+		switch {
+		case errors.Is(err, label.ErrLabelNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			log.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", id.String()+"."+string(format)))
+		w.WriteHeader(http.StatusOK)
+		if _, err = w.Write(data); err != nil {
+			log.WithField("error", err).Error("unable to write the label")
+		}
+	}
+}
@@ -1,37 +1,188 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/email"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/carrier"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/label"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	log "github.com/sirupsen/logrus"
 )
 
-// ShipOrder will alert the customer the order is being shipped
-func ShipOrder(order models.Order) error {
+// ShipOrder will hand the shipment the warehouse picked and packed to the cheapest carrier meeting the service level
+// of its order, store its shipping label and record it. The order is returned with the shipment, and is fully
+// shipped once every product ordered is in one of its shipments. Orders picked before shipments ship whatever
+// is left of them in one shipment. An order without a complete shipping address is rejected, since there is nowhere
+// to ship it to, and a shipment of more than is left of its order, or one no carrier can ship at its service level,
+// is a permanent error. The carrier a shipment is booked with is recorded before it's handed over (see bookCarrier),
+// and the shipment is voided if it can't be recorded afterwards.
+func ShipOrder(ctx context.Context, carriers []carrier.Carrier, labels *label.Store, pool *pgxpool.Pool, order models.Order, tx pgx.Tx) (_ models.Order, err error) {
 	log.WithField("order.id", order.ID).
 		Info("attempting to alert the customer the order is being shipped")
 
-	// notify the customer the order is being shipped
-	var b strings.Builder
+	address := order.Customer.ShippingAddress
+	if len(address.Line1) == 0 || len(address.City) == 0 || len(address.PostalCode) == 0 {
+		return order, hdlr.NewRejectedError(fmt.Errorf("order [%s] can't be shipped without a complete shipping address", order.ID))
+	}
+
+	store := db.NewDB()
+	shipped, err := store.ListShipments(order.ID, tx)
+	if err != nil {
+		return order, hdlr.NewRetryableError(err)
+	}
+
+	remaining := remainingQuantities(order, shipped)
+	shipment, err := nextShipment(order, shipped, remaining)
+	if err != nil {
+		return order, err
+	}
+
+	codes := make([]string, 0, len(shipment.Lines))
+	for _, l := range shipment.Lines {
+		codes = append(codes, l.ProductCode)
+	}
+	catalogue, err := store.CatalogueProducts(codes, tx)
+	if err != nil {
+		return order, hdlr.NewRetryableError(err)
+	}
+
+	currency := order.Currency
+	if len(currency) == 0 {
+		currency = models.DefaultCurrency
+	}
+
+	req := carrier.Request{
+		ShipmentID:   shipment.ID,
+		ServiceLevel: order.ServiceLevel,
+		Currency:     currency,
+		To:           address,
+	}
+	for i := range shipment.Packages {
+		shipment.Packages[i] = weighPackage(shipment.Packages[i], catalogue)
+		req.Parcels = append(req.Parcels, carrier.Parcel{WeightGrams: shipment.Packages[i].WeightGrams, Dimensions: shipment.Packages[i].Dimensions})
+	}
+
+	c, rate, err := bookCarrier(ctx, pool, carriers, order, req)
+	if err != nil {
+		return order, err
+	}
+
+	// the carrier keys shipments by their ID, so a shipment handed to it again while this event is retried isn't
+	// shipped twice
+	if shipment.TrackingNumber, err = c.CreateShipment(ctx, req, rate); err != nil {
+		return order, hdlr.NewRetryableError(err)
+	}
+
+	// the carrier has the shipment from here on, so it isn't charged for one that doesn't get recorded
+	defer func() {
+		if err != nil {
+			voidShipment(ctx, c, shipment)
+		}
+	}()
+
+	shippedAt := time.Now()
+	shipment.Carrier = rate.Carrier
+	shipment.Service = rate.Service
+	shipment.Cost = rate.Cost
+	shipment.Currency = rate.Currency
+	shipment.ShippedAt = &shippedAt
+	log.WithField("order.id", order.ID).
+		WithField("shipment.id", shipment.ID).
+		WithField("service_level", order.ServiceLevel).
+		WithField("carrier", rate.Carrier).
+		WithField("carrier.service", rate.Service).
+		WithField("cost", rate.Cost.Format(rate.Currency)).
+		WithField("tracking_number", shipment.TrackingNumber).
+		Info("handed shipment to the carrier")
+
+	// the label is stored before the shipment is recorded, so every shipment recorded has one
+	err = labels.Save(label.New(order, shipment))
+	switch {
+	case errors.Is(err, label.ErrNotEncodable):
+		return order, hdlr.NewPermanentError(fmt.Errorf("the label of shipment [%s] of order [%s] can't be printed: %w", shipment.ID, order.ID, err))
+	case err != nil:
+		return order, hdlr.NewRetryableError(err)
+	}
+
+	if err = store.InsertShipment(shipment, tx); err != nil {
+		return order, hdlr.NewRetryableError(err)
+	}
+
+	return markShipped(order, shipment, remaining), nil
+}
+
+// ShippedOrder returns the order with the shipment that was recorded for it when it was shipped before, so the
+// outcome of an event that was already processed can be published again
+func ShippedOrder(order models.Order, tx pgx.Tx) (models.Order, error) {
+	shipped, err := db.NewDB().ListShipments(order.ID, tx)
+	if err != nil {
+		return order, hdlr.NewRetryableError(err)
+	}
+
+	id := shipmentID(order)
+	for k, s := range shipped {
+		if s.ID == id {
+			others := append(append([]models.Shipment(nil), shipped[:k]...), shipped[k+1:]...)
+			return markShipped(order, s, remainingQuantities(order, others)), nil
+		}
+	}
+
+	return order, hdlr.NewPermanentError(fmt.Errorf("shipment [%s] of order [%s] was never recorded", id, order.ID))
+}
+
+// NotifyShipped will alert the customer the shipment of the order is being shipped. The notification's ID is derived
+// from the shipment, so the customer is only notified once when it's published again.
+func NotifyShipped(ctx context.Context, order models.Order) error {
+	shipment := *order.Shipment
+	address := order.Customer.ShippingAddress
+
+	names := make(map[string]models.Product, len(order.Products))
 	for _, p := range order.Products {
-		fmt.Fprintf(&b, "%d of product [%s]", p.Quantity, p.ProductCode)
+		names[p.ProductCode] = p
+	}
+
+	var b strings.Builder
+	for _, l := range shipment.Lines {
+		p := names[l.ProductCode]
+		// orders received before the catalogue have no product names
+		if len(p.Name) > 0 {
+			fmt.Fprintf(&b, "<div>%d of %s [%s]", l.Quantity, p.Name, l.ProductCode)
+		} else {
+			fmt.Fprintf(&b, "<div>%d of product [%s]", l.Quantity, l.ProductCode)
+		}
+		if len(order.Currency) > 0 {
+			fmt.Fprintf(&b, " at %s each", p.UnitPrice.Format(order.Currency))
+		}
+		b.WriteString("</div>")
 	}
 
-	address := fmt.Sprintf("<div>Shipping to Address:</div><div>%s</div><div>%s %s, %s</div>", order.Customer.ShippingAddress.Line1, order.Customer.ShippingAddress.City, order.Customer.ShippingAddress.State, order.Customer.ShippingAddress.PostalCode)
+	var rest string
+	if !order.FullyShipped {
+		rest = "<div>The rest of your order will follow in another shipment.</div>"
+	}
+
+	totals := email.Totals(order.Totals, order.Currency, order.PromotionCode)
+
+	shippingTo := fmt.Sprintf("<div>Shipping to Address:</div><div>%s</div><div>%s %s, %s</div><div>Shipping with %s %s, tracking number %s</div>", address.Line1, address.City, address.State, address.PostalCode, shipment.Carrier, shipment.Service, shipment.TrackingNumber)
 	subject := fmt.Sprintf("Hello %s, your order is being shipped!", order.Customer.FirstName)
-	body := fmt.Sprintf("<div>Your order is on its way! Here is a review of the products in your order:</div><div>%s</div><div>%s</div>", b.String(), address)
+	body := fmt.Sprintf("<div>Your order is on its way! Here are the products in this shipment:</div><div>%s</div>%s<div>%s</div><div>%s</div>", b.String(), rest, totals, shippingTo)
 
-	var err error
 	event := events.Notification{
 		EventBase: events.BaseEvent{
-			EventID:        uuid.New(),
+			EventID:        uuid.NewSHA1(shipment.ID, []byte("shipped-notification")),
 			EventTimestamp: time.Now(),
 		},
 		EventBody: models.Notification{
@@ -43,13 +194,213 @@ func ShipOrder(order models.Order) error {
 		},
 	}
 
-	if err = publisher.PublishEvent(event, config.NotificationTopicName); err != nil {
+	if err := publisher.PublishEvent(event, config.NotificationTopicName, publisher.WithContext(ctx)); err != nil {
 		log.WithField("error", err).
 			WithField("topic", config.NotificationTopicName).
 			Error("an issue ocurred publishing an event to Kafka")
 
-		return err
+		return hdlr.NewRetryableError(err)
 	}
 
 	return nil
 }
+
+// bookCarrier returns the carrier and rate the shipment of the request is booked with. The first time a shipment is
+// booked the carriers are rate shopped, and the rate chosen is recorded before the shipment is handed to the carrier.
+// A shipment retried once the carrier may have it goes back to the same carrier, which keys it by its ID, rather than
+// being shipped by another one too.
+func bookCarrier(ctx context.Context, pool *pgxpool.Pool, carriers []carrier.Carrier, order models.Order, req carrier.Request) (carrier.Carrier, carrier.Rate, error) {
+	store := db.NewDB()
+
+	var booked models.Shipment
+	err := pool.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+		var err error
+		booked, err = store.GetBooking(req.ShipmentID, tx)
+		return err
+	})
+	switch {
+	case err == nil:
+		for _, c := range carriers {
+			if c.Name() == booked.Carrier {
+				return c, carrier.Rate{Carrier: booked.Carrier, Service: booked.Service, Cost: booked.Cost, Currency: booked.Currency}, nil
+			}
+		}
+
+		return nil, carrier.Rate{}, hdlr.NewPermanentError(fmt.Errorf("shipment [%s] of order [%s] was booked with carrier %s, which isn't one of the carriers any more", req.ShipmentID, order.ID, booked.Carrier))
+	case !errors.Is(err, db.ErrBookingNotFound):
+		return nil, carrier.Rate{}, hdlr.NewRetryableError(err)
+	}
+
+	c, rate, err := carrier.Shop(ctx, carriers, req)
+	switch {
+	case errors.Is(err, carrier.ErrNoRate):
+		return nil, carrier.Rate{}, hdlr.NewPermanentError(fmt.Errorf("shipment [%s] of order [%s] can't be shipped: %w", req.ShipmentID, order.ID, err))
+	case err != nil:
+		return nil, carrier.Rate{}, hdlr.NewRetryableError(err)
+	}
+
+	booked = models.Shipment{ID: req.ShipmentID, OrderID: order.ID, Carrier: c.Name(), Service: rate.Service, Cost: rate.Cost, Currency: rate.Currency}
+	if err = pool.BeginFunc(context.Background(), func(tx pgx.Tx) error { return store.InsertBooking(booked, tx) }); err != nil {
+		return nil, carrier.Rate{}, hdlr.NewRetryableError(err)
+	}
+
+	log.WithField("order.id", order.ID).
+		WithField("shipment.id", req.ShipmentID).
+		WithField("carrier", c.Name()).
+		WithField("carrier.service", rate.Service).
+		Info("booked shipment with the carrier")
+
+	return c, rate, nil
+}
+
+// VoidShipment voids a shipment handed to one of the carriers that couldn't be recorded, so the carrier doesn't
+// charge for it. A shipment voided is booked afresh by the carrier when it's handed over again.
+func VoidShipment(ctx context.Context, carriers []carrier.Carrier, s models.Shipment) {
+	for _, c := range carriers {
+		if c.Name() == s.Carrier {
+			voidShipment(ctx, c, s)
+			return
+		}
+	}
+
+	log.WithField("shipment.id", s.ID).
+		WithField("carrier", s.Carrier).
+		Error("unable to void the shipment, its carrier isn't one of the carriers")
+}
+
+// voidShipment voids the shipment with the carrier, a shipment that can't be voided is only logged since it has
+// been booked with the carrier, which keys it by its ID, and is shipped with it when the event is retried
+func voidShipment(ctx context.Context, c carrier.Carrier, s models.Shipment) {
+	if err := c.Void(ctx, s.TrackingNumber); err != nil {
+		log.WithField("shipment.id", s.ID).
+			WithField("tracking_number", s.TrackingNumber).
+			WithField("error", err).
+			Error("unable to void the shipment with the carrier")
+		return
+	}
+
+	log.WithField("shipment.id", s.ID).
+		WithField("tracking_number", s.TrackingNumber).
+		Info("voided the shipment with the carrier")
+}
+
+// remainingQuantities returns how many of each product of the order are left to ship after the shipments
+func remainingQuantities(order models.Order, shipped []models.Shipment) map[string]int {
+	remaining := make(map[string]int, len(order.Products))
+	for _, p := range order.Products {
+		remaining[p.ProductCode] += p.Quantity
+	}
+
+	for _, s := range shipped {
+		for _, l := range s.Lines {
+			remaining[l.ProductCode] -= l.Quantity
+		}
+	}
+
+	return remaining
+}
+
+// markShipped returns the order with the shipment that shipped, fully shipped if nothing is left of it once the
+// shipment is taken off what remained to ship
+func markShipped(order models.Order, shipment models.Shipment, remaining map[string]int) models.Order {
+	left := make(map[string]int, len(remaining))
+	for code, quantity := range remaining {
+		left[code] = quantity
+	}
+	for _, l := range shipment.Lines {
+		left[l.ProductCode] -= l.Quantity
+	}
+
+	order.FullyShipped = true
+	for _, quantity := range left {
+		if quantity > 0 {
+			order.FullyShipped = false
+		}
+	}
+	order.Shipment = &shipment
+
+	return order
+}
+
+// nextShipment returns the shipment the warehouse picked and packed, checking it only ships what is left of the
+// order. Orders picked before shipments get a shipment of everything that is left of them, in one package, with an
+// ID derived from the order so it's the same every time the event is retried.
+func nextShipment(order models.Order, shipped []models.Shipment, remaining map[string]int) (models.Shipment, error) {
+	if order.Shipment == nil {
+		s := models.Shipment{ID: shipmentID(order), OrderID: order.ID}
+		added := make(map[string]bool, len(order.Products))
+		for _, p := range order.Products {
+			if quantity := remaining[p.ProductCode]; quantity > 0 && !added[p.ProductCode] {
+				s.Lines = append(s.Lines, models.ShipmentLine{ProductCode: p.ProductCode, Quantity: quantity})
+				added[p.ProductCode] = true
+			}
+		}
+
+		if len(s.Lines) == 0 {
+			return s, hdlr.NewPermanentError(fmt.Errorf("order [%s] has nothing left to ship", order.ID))
+		}
+		s.Packages = []models.Package{{Lines: s.Lines}}
+
+		return s, nil
+	}
+
+	s := *order.Shipment
+	s.OrderID = order.ID
+	if len(s.Lines) == 0 {
+		return s, hdlr.NewPermanentError(fmt.Errorf("shipment [%s] of order [%s] has no products", s.ID, order.ID))
+	}
+
+	for _, previous := range shipped {
+		if previous.ID == s.ID {
+			return s, hdlr.NewPermanentError(fmt.Errorf("shipment [%s] of order [%s] was already shipped", s.ID, order.ID))
+		}
+	}
+
+	left := make(map[string]int, len(remaining))
+	for code, quantity := range remaining {
+		left[code] = quantity
+	}
+	for _, l := range s.Lines {
+		if l.Quantity <= 0 || l.Quantity > left[l.ProductCode] {
+			return s, hdlr.NewPermanentError(fmt.Errorf("shipment [%s] ships %d of product [%s], but only %d are left to ship in order [%s]", s.ID, l.Quantity, l.ProductCode, left[l.ProductCode], order.ID))
+		}
+		left[l.ProductCode] -= l.Quantity
+	}
+
+	return s, nil
+}
+
+// shipmentID returns the ID of the shipment the warehouse picked and packed of the order, orders picked before
+// shipments have one derived from the order
+func shipmentID(order models.Order) uuid.UUID {
+	if order.Shipment == nil {
+		return uuid.NewSHA1(order.ID, []byte("shipment"))
+	}
+
+	return order.Shipment.ID
+}
+
+// weighPackage returns the package with the weight and dimensions of the products packed in it, stacked on top of
+// each other. Products that aren't in the catalogue, from orders received before it, weigh nothing.
+func weighPackage(p models.Package, catalogue map[string]models.CatalogueProduct) models.Package {
+	p.WeightGrams = 0
+	p.Dimensions = models.Dimensions{}
+	for _, l := range p.Lines {
+		product, found := catalogue[l.ProductCode]
+		if !found {
+			log.WithField("product.code", l.ProductCode).Warn("product isn't in the catalogue, it's shipped as if it weighs nothing")
+			continue
+		}
+
+		p.WeightGrams += product.WeightGrams * l.Quantity
+		p.Dimensions.HeightMm += product.Dimensions.HeightMm * l.Quantity
+		if product.Dimensions.LengthMm > p.Dimensions.LengthMm {
+			p.Dimensions.LengthMm = product.Dimensions.LengthMm
+		}
+		if product.Dimensions.WidthMm > p.Dimensions.WidthMm {
+			p.Dimensions.WidthMm = product.Dimensions.WidthMm
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"testing"
+
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/google/uuid"
+)
+
+func TestShipmentsCaptureAfterTheLast(t *testing.T) {
+	order := models.Order{
+		ID: uuid.New(),
+		Products: []models.Product{
+			{ProductCode: "12345", Quantity: 60},
+			{ProductCode: "67890", Quantity: 50},
+			{ProductCode: "11111", Quantity: 30},
+		},
+	}
+
+	// the shipments the warehouse picks of the order at most 50 units at a time
+	shipments := []models.Shipment{
+		{ID: uuid.New(), Lines: []models.ShipmentLine{{ProductCode: "12345", Quantity: 50}}},
+		{ID: uuid.New(), Lines: []models.ShipmentLine{{ProductCode: "12345", Quantity: 10}, {ProductCode: "67890", Quantity: 40}}},
+		{ID: uuid.New(), Lines: []models.ShipmentLine{{ProductCode: "67890", Quantity: 10}, {ProductCode: "11111", Quantity: 30}}},
+	}
+
+	var shipped []models.Shipment
+	for i, s := range shipments {
+		picked := order
+		picked.Shipment = &shipments[i]
+
+		remaining := remainingQuantities(picked, shipped)
+		next, err := nextShipment(picked, shipped, remaining)
+		if err != nil {
+			t.Fatalf("nextShipment() of shipment %d error = %v", i+1, err)
+		}
+
+		got := markShipped(picked, next, remaining)
+		last := i == len(shipments)-1
+		if got.FullyShipped != last {
+			t.Errorf("markShipped() of shipment %d fully shipped = %v, want %v", i+1, got.FullyShipped, last)
+		}
+		if got.Shipped() != last {
+			t.Errorf("Shipped() after shipment %d = %v, want the payment captured only after the last", i+1, got.Shipped())
+		}
+		if got.Shipment.ID != s.ID {
+			t.Errorf("markShipped() shipment = %s, want %s", got.Shipment.ID, s.ID)
+		}
+
+		shipped = append(shipped, next)
+	}
+
+	// a shipment delivered again once the order is fully shipped is dead lettered rather than captured twice
+	again := order
+	again.Shipment = &shipments[len(shipments)-1]
+	if _, err := nextShipment(again, shipped, remainingQuantities(again, shipped)); hdlr.Classify(err) != hdlr.Permanent {
+		t.Errorf("nextShipment() of a shipped shipment error = %v, want a permanent error", err)
+	}
+}
+
+func TestNextShipment(t *testing.T) {
+	order := models.Order{
+		ID:       uuid.New(),
+		Products: []models.Product{{ProductCode: "12345", Quantity: 2}, {ProductCode: "67890", Quantity: 1}},
+	}
+	earlier := models.Shipment{ID: uuid.New(), Lines: []models.ShipmentLine{{ProductCode: "12345", Quantity: 1}}}
+
+	tests := []struct {
+		name     string
+		shipment *models.Shipment
+		shipped  []models.Shipment
+		want     []models.ShipmentLine
+		wantErr  bool
+	}{
+		{
+			name: "picked before shipments ships everything",
+			want: []models.ShipmentLine{{ProductCode: "12345", Quantity: 2}, {ProductCode: "67890", Quantity: 1}},
+		},
+		{
+			name:    "picked before shipments ships what is left",
+			shipped: []models.Shipment{earlier},
+			want:    []models.ShipmentLine{{ProductCode: "12345", Quantity: 1}, {ProductCode: "67890", Quantity: 1}},
+		},
+		{
+			name:     "part of the order",
+			shipment: &models.Shipment{ID: uuid.New(), Lines: []models.ShipmentLine{{ProductCode: "67890", Quantity: 1}}},
+			shipped:  []models.Shipment{earlier},
+			want:     []models.ShipmentLine{{ProductCode: "67890", Quantity: 1}},
+		},
+		{
+			name:     "more than is left",
+			shipment: &models.Shipment{ID: uuid.New(), Lines: []models.ShipmentLine{{ProductCode: "12345", Quantity: 2}}},
+			shipped:  []models.Shipment{earlier},
+			wantErr:  true,
+		},
+		{
+			name:     "product not in the order",
+			shipment: &models.Shipment{ID: uuid.New(), Lines: []models.ShipmentLine{{ProductCode: "00000", Quantity: 1}}},
+			wantErr:  true,
+		},
+		{
+			name:     "no products",
+			shipment: &models.Shipment{ID: uuid.New()},
+			wantErr:  true,
+		},
+		{
+			name:     "already shipped",
+			shipment: &earlier,
+			shipped:  []models.Shipment{earlier},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := order
+			o.Shipment = tt.shipment
+
+			got, err := nextShipment(o, tt.shipped, remainingQuantities(o, tt.shipped))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nextShipment() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if hdlr.Classify(err) != hdlr.Permanent {
+					t.Errorf("nextShipment() error = %v, want a permanent error", err)
+				}
+				return
+			}
+
+			if len(got.Lines) != len(tt.want) {
+				t.Fatalf("nextShipment() lines = %v, want %v", got.Lines, tt.want)
+			}
+			for i := range got.Lines {
+				if got.Lines[i] != tt.want[i] {
+					t.Errorf("nextShipment() lines = %v, want %v", got.Lines, tt.want)
+				}
+			}
+			if got.OrderID != order.ID {
+				t.Errorf("nextShipment() order = %s, want %s", got.OrderID, order.ID)
+			}
+		})
+	}
+}
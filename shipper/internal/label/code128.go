@@ -0,0 +1,97 @@
+package label
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotEncodable is returned when a value can't be encoded in a Code 128 barcode
+var ErrNotEncodable = errors.New("the value can't be encoded in a Code 128 barcode")
+
+// code128Patterns are the widths of the bars and spaces of every Code 128 symbol, starting with a bar. Symbols 103,
+// 104 and 105 start code sets A, B and C.
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232",
+}
+
+const (
+	code128StartB = 104
+	code128StartC = 105
+
+	// code128Stop is the stop symbol followed by the final bar
+	code128Stop = "2331112"
+
+	// code128QuietZone is how many modules are left blank either side of the barcode, so scanners can find it
+	code128QuietZone = 10
+)
+
+// Code128 returns the modules of a Code 128 barcode of the value, true for a bar and false for a space, including
+// the quiet zones. Values that are an even number of digits are encoded in code set C, two digits to a symbol,
+// anything else in code set B, which covers printable ASCII.
+func Code128(value string) ([]bool, error) {
+	if len(value) == 0 {
+		return nil, fmt.Errorf("%w: it is empty", ErrNotEncodable)
+	}
+
+	var symbols []int
+	if digits(value) && len(value)%2 == 0 {
+		symbols = append(symbols, code128StartC)
+		for i := 0; i < len(value); i += 2 {
+			symbols = append(symbols, int(value[i]-'0')*10+int(value[i+1]-'0'))
+		}
+	} else {
+		symbols = append(symbols, code128StartB)
+		for i := 0; i < len(value); i++ {
+			if value[i] < ' ' || value[i] > '~' {
+				return nil, fmt.Errorf("%w: %q isn't printable ASCII", ErrNotEncodable, value)
+			}
+			symbols = append(symbols, int(value[i]-' '))
+		}
+	}
+
+	// the check symbol is the start symbol plus every symbol weighted by its position, modulo 103
+	check := symbols[0]
+	for i, s := range symbols[1:] {
+		check += (i + 1) * s
+	}
+	symbols = append(symbols, check%103)
+
+	modules := make([]bool, code128QuietZone, code128QuietZone+len(symbols)*11+13+code128QuietZone)
+	for _, s := range symbols {
+		modules = appendWidths(modules, code128Patterns[s])
+	}
+	modules = appendWidths(modules, code128Stop)
+
+	return append(modules, make([]bool, code128QuietZone)...), nil
+}
+
+// appendWidths appends the bars and spaces of the widths to the modules, starting with a bar
+func appendWidths(modules []bool, widths string) []bool {
+	for i, w := range widths {
+		for j := 0; j < int(w-'0'); j++ {
+			modules = append(modules, i%2 == 0)
+		}
+	}
+
+	return modules
+}
+
+func digits(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,96 @@
+package label
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCode128(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []int // the symbols between the start and stop, start symbol and check symbol included
+	}{
+		{name: "even number of digits in code set C", value: "12345678", want: []int{105, 12, 34, 56, 78, 47}},           // 105+12+68+168+312 = 665
+		{name: "odd number of digits in code set B", value: "1234567", want: []int{104, 17, 18, 19, 20, 21, 22, 23, 74}}, // 692
+		{name: "letters and digits", value: "PJJ123C", want: []int{104, 48, 42, 42, 17, 18, 19, 35, 55}},                 // 879
+		{name: "leading zeros", value: "00", want: []int{105, 0, 2}},                                                     // 105
+		{name: "first and last printable characters", value: " ~", want: []int{104, 0, 94, 86}},                          // 104+0+188 = 292
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modules, err := Code128(tt.value)
+			if err != nil {
+				t.Fatalf("Code128() error = %v", err)
+			}
+
+			// every symbol is 11 modules wide, and the stop symbol with its final bar 13
+			if want := code128QuietZone + len(tt.want)*11 + 13 + code128QuietZone; len(modules) != want {
+				t.Fatalf("Code128() = %d modules, want %d", len(modules), want)
+			}
+			for i := 0; i < code128QuietZone; i++ {
+				if modules[i] || modules[len(modules)-1-i] {
+					t.Fatalf("Code128() has a bar in the quiet zones")
+				}
+			}
+
+			body := modules[code128QuietZone : len(modules)-code128QuietZone]
+			var symbols []int
+			for i := 0; i+11 <= len(body)-13; i += 11 {
+				symbols = append(symbols, symbol(t, body[i:i+11]))
+			}
+			if !reflect.DeepEqual(symbols, tt.want) {
+				t.Errorf("Code128() symbols = %v, want %v", symbols, tt.want)
+			}
+
+			if stop := widths(body[len(body)-13:]); stop != code128Stop {
+				t.Errorf("Code128() stop = %s, want %s", stop, code128Stop)
+			}
+		})
+	}
+}
+
+func TestCode128NotEncodable(t *testing.T) {
+	for _, value := range []string{"", "café", "tab\there", "line\n"} {
+		t.Run(value, func(t *testing.T) {
+			if _, err := Code128(value); !errors.Is(err, ErrNotEncodable) {
+				t.Errorf("Code128(%q) error = %v, want %v", value, err, ErrNotEncodable)
+			}
+		})
+	}
+}
+
+// symbol returns the Code 128 symbol the 11 modules are the bars and spaces of
+func symbol(t *testing.T, modules []bool) int {
+	t.Helper()
+
+	w := widths(modules)
+	for s, pattern := range code128Patterns {
+		if pattern == w {
+			return s
+		}
+	}
+
+	t.Fatalf("%s isn't the pattern of a Code 128 symbol", w)
+	return -1
+}
+
+// widths returns the widths of the bars and spaces the modules are made of, starting with a bar
+func widths(modules []bool) string {
+	var b strings.Builder
+	run := 1
+	for i := 1; i <= len(modules); i++ {
+		if i < len(modules) && modules[i] == modules[i-1] {
+			run++
+			continue
+		}
+
+		b.WriteByte(byte('0' + run))
+		run = 1
+	}
+
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package label
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// Format is a format shipping labels are rendered in
+type Format string
+
+const (
+	// PDF labels are for office printers
+	PDF Format = "pdf"
+
+	// ZPL labels are for thermal printers, which draw the barcode themselves
+	ZPL Format = "zpl"
+)
+
+// Formats lists every format a label is rendered in
+var Formats = []Format{PDF, ZPL}
+
+// ContentType returns the media type of labels in the format
+func (f Format) ContentType() string {
+	if f == ZPL {
+		return "application/x-zpl"
+	}
+
+	return "application/pdf"
+}
+
+// Label is what's printed on the shipping label of a shipment, a 4x6 inch label like the carriers use. The tracking
+// number is printed as a Code 128 barcode.
+type Label struct {
+	ShipmentID     uuid.UUID
+	OrderID        uuid.UUID
+	Carrier        string
+	Service        string
+	TrackingNumber string
+	Name           string // who the shipment is for
+	To             models.Address
+	WeightGrams    int
+	Packages       int
+}
+
+// New returns the label of the shipment of the order, once it has been handed to a carrier
+func New(order models.Order, s models.Shipment) Label {
+	l := Label{
+		ShipmentID:     s.ID,
+		OrderID:        order.ID,
+		Carrier:        s.Carrier,
+		Service:        s.Service,
+		TrackingNumber: s.TrackingNumber,
+		Name:           strings.TrimSpace(order.Customer.FirstName + " " + order.Customer.LastName),
+		To:             order.Customer.ShippingAddress,
+		Packages:       len(s.Packages),
+	}
+	for _, p := range s.Packages {
+		l.WeightGrams += p.WeightGrams
+	}
+
+	return l
+}
+
+// Render returns the label in the format
+func (l Label) Render(f Format) ([]byte, error) {
+	switch f {
+	case PDF:
+		return l.PDF()
+	case ZPL:
+		return l.ZPL(), nil
+	default:
+		return nil, fmt.Errorf("labels can't be rendered as %q", f)
+	}
+}
+
+// addressLines returns the lines of the address the label is shipped to, addresses without a country are in the US
+func (l Label) addressLines() []string {
+	lines := []string{l.Name, l.To.Line1}
+	if len(l.To.Line2) > 0 {
+		lines = append(lines, l.To.Line2)
+	}
+	lines = append(lines, strings.TrimSpace(fmt.Sprintf("%s, %s %s", l.To.City, l.To.State, l.To.PostalCode)))
+
+	country := l.To.Country
+	if len(country) == 0 {
+		country = "US"
+	}
+
+	return append(lines, country)
+}
+
+// details returns the lines printed under the barcode, identifying the shipment to the warehouse
+func (l Label) details() []string {
+	return []string{
+		"Order " + l.OrderID.String(),
+		"Shipment " + l.ShipmentID.String(),
+		fmt.Sprintf("%.2f kg, %d package(s)", float64(l.WeightGrams)/1000, l.Packages),
+	}
+}
@@ -0,0 +1,107 @@
+package label
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// the size of a label and its margins in PDF points, 72 to an inch
+const (
+	pdfWidth  = 288
+	pdfHeight = 432
+	pdfMargin = 18
+)
+
+// PDF returns the label as a single page PDF, the barcode is drawn as filled rectangles so it prints sharp
+func (l Label) PDF() ([]byte, error) {
+	modules, err := Code128(l.TrackingNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	var c bytes.Buffer
+	text := func(font string, size, x, y float64, s string) {
+		fmt.Fprintf(&c, "BT /%s %.0f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(s))
+	}
+	rule := func(y float64) {
+		fmt.Fprintf(&c, "%d %.2f %d 1.5 re f\n", pdfMargin, y, pdfWidth-2*pdfMargin)
+	}
+
+	text("F2", 18, pdfMargin, 400, strings.TrimSpace(l.Carrier+" "+l.Service))
+	rule(388)
+
+	text("F1", 9, pdfMargin, 370, "SHIP TO:")
+	for i, line := range l.addressLines() {
+		text("F2", 14, pdfMargin, 350-float64(i)*18, line)
+	}
+	rule(240)
+
+	// the barcode spans the width of the label, merging the modules of each bar into one rectangle
+	module := float64(pdfWidth-2*pdfMargin) / float64(len(modules))
+	for i := 0; i < len(modules); i++ {
+		if !modules[i] {
+			continue
+		}
+
+		start := i
+		for i+1 < len(modules) && modules[i+1] {
+			i++
+		}
+		fmt.Fprintf(&c, "%.3f 140 %.3f 84 re f\n", pdfMargin+float64(start)*module, float64(i-start+1)*module)
+	}
+	text("F2", 12, pdfMargin, 122, "TRACKING #: "+l.TrackingNumber)
+	rule(110)
+
+	for i, line := range l.details() {
+		text("F1", 8, pdfMargin, 94-float64(i)*12, line)
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", pdfWidth, pdfHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", c.Len(), c.String()),
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, len(objects))
+	for i, o := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return b.Bytes(), nil
+}
+
+// pdfString escapes the text to go in a PDF string in the WinAnsi encoding of the fonts, characters it doesn't
+// have are printed as a question mark
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= ' ' && r <= '~':
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+
+	return b.String()
+}
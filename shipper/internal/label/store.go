@@ -0,0 +1,77 @@
+package label
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// ErrLabelNotFound is returned when there is no label of a shipment
+var ErrLabelNotFound = errors.New("label not found")
+
+// Store keeps the labels of shipments in a directory, with a file per shipment and format named after the ID of the
+// shipment, e.g. 6e042f29-350b-4d51-8849-5e36456dfa48.pdf. Services running on the same machine can share it.
+type Store struct {
+	Dir string
+}
+
+// NewStore returns a store of labels kept in the directory
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Save renders the label in every format and stores it, replacing the label the shipment had. A label is either
+// stored whole or not at all, so one being read is never half written.
+func (s *Store) Save(l Label) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	for _, f := range Formats {
+		data, err := l.Render(f)
+		if err != nil {
+			return err
+		}
+
+		if err = s.write(s.path(l.ShipmentID, f), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load returns the label of the shipment in the format
+func (s *Store) Load(shipmentID uuid.UUID, f Format) ([]byte, error) {
+	data, err := os.ReadFile(s.path(shipmentID, f))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrLabelNotFound
+	}
+
+	return data, err
+}
+
+// write writes the data to a temporary file next to the path, and moves it into place once it's all written
+func (s *Store) write(path string, data []byte) error {
+	f, err := os.CreateTemp(s.Dir, ".label-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (s *Store) path(shipmentID uuid.UUID, f Format) string {
+	return filepath.Join(s.Dir, shipmentID.String()+"."+string(f))
+}
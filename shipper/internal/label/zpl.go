@@ -0,0 +1,54 @@
+package label
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// the size of a label in dots of a 203 dpi thermal printer, and its margin
+const (
+	zplWidth  = 812
+	zplHeight = 1218
+	zplMargin = 40
+)
+
+// ZPL returns the label as a ZPL II format, the printer draws the Code 128 barcode itself
+func (l Label) ZPL() []byte {
+	var b bytes.Buffer
+	text := func(size, x, y int, s string) {
+		fmt.Fprintf(&b, "^FO%d,%d^A0N,%d,%d^FH^FD%s^FS\n", x, y, size, size, zplField(s))
+	}
+	rule := func(y int) {
+		fmt.Fprintf(&b, "^FO%d,%d^GB%d,4,4^FS\n", zplMargin, y, zplWidth-2*zplMargin)
+	}
+
+	fmt.Fprintf(&b, "^XA\n^PW%d\n^LL%d\n^CI28\n", zplWidth, zplHeight)
+
+	text(50, zplMargin, 40, strings.TrimSpace(l.Carrier+" "+l.Service))
+	rule(110)
+
+	text(28, zplMargin, 140, "SHIP TO:")
+	for i, line := range l.addressLines() {
+		text(40, zplMargin, 180+i*50, line)
+	}
+	rule(520)
+
+	// automatic mode lets the printer pick the code sets, the tracking number is printed under it separately
+	fmt.Fprintf(&b, "^FO%d,570^BY2^BCN,220,N,N,N,A^FH^FD%s^FS\n", zplMargin+20, zplField(l.TrackingNumber))
+	text(36, zplMargin+20, 810, "TRACKING #: "+l.TrackingNumber)
+	rule(880)
+
+	for i, line := range l.details() {
+		text(24, zplMargin, 910+i*40, line)
+	}
+
+	b.WriteString("^XZ\n")
+
+	return b.Bytes()
+}
+
+// zplField escapes the text to go in a field with ^FH, so it can't end the field or start a command
+func zplField(s string) string {
+	return strings.NewReplacer("_", "_5F", "^", "_5E", "~", "_7E").Replace(s)
+}
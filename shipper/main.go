@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/cmd/consumer"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/cmd/server"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/carrier"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/shipper/internal/label"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -21,25 +26,64 @@ func init() {
 
 	// Only log the warning severity or above.
 	log.SetLevel(config.LogLevel())
+
+	// Identify this service as the producer of the events it publishes
+	publisher.ProducerName = "shipper"
 }
 
 func main() {
 	startTime := time.Now()
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		sig := <-sigs
 		log.WithField("uptime", time.Since(startTime).String()).
 			WithField("signal", sig.String()).
-			Error("interrupt signal detected")
-		os.Exit(0)
+			Warn("interrupt signal detected, shutting down")
+		cancel()
 	}()
 
+	carriers, err := carrier.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the consumer stores the label of every shipment and the server serves them to the warehouse, along with the
+	// tracking of shipments by the carriers they were handed to, if the consumer stops the whole service shuts down
+	labels := label.NewStore(config.LabelDir())
+
 	c := consumer.Consumer{
-		Broker: config.BrokerAddress(),
-		Group:  config.ConsumerGroup(),
-		Topic:  config.OrderPickedAndPackedTopicName,
+		Broker:   config.BrokerAddress(),
+		Group:    config.ConsumerGroup(),
+		Topic:    config.OrderPickedAndPackedTopicName,
+		Carriers: carriers,
+		Labels:   labels,
+	}
+
+	consumed := make(chan error, 1)
+	go func() {
+		err := c.SubscribeAndListen(ctx)
+		cancel()
+		consumed <- err
+	}()
+
+	s := server.Server{
+		Port:     config.ShipperPort(),
+		Labels:   labels,
+		Carriers: carriers,
+	}
+
+	if err = s.ListenAndServe(ctx); err != nil {
+		log.Fatal(err)
 	}
 
-	log.Fatal(c.SubscribeAndListen())
+	if err = <-consumed; err != nil {
+		log.Fatal(err)
+	}
+
+	// flush anything still waiting to be delivered before exiting
+	publisher.Close()
+
+	log.WithField("uptime", time.Since(startTime).String()).Info("shutdown complete")
 }
@@ -0,0 +1,99 @@
+package sla
+
+import (
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/metrics"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+)
+
+// The stages of the pipeline that are measured against the SLA of an order
+const (
+	// Warehouse is when the warehouse has queued the order to be picked
+	Warehouse = "warehouse"
+
+	// Pick is when the warehouse has picked and packed the order
+	Pick = "pick"
+
+	// Shipper is when the order has been handed to the carrier
+	Shipper = "shipper"
+)
+
+// targets are how long after an order is received each stage should be done with it, by service level
+var targets = map[models.ServiceLevel]map[string]time.Duration{
+	models.StandardShipping: {
+		Warehouse: 4 * time.Hour,
+		Pick:      24 * time.Hour,
+		Shipper:   48 * time.Hour,
+	},
+	models.ExpeditedShipping: {
+		Warehouse: time.Hour,
+		Pick:      4 * time.Hour,
+		Shipper:   8 * time.Hour,
+	},
+	models.OvernightShipping: {
+		Warehouse: 30 * time.Minute,
+		Pick:      2 * time.Hour,
+		Shipper:   4 * time.Hour,
+	},
+}
+
+// Target returns how long after an order of the service level is received the stage should be done with it. Orders
+// received before service levels are standard.
+func Target(level models.ServiceLevel, stage string) time.Duration {
+	t, found := targets[level]
+	if !found {
+		t = targets[models.StandardShipping]
+	}
+
+	return t[stage]
+}
+
+// Deadline returns when the stage should be done with the order, and false if the order was received before it was
+// timestamped so there is no deadline
+func Deadline(o models.Order, stage string) (time.Time, bool) {
+	if o.ReceivedAt == nil {
+		return time.Time{}, false
+	}
+
+	return o.ReceivedAt.Add(Target(o.ServiceLevel, stage)), true
+}
+
+// Tags returns the tags of the order time metric of the stage recording the service level of the order, how long
+// after it was received the stage was done with it and whether that met the SLA. A warning is logged for an order
+// that missed the SLA, so it can be alerted on.
+func Tags(o models.Order, stage string, done time.Time) []metrics.Tag {
+	level := o.ServiceLevel
+	if len(level) == 0 {
+		level = models.StandardShipping
+	}
+
+	tags := []metrics.Tag{{Name: "service_level", Value: string(level)}}
+
+	deadline, ok := Deadline(o, stage)
+	if !ok {
+		return tags
+	}
+
+	elapsed := done.Sub(*o.ReceivedAt)
+	met := !done.After(deadline)
+	tags = append(tags,
+		metrics.Tag{Name: "elapsed_ms", Value: strconv.FormatInt(elapsed.Milliseconds(), 10)},
+		metrics.Tag{Name: "sla_ms", Value: strconv.FormatInt(Target(level, stage).Milliseconds(), 10)},
+		metrics.Tag{Name: "sla_met", Value: strconv.FormatBool(met)},
+	)
+
+	if !met {
+		log.WithField("order.id", o.ID).
+			WithField("service_level", level).
+			WithField("process_step", stage).
+			WithField("elapsed", elapsed.String()).
+			WithField("deadline", deadline).
+			Warn("order missed the SLA of the stage")
+	}
+
+	return tags
+}
@@ -2,21 +2,26 @@ package consumer
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"strconv"
+	"time"
 
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/codec"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/dispatcher"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
 	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/headers"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/metrics"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/sla"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/warehouse/internal/handlers"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 // Consumer represents the subscription to a specified Kafka topic
@@ -26,15 +31,25 @@ type Consumer struct {
 	Topic  string
 }
 
-// SubscribeAndListen will subscribe to a Kafka topic and start polling and listening for events
+// pollTimeout is how long to wait for a message before checking if the consumer should shut down
+const pollTimeout = 500 * time.Millisecond
+
+// consumerName is the name the events this consumer processed are recorded under, so another service consuming the
+// same events still processes them
+const consumerName = "warehouse"
+
+// SubscribeAndListen will subscribe to a Kafka topic and start polling and listening for events until the
+// context is cancelled. The message being processed when the context is cancelled is finished and committed
+// before the consumer leaves the group.
 // Adpated from https://github.com/confluentinc/confluent-kafka-go#examples
-func (c *Consumer) SubscribeAndListen() error {
+func (c *Consumer) SubscribeAndListen(ctx context.Context) error {
 
 	kc, err := kafka.NewConsumer(&kafka.ConfigMap{
 		"bootstrap.servers":     c.Broker,
 		"broker.address.family": "v4",
 		"group.id":              c.Group + "-warehouse",
 		"session.timeout.ms":    6000,
+		"enable.auto.commit":    false,
 		"auto.offset.reset":     "earliest"})
 
 	if err != nil {
@@ -45,21 +60,58 @@ func (c *Consumer) SubscribeAndListen() error {
 
 	log.WithField("consumer", kc).Info("Created Consumer")
 
-	if err = kc.SubscribeTopics([]string{c.Topic}, nil); err != nil {
+	pool, err := db.NewDB().ConnectPool(ctx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
+		kc.Close()
+
+		return err
+	}
+
+	defer func() {
+		log.Info("closing connection pool to database")
+		pool.Close()
+	}()
+
+	// each message is committed by the worker that processed it, once it has been handled. Retried messages that
+	// aren't due yet pause their retry topic partition until they are, rather than holding up the poll.
+	d := dispatcher.New(kc, config.PartitionWorkers(), hdlr.RetryWait, func(msg *kafka.Message) {
+		handleMessage(pool, msg)
+		commitMessage(kc, msg)
+	})
+
+	if err = kc.SubscribeTopics([]string{c.Topic, config.RetryTopicName(c.Topic)}, d.Rebalance); err != nil {
 		log.WithField("error", err).
 			WithField("topic", c.Topic).
 			Error("Failed to subscribe to topic")
+		kc.Close()
 
 		return err
 	}
 
 	for {
-		msg, err := kc.ReadMessage(-1)
+		select {
+		case <-ctx.Done():
+			log.Warn("Closing consumer...")
+			d.Close()
+
+			return kc.Close()
+		default:
+		}
+
+		d.Resume()
+
+		msg, err := kc.ReadMessage(pollTimeout)
 		if err != nil {
+			if kerr, ok := err.(kafka.Error); ok && kerr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+
 			// The client will automatically try to recover from all errors.
 			log.WithField("error", err).Error(msg)
 
 			log.Warn("Closing consumer...")
+			d.Close()
 			kc.Close()
 
 			return err
@@ -67,49 +119,81 @@ func (c *Consumer) SubscribeAndListen() error {
 
 		log.WithField("topic", msg.TopicPartition).Info(string(msg.Value))
 
-		var event events.OrderConfirmed
-		if err = json.Unmarshal([]byte(string(msg.Value)), &event); err != nil {
-			log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
+		d.Dispatch(msg)
+	}
+}
 
-			continue
-		}
+// commitMessage will commit the offset of a message once it has been handled one way or another
+func commitMessage(kc *kafka.Consumer, msg *kafka.Message) {
+	if _, err := kc.CommitMessage(msg); err != nil {
+		log.WithField("error", err).
+			WithField("topic", msg.TopicPartition).
+			Error("an issue occurred trying to commit the offset of the message")
+	}
+}
 
-		var order models.Order
-		if order, err = extractOrder(event); err != nil {
-			log.WithField("error", err).Error("an issue occurred trying to extract order information from the order recieved event")
+// handleMessage will route a message to the handler for the event it holds, based on its headers. Messages
+// without an event name header were published before headers were added, so they are assumed to hold an OrderConfirmed.
+func handleMessage(pool *pgxpool.Pool, msg *kafka.Message) {
+	ctx := headers.NewContext(context.Background(), headers.FromMessage(msg))
+
+	switch name := headers.Get(msg.Headers, headers.EventName); name {
+	case "", events.OrderConfirmed{}.Name():
+		handleOrderConfirmed(ctx, pool, msg)
+	default:
+		log.WithField("event.name", name).
+			WithField("topic", msg.TopicPartition).
+			Debug("skipping an event this consumer doesn't handle")
+	}
+}
 
-			hdlr.HandleError(event)
-			continue
-		}
+// handleOrderConfirmed will process a single OrderConfirmed message, every failure is handed off to be retried or dead lettered
+func handleOrderConfirmed(ctx context.Context, pool *pgxpool.Pool, msg *kafka.Message) {
+	var err error
 
-		if err = processEvent(event, order); err != nil {
-			log.WithField("error", err).Error("an issue occurred trying to process the event")
+	var event events.OrderConfirmed
+	if err = codec.Decode(msg, &event); err != nil {
+		log.WithField("error", err).Error("an issue occurred unmarshalling event from message received")
 
-			hdlr.HandleError(event)
-			continue
-		}
+		hdlr.HandleUnreadableMessage(msg, err)
+		return
+	}
 
-		// No issues publishing the order received event, lets publish the order time metric
-		tag1 := metrics.Tag{
-			Name:  "products_ordered",
-			Value: strconv.Itoa(len(order.Products)),
-		}
-		tag2 := metrics.Tag{
-			Name:  "order_id",
-			Value: order.ID.String(),
-		}
-		tag3 := metrics.Tag{
-			Name:  "process_step",
-			Value: "warehouse",
-		}
-		tags := []metrics.Tag{tag1, tag2, tag3}
-		m := metrics.NewOrderTime(tags)
-		me := events.TranslateToOrderTimeMetricEvent(m)
-		if err = publisher.PublishEvent(me, config.OrderTimeTopicName); err != nil {
-			log.WithField("orderID", order.ID).
-				WithField("error", err.Error()).
-				Error("unable to publish order time metric")
-		}
+	var order models.Order
+	if order, err = extractOrder(event); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to extract order information from the order recieved event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	if err = hdlr.Retry(func() error { return processEvent(ctx, pool, event, order) }); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to process the event")
+
+		hdlr.HandleFailure(event, msg, err)
+		return
+	}
+
+	// No issues queueing the order, lets publish the order time metric
+	tag1 := metrics.Tag{
+		Name:  "products_ordered",
+		Value: strconv.Itoa(len(order.Products)),
+	}
+	tag2 := metrics.Tag{
+		Name:  "order_id",
+		Value: order.ID.String(),
+	}
+	tag3 := metrics.Tag{
+		Name:  "process_step",
+		Value: "warehouse",
+	}
+	tags := append([]metrics.Tag{tag1, tag2, tag3}, sla.Tags(order, sla.Warehouse, time.Now())...)
+	m := metrics.NewOrderTime(tags)
+	me := events.TranslateToOrderTimeMetricEvent(m)
+	if err = publisher.PublishEvent(me, config.OrderTimeTopicName, publisher.WithContext(ctx)); err != nil {
+		log.WithField("orderID", order.ID).
+			WithField("error", err.Error()).
+			Error("unable to publish order time metric")
 	}
 }
 
@@ -119,41 +203,40 @@ func extractOrder(event events.OrderConfirmed) (models.Order, error) {
 	body := event.Body()
 	order, ok := body.(models.Order)
 	if !ok {
-		return models.Order{}, errors.New("event body can't be cast as an order")
+		return models.Order{}, hdlr.NewPermanentError(errors.New("event body can't be cast as an order"))
 	}
 
 	return order, nil
 }
 
-func processEvent(event events.Event, order models.Order) error {
-	var err error
-
+func processEvent(ctx context.Context, pool *pgxpool.Pool, event events.Event, order models.Order) (err error) {
 	db := db.NewDB()
-	conn, err := db.Connect()
-	if err != nil {
-		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
-		return err
-	}
 
 	// begin a transaction
-	tx, err := conn.Begin(context.Background())
+	tx, err := pool.Begin(context.Background())
 	if err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to start a database transaction")
 		return err
 	}
 
 	defer func() {
+		if err != nil {
+			log.Info("rolling back DB transaction")
+			if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+				log.WithField("error", rbErr).Error("an issue occurred trying to roll back the transaction")
+			}
+
+			return
+		}
+
 		log.Info("committing DB transaction")
 		if err = tx.Commit(context.Background()); err != nil {
 			log.WithField("error", err).Error("an issue occurred trying to commit the transaction")
 		}
-
-		log.Info("closing connection to database")
-		conn.Close(context.Background())
 	}()
 
 	// check to see if event has already been processed
-	eventAlreadyProcessed, err := db.EventExists(event, tx)
+	eventAlreadyProcessed, err := db.EventExists(consumerName, event, tx)
 	if err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to check if an event was already processed")
 		return err
@@ -168,15 +251,15 @@ func processEvent(event events.Event, order models.Order) error {
 		return nil
 	}
 
-	// event hasn't been processed yet, pick and pack the order
-	if err = handlers.PickAndPackOrder(order); err != nil {
-		log.WithField("error", err).Error("an issue occurred trying to pick and pack the order")
+	// event hasn't been processed yet, queue the order to be picked and packed
+	if err = handlers.QueueOrder(ctx, order, tx); err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to queue the order to be picked")
 
 		return err
 	}
 
 	// mark the event as processed
-	if err = db.InsertEvent(event, tx); err != nil {
+	if err = db.InsertEvent(consumerName, event, tx); err != nil {
 		log.WithField("error", err).Error("an issue occurred trying to insert the event")
 		return err
 	}
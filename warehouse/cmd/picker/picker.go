@@ -0,0 +1,126 @@
+package picker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/metrics"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/sla"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/warehouse/internal/handlers"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+// Picker takes the next order off the pick queue every interval, the way the warehouse personnel would, and
+// publishes an OrderPickedAndPacked event for every shipment of it picked and packed. An order too big for one
+// shipment of at most MaxUnits units stays on the queue until the rest of it is picked.
+type Picker struct {
+	Interval time.Duration
+	MaxUnits int
+}
+
+// Run will pick orders until the context is cancelled, the order being picked when the context is cancelled is
+// finished first
+func (p *Picker) Run(ctx context.Context) error {
+	pool, err := db.NewDB().ConnectPool(ctx)
+	if err != nil {
+		log.WithField("error", err).Error("an issue occurred trying to make a connection to the database")
+
+		return err
+	}
+
+	defer func() {
+		log.Info("closing connection pool to database")
+		pool.Close()
+	}()
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Warn("Stopping picker...")
+
+			return nil
+		case <-ticker.C:
+		}
+
+		// an order that can't be picked stays on the queue to be picked again
+		order, err := p.pickNext(pool)
+		switch {
+		case errors.Is(err, db.ErrPickQueueEmpty):
+			continue
+		case err != nil:
+			log.WithField("error", err).Error("an issue occurred trying to pick the next order")
+			continue
+		}
+
+		publishOrderTimeMetric(order)
+	}
+}
+
+// pickNext takes the next order off the pick queue and picks and packs its next shipment, the shipment is only
+// recorded, and the order only taken off the queue once every product of it is picked, if the OrderPickedAndPacked
+// event is published
+func (p *Picker) pickNext(pool *pgxpool.Pool) (models.Order, error) {
+	queue := db.NewDB()
+
+	var order models.Order
+	err := pool.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+		var picked []models.Shipment
+		var err error
+		if order, picked, err = queue.NextPick(tx); err != nil {
+			return err
+		}
+
+		shipment, done := handlers.PickAndPackOrder(order, picked, p.MaxUnits)
+		if len(shipment.Lines) == 0 {
+			return queue.RecordPick(order.ID, picked, true, tx)
+		}
+		order.Shipment = &shipment
+
+		if err = queue.RecordPick(order.ID, append(picked, shipment), done, tx); err != nil {
+			return err
+		}
+
+		e := events.OrderPickedAndPacked{
+			EventBase: events.BaseEvent{
+				EventID:        uuid.New(),
+				EventTimestamp: time.Now(),
+			},
+			EventBody: order,
+		}
+
+		return publisher.PublishEvent(e, config.OrderPickedAndPackedTopicName)
+	})
+
+	return order, err
+}
+
+// publishOrderTimeMetric publishes the order time metric of picking the order, the order has been picked by then so
+// a metric that can't be published is only logged
+func publishOrderTimeMetric(order models.Order) {
+	tags := []metrics.Tag{
+		{Name: "products_ordered", Value: strconv.Itoa(len(order.Products))},
+		{Name: "order_id", Value: order.ID.String()},
+		{Name: "process_step", Value: sla.Pick},
+	}
+	tags = append(tags, sla.Tags(order, sla.Pick, time.Now())...)
+
+	me := events.TranslateToOrderTimeMetricEvent(metrics.NewOrderTime(tags))
+	if err := publisher.PublishEvent(me, config.OrderTimeTopicName); err != nil {
+		log.WithField("orderID", order.ID).
+			WithField("error", err.Error()).
+			Error("unable to publish order time metric")
+	}
+}
@@ -2,62 +2,66 @@ package handlers
 
 import (
 	"fmt"
-	"strings"
-	"time"
 
-	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
-	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
-	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
-// PickAndPackOrder will alert the warehouse personnel to pick and pack the customers order
-func PickAndPackOrder(order models.Order) error {
+// PickAndPackOrder will alert the warehouse personnel to pick and pack the next shipment of the customers order, given
+// the shipments already picked of it, returning the shipment and whether the whole order has been picked. A shipment
+// holds at most maxUnits units of products, in one package, so bigger orders are picked in several shipments, the
+// products in the order they were ordered. Shipment IDs are derived from the order and how many shipments came
+// before, so a shipment picked again after its event was published isn't shipped twice. Orders without products
+// never make it onto the pick queue (see QueueOrder).
+func PickAndPackOrder(order models.Order, picked []models.Shipment, maxUnits int) (models.Shipment, bool) {
 	log.WithField("order.id", order.ID).
+		WithField("service_level", order.ServiceLevel).
+		WithField("shipments_picked", len(picked)).
 		Info("attempting to alert warehouse personnel to pick and pack order")
 
+	left := make(map[string]int, len(order.Products))
+	for _, p := range order.Products {
+		left[p.ProductCode] += p.Quantity
+	}
+	for _, s := range picked {
+		for _, l := range s.Lines {
+			left[l.ProductCode] -= l.Quantity
+		}
+	}
+
 	// We are not actually connecting to the warehouse system, so just log it for now
+	shipment := models.Shipment{ID: uuid.NewSHA1(order.ID, []byte(fmt.Sprintf("shipment-%d", len(picked)+1))), OrderID: order.ID}
+	units := 0
 	for _, p := range order.Products {
+		quantity := left[p.ProductCode]
+		if quantity <= 0 {
+			continue
+		}
+		if quantity > maxUnits-units {
+			quantity = maxUnits - units
+		}
+		if quantity == 0 {
+			break
+		}
+
 		log.WithField("order.id", order.ID).
 			WithField("product.code", p.ProductCode).
-			WithField("product.quantity", p.Quantity).
+			WithField("product.quantity", quantity).
 			Info("picking product to be packed for shipping")
-	}
 
-	// notify the customer the order is being picked and packed
-	var b strings.Builder
-	for _, p := range order.Products {
-		fmt.Fprintf(&b, "%d of product [%s]", p.Quantity, p.ProductCode)
+		shipment.Lines = append(shipment.Lines, models.ShipmentLine{ProductCode: p.ProductCode, Quantity: quantity})
+		left[p.ProductCode] -= quantity
+		units += quantity
 	}
+	shipment.Packages = []models.Package{{Lines: shipment.Lines}}
 
-	address := fmt.Sprintf("<div>Shipping to Address:</div><div>%s</div><div>%s %s, %s</div>", order.Customer.ShippingAddress.Line1, order.Customer.ShippingAddress.City, order.Customer.ShippingAddress.State, order.Customer.ShippingAddress.PostalCode)
-	subject := fmt.Sprintf("Hello %s, your order has been received.", order.Customer.FirstName)
-	body := fmt.Sprintf("<div>Your order has been received and we will be preparing it for shipping as soon as possible. Here is a review of the products in your order:</div><div>%s</div><div>%s</div>", b.String(), address)
-
-	var err error
-	event := events.Notification{
-		EventBase: events.BaseEvent{
-			EventID:        uuid.New(),
-			EventTimestamp: time.Now(),
-		},
-		EventBody: models.Notification{
-			Type:      models.Email,
-			Recipient: order.Customer.EmailAddress,
-			From:      "orders@ppe4all.com",
-			Subject:   subject,
-			Body:      body,
-		},
-	}
-
-	if err = publisher.PublishEvent(event, config.NotificationTopicName); err != nil {
-		log.WithField("error", err).
-			WithField("topic", config.NotificationTopicName).
-			Error("an issue ocurred publishing an event to Kafka")
-
-		return err
+	done := true
+	for _, quantity := range left {
+		if quantity > 0 {
+			done = false
+		}
 	}
 
-	return nil
+	return shipment, done
 }
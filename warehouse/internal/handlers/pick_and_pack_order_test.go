@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/google/uuid"
+)
+
+func TestPickAndPackOrder(t *testing.T) {
+	order := models.Order{
+		ID: uuid.MustParse("6e042f29-350b-4d51-8849-5e36456dfa48"),
+		Products: []models.Product{
+			{ProductCode: "12345", Quantity: 60},
+			{ProductCode: "67890", Quantity: 50},
+			{ProductCode: "11111", Quantity: 30},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		picked   [][]models.ShipmentLine
+		maxUnits int
+		want     []models.ShipmentLine
+		wantDone bool
+	}{
+		{
+			name:     "fits in one shipment",
+			maxUnits: 200,
+			want:     []models.ShipmentLine{{ProductCode: "12345", Quantity: 60}, {ProductCode: "67890", Quantity: 50}, {ProductCode: "11111", Quantity: 30}},
+			wantDone: true,
+		},
+		{
+			name:     "exactly fits",
+			maxUnits: 140,
+			want:     []models.ShipmentLine{{ProductCode: "12345", Quantity: 60}, {ProductCode: "67890", Quantity: 50}, {ProductCode: "11111", Quantity: 30}},
+			wantDone: true,
+		},
+		{
+			name:     "first shipment splits a product",
+			maxUnits: 50,
+			want:     []models.ShipmentLine{{ProductCode: "12345", Quantity: 50}},
+		},
+		{
+			name:     "next shipment carries on from the last",
+			picked:   [][]models.ShipmentLine{{{ProductCode: "12345", Quantity: 50}}},
+			maxUnits: 50,
+			want:     []models.ShipmentLine{{ProductCode: "12345", Quantity: 10}, {ProductCode: "67890", Quantity: 40}},
+		},
+		{
+			name:     "last shipment",
+			picked:   [][]models.ShipmentLine{{{ProductCode: "12345", Quantity: 50}}, {{ProductCode: "12345", Quantity: 10}, {ProductCode: "67890", Quantity: 40}}},
+			maxUnits: 50,
+			want:     []models.ShipmentLine{{ProductCode: "67890", Quantity: 10}, {ProductCode: "11111", Quantity: 30}},
+			wantDone: true,
+		},
+		{
+			name: "nothing left",
+			picked: [][]models.ShipmentLine{
+				{{ProductCode: "12345", Quantity: 60}, {ProductCode: "67890", Quantity: 50}, {ProductCode: "11111", Quantity: 30}},
+			},
+			maxUnits: 50,
+			wantDone: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var picked []models.Shipment
+			for _, lines := range tt.picked {
+				picked = append(picked, models.Shipment{Lines: lines})
+			}
+
+			got, done := PickAndPackOrder(order, picked, tt.maxUnits)
+			if !reflect.DeepEqual(got.Lines, tt.want) {
+				t.Errorf("PickAndPackOrder() lines = %v, want %v", got.Lines, tt.want)
+			}
+			if done != tt.wantDone {
+				t.Errorf("PickAndPackOrder() done = %v, want %v", done, tt.wantDone)
+			}
+			if !reflect.DeepEqual(got.Packages, []models.Package{{Lines: got.Lines}}) {
+				t.Errorf("PickAndPackOrder() packages = %v, want one of every line", got.Packages)
+			}
+		})
+	}
+}
+
+func TestPickAndPackOrderShipmentIDs(t *testing.T) {
+	order := models.Order{ID: uuid.New(), Products: []models.Product{{ProductCode: "12345", Quantity: 3}}}
+
+	first, _ := PickAndPackOrder(order, nil, 1)
+	again, _ := PickAndPackOrder(order, nil, 1)
+	second, _ := PickAndPackOrder(order, []models.Shipment{first}, 1)
+
+	if first.ID != again.ID {
+		t.Errorf("PickAndPackOrder() picked again = %s, want the same ID %s", again.ID, first.ID)
+	}
+	if first.ID == second.ID {
+		t.Errorf("PickAndPackOrder() next shipment = %s, want a different ID to the first", second.ID)
+	}
+}
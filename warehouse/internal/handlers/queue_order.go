@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/db"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/email"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/events"
+	hdlr "github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/handlers"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/models"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/sla"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+// pickPriorities are the priorities of the service levels in the pick queue, faster service levels jump the queue
+// ahead of slower ones. Orders received before service levels are standard.
+var pickPriorities = map[models.ServiceLevel]int{
+	models.StandardShipping:  0,
+	models.ExpeditedShipping: 1,
+	models.OvernightShipping: 2,
+}
+
+// QueueOrder will add the customers order to the pick queue, ahead of the orders of slower service levels, and let
+// the customer know it's being prepared. Orders of the same service level are picked by when the SLA of picking
+// them runs out.
+func QueueOrder(ctx context.Context, order models.Order, tx pgx.Tx) error {
+	log.WithField("order.id", order.ID).
+		WithField("service_level", order.ServiceLevel).
+		Info("attempting to add order to the pick queue")
+
+	if len(order.Products) == 0 {
+		return hdlr.NewPermanentError(fmt.Errorf("order [%s] has no products to pick", order.ID))
+	}
+
+	// orders received before they were timestamped are due as if they were received now
+	due, ok := sla.Deadline(order, sla.Pick)
+	if !ok {
+		due = time.Now().Add(sla.Target(order.ServiceLevel, sla.Pick))
+	}
+
+	if err := db.NewDB().InsertPick(order, pickPriorities[order.ServiceLevel], due, tx); err != nil {
+		return hdlr.NewRetryableError(err)
+	}
+
+	// notify the customer the order is being prepared
+	var b strings.Builder
+	for _, p := range order.Products {
+		// orders received before the catalogue have no product names
+		if len(p.Name) > 0 {
+			fmt.Fprintf(&b, "<div>%d of %s [%s]", p.Quantity, p.Name, p.ProductCode)
+		} else {
+			fmt.Fprintf(&b, "<div>%d of product [%s]", p.Quantity, p.ProductCode)
+		}
+		if len(order.Currency) > 0 {
+			fmt.Fprintf(&b, " at %s each: %s", p.UnitPrice.Format(order.Currency), p.LineTotal.Format(order.Currency))
+			if p.Discount > 0 {
+				fmt.Fprintf(&b, " less %s discount", p.Discount.Format(order.Currency))
+			}
+			if p.Tax > 0 {
+				fmt.Fprintf(&b, " plus %s tax", p.Tax.Format(order.Currency))
+			}
+		}
+		b.WriteString("</div>")
+	}
+
+	totals := email.Totals(order.Totals, order.Currency, order.PromotionCode)
+
+	address := fmt.Sprintf("<div>Shipping to Address:</div><div>%s</div><div>%s %s, %s</div>", order.Customer.ShippingAddress.Line1, order.Customer.ShippingAddress.City, order.Customer.ShippingAddress.State, order.Customer.ShippingAddress.PostalCode)
+	subject := fmt.Sprintf("Hello %s, your order has been received.", order.Customer.FirstName)
+	body := fmt.Sprintf("<div>Your order has been received and we will be preparing it for shipping as soon as possible. Here is a review of the products in your order:</div><div>%s</div><div>%s</div><div>%s</div>", b.String(), totals, address)
+
+	var err error
+	event := events.Notification{
+		EventBase: events.BaseEvent{
+			EventID:        uuid.New(),
+			EventTimestamp: time.Now(),
+		},
+		EventBody: models.Notification{
+			Type:      models.Email,
+			Recipient: order.Customer.EmailAddress,
+			From:      "orders@ppe4all.com",
+			Subject:   subject,
+			Body:      body,
+		},
+	}
+
+	if err = publisher.PublishEvent(event, config.NotificationTopicName, publisher.WithContext(ctx)); err != nil {
+		log.WithField("error", err).
+			WithField("topic", config.NotificationTopicName).
+			Error("an issue ocurred publishing an event to Kafka")
+
+		return hdlr.NewRetryableError(err)
+	}
+
+	return nil
+}
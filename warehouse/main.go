@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/config"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/publisher"
 	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/warehouse/cmd/consumer"
+	"github.com/bilalislam/Asynchronous-Event-Handling-Using-Microservices-and-Kafka/code/warehouse/cmd/picker"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -21,18 +24,36 @@ func init() {
 
 	// Only log the warning severity or above.
 	log.SetLevel(config.LogLevel())
+
+	// Identify this service as the producer of the events it publishes
+	publisher.ProducerName = "warehouse"
 }
 
 func main() {
 	startTime := time.Now()
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		sig := <-sigs
 		log.WithField("uptime", time.Since(startTime).String()).
 			WithField("signal", sig.String()).
-			Error("interrupt signal detected")
-		os.Exit(0)
+			Warn("interrupt signal detected, shutting down")
+		cancel()
+	}()
+
+	// confirmed orders are queued to be picked, and the picker takes them off the queue the fastest service level
+	// first, if either stops the whole service shuts down
+	p := picker.Picker{
+		Interval: config.PickInterval(),
+		MaxUnits: config.PickMaxUnits(),
+	}
+
+	picked := make(chan error, 1)
+	go func() {
+		err := p.Run(ctx)
+		cancel()
+		picked <- err
 	}()
 
 	c := consumer.Consumer{
@@ -41,5 +62,16 @@ func main() {
 		Topic:  config.OrderConfirmedTopicName,
 	}
 
-	log.Fatal(c.SubscribeAndListen())
+	if err := c.SubscribeAndListen(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := <-picked; err != nil {
+		log.Fatal(err)
+	}
+
+	// flush anything still waiting to be delivered before exiting
+	publisher.Close()
+
+	log.WithField("uptime", time.Since(startTime).String()).Info("shutdown complete")
 }